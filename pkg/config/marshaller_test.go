@@ -20,12 +20,14 @@ var testNamespaceConfig = Namespace{
 		},
 	},
 	Backend: BackendNamespace{
-		Username:     "user0",
-		Password:     "pwd0",
-		Instances:    []string{"127.0.0.1:4000", "127.0.0.1:4001"},
-		SelectorType: "random",
-		PoolSize:     1,
-		IdleTimeout:  20,
+		Username:         "user0",
+		Password:         "pwd0",
+		Instances:        []string{"127.0.0.1:4000", "127.0.0.1:4001"},
+		ReplicaInstances: []string{"127.0.0.1:4002"},
+		InstanceWeights:  map[string]int{"127.0.0.1:4000": 2, "127.0.0.1:4001": 1},
+		SelectorType:     "random",
+		PoolSize:         1,
+		IdleTimeout:      20,
 	},
 }
 
@@ -12,11 +12,23 @@ type Namespace struct {
 type FrontendNamespace struct {
 	AllowedDBs   []string           `yaml:"allowed_dbs"`
 	SlowSQLTime  int                `yaml:"slow_sql_time"`
+	AllowedIPs   []string           `yaml:"allowed_ips"`
 	DeniedIPs    []string           `yaml:"denied_ips"`
 	IdleTimeout  int                `yaml:"idle_timeout"`
 	Users        []FrontendUserInfo `yaml:"users"`
 	SQLBlackList []SQLInfo          `yaml:"sql_blacklist"`
 	SQLWhiteList []SQLInfo          `yaml:"sql_whitelist"`
+	// If RequireSecureTransport is enabled, clients must connect with tls.
+	RequireSecureTransport bool `yaml:"require_secure_transport"`
+	// If EmulatePrepare is enabled, prepared statements are cached in proxy
+	// and don't pin backend connections.
+	EmulatePrepare bool `yaml:"emulate_prepare"`
+	// If AllowLoadDataLocal is enabled, clients can execute LOAD DATA LOCAL INFILE,
+	// the file content is relayed to backend.
+	AllowLoadDataLocal bool `yaml:"allow_load_data_local"`
+	// If Passthrough is enabled, backend response packets of queries are relayed
+	// to clients as they are instead of being decoded and encoded again.
+	Passthrough bool `yaml:"passthrough"`
 }
 
 type FrontendUserInfo struct {
@@ -34,12 +46,39 @@ type RateLimiterInfo struct {
 }
 
 type BackendNamespace struct {
-	Username     string   `yaml:"username"`
-	Password     string   `yaml:"password"`
-	Instances    []string `yaml:"instances"`
-	SelectorType string   `yaml:"selector_type"`
-	PoolSize     int      `yaml:"pool_size"`
-	IdleTimeout  int      `yaml:"idle_timeout"`
+	Username         string          `yaml:"username"`
+	Password         string          `yaml:"password"`
+	Instances        []string        `yaml:"instances"`
+	ReplicaInstances []string        `yaml:"replica_instances"`
+	InstanceWeights  map[string]int  `yaml:"instance_weights"`
+	SelectorType     string          `yaml:"selector_type"`
+	PoolSize         int             `yaml:"pool_size"`
+	IdleTimeout      int             `yaml:"idle_timeout"`
+	HealthCheck      HealthCheckInfo `yaml:"health_check"`
+	// If UseRegistry is enabled, primary instances are discovered from registry instead of Instances.
+	UseRegistry bool            `yaml:"use_registry"`
+	Security    BackendSecurity `yaml:"security"`
+}
+
+// BackendSecurity is the tls config for connections to backend instances.
+type BackendSecurity struct {
+	EnableTLS bool   `yaml:"enable_tls"`
+	SSLCA     string `yaml:"ssl_ca"`
+	// SSLCert and SSLKey are the client cert and key, they are only needed if backend verifies client cert.
+	SSLCert string `yaml:"ssl_cert"`
+	SSLKey  string `yaml:"ssl_key"`
+	// ServerName is used to verify backend cert, backend host is used if it's empty.
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+type HealthCheckInfo struct {
+	Enable           bool   `yaml:"enable"`
+	IntervalMs       int64  `yaml:"interval_ms"`
+	TimeoutMs        int64  `yaml:"timeout_ms"`
+	ProbeSQL         string `yaml:"probe_sql"`
+	FailureThreshold int    `yaml:"failure_threshold"`
+	SuccessThreshold int    `yaml:"success_threshold"`
 }
 
 type StrategyInfo struct {
@@ -20,9 +20,31 @@ type Proxy struct {
 }
 
 type ProxyServer struct {
-	Addr           string `yaml:"addr"`
-	MaxConnections uint32 `yaml:"max_connections"`
-	SessionTimeout int    `yaml:"session_timeout"`
+	Addr           string              `yaml:"addr"`
+	MaxConnections uint32              `yaml:"max_connections"`
+	SessionTimeout int                 `yaml:"session_timeout"`
+	Security       ProxyServerSecurity `yaml:"security"`
+	ProxyProtocol  ProxyProtocol       `yaml:"proxy_protocol"`
+	// Socket is the unix socket path, it's served alongside Addr if it's not empty.
+	Socket string `yaml:"socket"`
+	// SocketPermission is the octal file mode of Socket such as "0660", umask is used if it's empty.
+	SocketPermission string `yaml:"socket_permission"`
+}
+
+// ProxyProtocol is disabled if Networks is empty.
+type ProxyProtocol struct {
+	// Networks are ips or CIDRs of trusted proxies, "*" means all. Connections from them must send PROXY protocol header.
+	Networks      []string `yaml:"networks"`
+	HeaderTimeout int      `yaml:"header_timeout"` // seconds
+}
+
+// ProxyServerSecurity is the tls config for client connections, tls is disabled if cert or key is empty.
+type ProxyServerSecurity struct {
+	SSLCA   string `yaml:"ssl_ca"`
+	SSLCert string `yaml:"ssl_cert"`
+	SSLKey  string `yaml:"ssl_key"`
+	// If VerifyClientCert is enabled, clients must provide certificates signed by SSLCA.
+	VerifyClientCert bool `yaml:"verify_client_cert"`
 }
 
 type AdminServer struct {
@@ -36,6 +58,8 @@ type Log struct {
 	Level   string  `yaml:"level"`
 	Format  string  `yaml:"format"`
 	LogFile LogFile `yaml:"log_file"`
+	// slow log is written to a separate file, it's disabled if filename is empty.
+	SlowLogFile LogFile `yaml:"slow_log_file"`
 }
 
 type LogFile struct {
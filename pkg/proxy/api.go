@@ -4,10 +4,12 @@ import (
 	"net"
 	"net/http"
 	"net/http/pprof"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/tidb-incubator/weir/pkg/config"
 	"github.com/tidb-incubator/weir/pkg/configcenter"
+	"github.com/tidb-incubator/weir/pkg/proxy/backend"
 	"github.com/tidb-incubator/weir/pkg/proxy/namespace"
 	"github.com/tidb-incubator/weir/pkg/proxy/server"
 	"github.com/pingcap/tidb/util/logutil"
@@ -18,8 +20,16 @@ import (
 const (
 	ParamNamespace = "namespace"
 	ParamBreaker   = "breaker"
+
+	QueryAddr   = "addr"
+	QueryRole   = "role"
+	QueryWeight = "weight"
 )
 
+// the backend instances changed by api are not written back to the namespace config,
+// so the change is undone when the namespace is reloaded or the proxy is restarted.
+const msgInstanceChangeNotPersisted = "success, the change is not persisted and is lost after namespace reload or proxy restart"
+
 type HttpApiServer struct {
 	cfg         *config.Proxy
 	proxyServer *server.Server
@@ -41,6 +51,11 @@ type CommonJsonResp struct {
 	Msg  string `json:"msg"`
 }
 
+type BackendStatusJsonResp struct {
+	CommonJsonResp
+	Instances []backend.InstanceStatus `json:"instances"`
+}
+
 func NewNamespaceHttpHandler(nsmgr *namespace.NamespaceManager, cfgCenter configcenter.ConfigCenter) *NamespaceHttpHandler {
 	return &NamespaceHttpHandler{
 		nsmgr:     nsmgr,
@@ -73,6 +88,10 @@ func CreateHttpApiServer(proxyServer *server.Server, nsmgr *namespace.NamespaceM
 	namespaceHttpHandler := NewNamespaceHttpHandler(apiServer.nsmgr, apiServer.cfgCenter)
 	namespaceHttpHandler.AddHandlersToRouteGroup(namespaceRouteGroup)
 
+	tlsRouteGroup := engine.Group("/admin/tls")
+	apiServer.wrapBasicAuthGinMiddleware(tlsRouteGroup)
+	tlsRouteGroup.POST("/reload", apiServer.HandleReloadTLSConfig)
+
 	metricsRouteGroup := engine.Group("/metrics")
 	metricsRouteGroup.GET("/", gin.WrapF(promhttp.Handler().ServeHTTP))
 
@@ -101,6 +120,18 @@ func (h *HttpApiServer) wrapBasicAuthGinMiddleware(group *gin.RouterGroup) {
 	}
 }
 
+func (h *HttpApiServer) HandleReloadTLSConfig(c *gin.Context) {
+	if err := h.proxyServer.ReloadTLSConfig(); err != nil {
+		errMsg := "reload tls config error"
+		logutil.BgLogger().Error(errMsg, zap.Error(err))
+		c.JSON(http.StatusOK, CreateJsonResp(http.StatusInternalServerError, errMsg+": "+err.Error()))
+		return
+	}
+
+	logutil.BgLogger().Info("reload tls config success")
+	c.JSON(http.StatusOK, CreateSuccessJsonResp())
+}
+
 func (h *HttpApiServer) Run() {
 	defer func() {
 		if err := h.listener.Close(); err != nil {
@@ -131,6 +162,9 @@ func (n *NamespaceHttpHandler) AddHandlersToRouteGroup(group *gin.RouterGroup) {
 	group.POST("/remove/:namespace", n.HandleRemoveNamespace)
 	group.POST("/reload/prepare/:namespace", n.HandlePrepareReload)
 	group.POST("/reload/commit/:namespace", n.HandleCommitReload)
+	group.GET("/backend/status/:namespace", n.HandleGetBackendStatus)
+	group.POST("/backend/add/:namespace", n.HandleAddBackendInstance)
+	group.POST("/backend/remove/:namespace", n.HandleRemoveBackendInstance)
 }
 
 func (n *NamespaceHttpHandler) HandleRemoveNamespace(c *gin.Context) {
@@ -189,6 +223,95 @@ func (n *NamespaceHttpHandler) HandleCommitReload(c *gin.Context) {
 	c.JSON(http.StatusOK, CreateSuccessJsonResp())
 }
 
+func (n *NamespaceHttpHandler) HandleGetBackendStatus(c *gin.Context) {
+	ns := c.Param(ParamNamespace)
+	if ns == "" {
+		c.JSON(http.StatusOK, CreateJsonResp(http.StatusBadRequest, "bad namespace parameter"))
+		return
+	}
+
+	targetNs, ok := n.nsmgr.GetNamespace(ns)
+	if !ok {
+		c.JSON(http.StatusOK, CreateJsonResp(http.StatusNotFound, "namespace not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, BackendStatusJsonResp{
+		CommonJsonResp: CreateSuccessJsonResp(),
+		Instances:      targetNs.GetInstanceStatuses(),
+	})
+}
+
+func (n *NamespaceHttpHandler) HandleAddBackendInstance(c *gin.Context) {
+	ns := c.Param(ParamNamespace)
+	if ns == "" {
+		c.JSON(http.StatusOK, CreateJsonResp(http.StatusBadRequest, "bad namespace parameter"))
+		return
+	}
+
+	addr := c.Query(QueryAddr)
+	if addr == "" {
+		c.JSON(http.StatusOK, CreateJsonResp(http.StatusBadRequest, "bad addr parameter"))
+		return
+	}
+	role, err := backend.ParseInstanceRole(c.DefaultQuery(QueryRole, backend.InstanceRoleNamePrimary))
+	if err != nil {
+		c.JSON(http.StatusOK, CreateJsonResp(http.StatusBadRequest, "bad role parameter"))
+		return
+	}
+	weight, err := strconv.Atoi(c.DefaultQuery(QueryWeight, "0"))
+	if err != nil || weight < 0 {
+		c.JSON(http.StatusOK, CreateJsonResp(http.StatusBadRequest, "bad weight parameter"))
+		return
+	}
+
+	targetNs, ok := n.nsmgr.GetNamespace(ns)
+	if !ok {
+		c.JSON(http.StatusOK, CreateJsonResp(http.StatusNotFound, "namespace not found"))
+		return
+	}
+
+	if err := targetNs.AddInstance(addr, role, weight); err != nil {
+		errMsg := "add backend instance error"
+		logutil.BgLogger().Error(errMsg, zap.Error(err), zap.String("namespace", ns), zap.String("addr", addr))
+		c.JSON(http.StatusOK, CreateJsonResp(http.StatusInternalServerError, errMsg+": "+err.Error()))
+		return
+	}
+
+	logutil.BgLogger().Info("add backend instance success", zap.String("namespace", ns), zap.String("addr", addr))
+	c.JSON(http.StatusOK, CreateJsonResp(http.StatusOK, msgInstanceChangeNotPersisted))
+}
+
+func (n *NamespaceHttpHandler) HandleRemoveBackendInstance(c *gin.Context) {
+	ns := c.Param(ParamNamespace)
+	if ns == "" {
+		c.JSON(http.StatusOK, CreateJsonResp(http.StatusBadRequest, "bad namespace parameter"))
+		return
+	}
+
+	addr := c.Query(QueryAddr)
+	if addr == "" {
+		c.JSON(http.StatusOK, CreateJsonResp(http.StatusBadRequest, "bad addr parameter"))
+		return
+	}
+
+	targetNs, ok := n.nsmgr.GetNamespace(ns)
+	if !ok {
+		c.JSON(http.StatusOK, CreateJsonResp(http.StatusNotFound, "namespace not found"))
+		return
+	}
+
+	if err := targetNs.RemoveInstance(addr); err != nil {
+		errMsg := "remove backend instance error"
+		logutil.BgLogger().Error(errMsg, zap.Error(err), zap.String("namespace", ns), zap.String("addr", addr))
+		c.JSON(http.StatusOK, CreateJsonResp(http.StatusInternalServerError, errMsg+": "+err.Error()))
+		return
+	}
+
+	logutil.BgLogger().Info("remove backend instance success", zap.String("namespace", ns), zap.String("addr", addr))
+	c.JSON(http.StatusOK, CreateJsonResp(http.StatusOK, msgInstanceChangeNotPersisted))
+}
+
 func CreateJsonResp(code int, msg string) CommonJsonResp {
 	return CommonJsonResp{
 		Code: code,
@@ -2,11 +2,12 @@ package backend
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"sync"
 	"time"
 
-	"github.com/tidb-incubator/weir/pkg/proxy/backend/client"
+	"github.com/tidb-incubator/weir/pkg/proxy/constant"
 	"github.com/tidb-incubator/weir/pkg/proxy/driver"
 	"github.com/tidb-incubator/weir/pkg/proxy/metrics"
 	"github.com/tidb-incubator/weir/pkg/util/sync2"
@@ -15,13 +16,24 @@ import (
 )
 
 var (
-	ErrNoBackendAddr   = errors.New("no backend addr")
-	ErrBackendClosed   = errors.New("backend is closed")
-	ErrBackendNotFound = errors.New("backend not found")
+	ErrNoBackendAddr         = errors.New("no backend addr")
+	ErrBackendClosed         = errors.New("backend is closed")
+	ErrBackendNotFound       = errors.New("backend not found")
+	ErrDuplicatedBackendAddr = errors.New("duplicated backend addr")
+	ErrInvalidInstanceWeight = errors.New("invalid instance weight")
+	ErrRemoveLastPrimary     = errors.New("cannot remove the last primary instance")
 )
 
+// removedConnPoolDrainTimeout is the max time to wait for the conns of a removed instance to be put back.
+// Pinned conns and conns attached to transactions may never be put back until the client sessions end.
+var removedConnPoolDrainTimeout = time.Minute
+
 type BackendConfig struct {
-	Addrs        map[string]struct{}
+	Addrs        map[string]struct{} // primary instances, serve both read and write
+	ReplicaAddrs map[string]struct{} // read replica instances, serve autocommit read only
+	Weights      map[string]int      // key: addr, only used by weighted selectors
+	HealthCheck  *HealthCheckConfig  // nil if health check is disabled
+	TLSConfig    *tls.Config         // nil if tls is disabled
 	UserName     string
 	Password     string
 	Capacity     int
@@ -33,8 +45,18 @@ type BackendImpl struct {
 	ns        string
 	cfg       *BackendConfig
 	connPools map[string]*ConnPool // key: addr
+	// primaries and replicas are selected separately, so that the state of one selector,
+	// e.g. the counter of round robin, is not shared by interleaved writes and reads.
+	primarySelector Selector
+	replicaSelector Selector
+
+	// instance slices are replaced rather than modified in place when instances are
+	// added or removed, so a slice got under lock can be used after unlocking.
 	instances []*Instance
-	selector  Selector
+	primaries []*Instance
+	replicas  []*Instance
+
+	healthChecker *HealthChecker
 
 	lock   sync.RWMutex
 	closed sync2.AtomicBool
@@ -62,17 +84,23 @@ func (b *BackendImpl) Init() error {
 	if err := b.initConnPools(); err != nil {
 		return err
 	}
+	b.initHealthChecker()
 
 	metrics.BackendEventCounter.WithLabelValues(b.ns, metrics.BackendEventInited).Inc()
 	return nil
 }
 
 func (b *BackendImpl) initSelector() error {
-	selector, err := CreateSelector(b.cfg.SelectorType)
+	primarySelector, err := CreateSelector(b.cfg.SelectorType)
+	if err != nil {
+		return err
+	}
+	replicaSelector, err := CreateSelector(b.cfg.SelectorType)
 	if err != nil {
 		return err
 	}
-	b.selector = selector
+	b.primarySelector = primarySelector
+	b.replicaSelector = replicaSelector
 	return nil
 }
 
@@ -82,19 +110,20 @@ func (b *BackendImpl) initInstances() error {
 		return err
 	}
 	b.instances = instances
+	for _, ins := range instances {
+		if ins.IsReplica() {
+			b.replicas = append(b.replicas, ins)
+		} else {
+			b.primaries = append(b.primaries, ins)
+		}
+	}
 	return nil
 }
 
 func (b *BackendImpl) initConnPools() error {
 	connPools := make(map[string]*ConnPool)
-	for addr := range b.cfg.Addrs {
-		poolCfg := &ConnPoolConfig{
-			Config:      Config{Addr: addr, UserName: b.cfg.UserName, Password: b.cfg.Password},
-			Capacity:    b.cfg.Capacity,
-			IdleTimeout: b.cfg.IdleTimeout,
-		}
-		connPool := NewConnPool(b.ns, poolCfg)
-		connPools[addr] = connPool
+	for _, ins := range b.instances {
+		connPools[ins.Addr()] = b.newConnPool(ins.Addr())
 	}
 
 	successfulInitConnPoolAddrs := make(map[string]struct{})
@@ -116,30 +145,177 @@ func (b *BackendImpl) initConnPools() error {
 		return initConnPoolErr
 	}
 
+	for _, ins := range b.instances {
+		ins.pool = connPools[ins.Addr()]
+	}
 	b.connPools = connPools
 	return nil
 }
 
+func (b *BackendImpl) newConnPool(addr string) *ConnPool {
+	poolCfg := &ConnPoolConfig{
+		Config:      Config{Addr: addr, UserName: b.cfg.UserName, Password: b.cfg.Password, TLSConfig: b.cfg.TLSConfig},
+		Capacity:    b.cfg.Capacity,
+		IdleTimeout: b.cfg.IdleTimeout,
+	}
+	return NewConnPool(b.ns, poolCfg)
+}
+
+func (b *BackendImpl) initHealthChecker() {
+	if b.cfg.HealthCheck == nil {
+		return
+	}
+	p := newConnProber(b.cfg.UserName, b.cfg.Password, b.cfg.TLSConfig, b.cfg.HealthCheck)
+	b.healthChecker = NewHealthChecker(b.ns, b.cfg.HealthCheck, b.getInstances, p)
+	b.healthChecker.Start()
+}
+
 func (b *BackendImpl) GetConn(ctx context.Context) (driver.SimpleBackendConn, error) {
 	if b.closed.Get() {
 		return nil, ErrBackendClosed
 	}
 
-	instance, err := b.route(b.instances)
+	instance, err := b.route(b.primarySelector, selectableInstances(b.getPrimaries()))
 	if err != nil {
 		return nil, err
 	}
 
-	conn, err := client.Connect(instance.Addr(), b.cfg.UserName, b.cfg.Password, "")
+	conn, err := connect(instance.Addr(), b.cfg.UserName, b.cfg.Password, b.cfg.TLSConfig)
 	return conn, err
 }
 
+// GetPooledConn returns a pooled conn of primary instances.
 func (b *BackendImpl) GetPooledConn(ctx context.Context) (driver.PooledBackendConn, error) {
+	return b.getPooledConn(ctx, b.primarySelector, selectableInstances(b.getPrimaries()))
+}
+
+// GetPooledReadConn returns a pooled conn of healthy replica instances,
+// it falls back to primary instances if there is no healthy replica.
+func (b *BackendImpl) GetPooledReadConn(ctx context.Context) (driver.PooledBackendConn, error) {
+	replicas := excludeInstance(filterHealthyInstances(b.getReplicas()), getExcludedAddrFromCtx(ctx))
+	if len(replicas) == 0 {
+		return b.GetPooledConn(ctx)
+	}
+	return b.getPooledConn(ctx, b.replicaSelector, replicas)
+}
+
+func (b *BackendImpl) GetInstanceStatuses() []InstanceStatus {
+	instances := b.getInstances()
+	ret := make([]InstanceStatus, 0, len(instances))
+	for _, ins := range instances {
+		ret = append(ret, ins.Status())
+	}
+	return ret
+}
+
+// AddInstance creates a conn pool for the new instance without affecting other instances.
+// weight 0 means the default weight.
+func (b *BackendImpl) AddInstance(addr string, role InstanceRole, weight int) error {
+	if addr == "" {
+		return ErrNoBackendAddr
+	}
+	if weight < 0 {
+		return ErrInvalidInstanceWeight
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.closed.Get() {
+		return ErrBackendClosed
+	}
+	if _, ok := b.connPools[addr]; ok {
+		return ErrDuplicatedBackendAddr
+	}
+
+	connPool := b.newConnPool(addr)
+	if err := connPool.Init(); err != nil {
+		return err
+	}
+
+	ins := &Instance{addr: addr, role: role, weight: weight, pool: connPool}
+	b.connPools[addr] = connPool
+	b.instances = appendInstance(b.instances, ins)
+	if ins.IsReplica() {
+		b.replicas = appendInstance(b.replicas, ins)
+	} else {
+		b.primaries = appendInstance(b.primaries, ins)
+	}
+
+	metrics.BackendEventCounter.WithLabelValues(b.ns, metrics.BackendEventInstanceAdded).Inc()
+	logutil.BgLogger().Info("backend instance added", zap.String("namespace", b.ns),
+		zap.String("addr", addr), zap.Stringer("role", role))
+	return nil
+}
+
+// RemoveInstance stops routing new requests to the instance and closes its conn pool asynchronously.
+// Closing the pool waits for the conns in use to be put back, so running transactions are not broken.
+// The conns still in use after removedConnPoolDrainTimeout are force closed, see ConnPool.Drain.
+func (b *BackendImpl) RemoveInstance(addr string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.closed.Get() {
+		return ErrBackendClosed
+	}
+	connPool, ok := b.connPools[addr]
+	if !ok {
+		return ErrBackendNotFound
+	}
+
+	primaries := excludeInstance(b.primaries, addr)
+	if len(primaries) == 0 {
+		return ErrRemoveLastPrimary
+	}
+
+	delete(b.connPools, addr)
+	b.instances = excludeInstance(b.instances, addr)
+	b.primaries = primaries
+	b.replicas = excludeInstance(b.replicas, addr)
+	removeSelectorInstance(b.primarySelector, addr)
+	removeSelectorInstance(b.replicaSelector, addr)
+
+	metrics.BackendEventCounter.WithLabelValues(b.ns, metrics.BackendEventInstanceRemoved).Inc()
+	logutil.BgLogger().Info("backend instance removed, draining conn pool", zap.String("namespace", b.ns),
+		zap.String("addr", addr))
+
+	go func() {
+		forceClosed := connPool.Drain(removedConnPoolDrainTimeout)
+		metrics.BackendConnInUseGauge.DeleteLabelValues(b.ns, addr)
+		logutil.BgLogger().Info("conn pool of removed backend instance is closed", zap.String("namespace", b.ns),
+			zap.String("addr", addr), zap.Int("force_closed", forceClosed))
+	}()
+	return nil
+}
+
+func (b *BackendImpl) getInstances() []*Instance {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.instances
+}
+
+func (b *BackendImpl) getPrimaries() []*Instance {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.primaries
+}
+
+func (b *BackendImpl) getReplicas() []*Instance {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.replicas
+}
+
+func (b *BackendImpl) getPooledConn(ctx context.Context, selector Selector, instances []*Instance) (driver.PooledBackendConn, error) {
 	if b.closed.Get() {
 		return nil, ErrBackendClosed
 	}
 
-	instance, err := b.route(b.instances)
+	if excluded := excludeInstance(instances, getExcludedAddrFromCtx(ctx)); len(excluded) != 0 {
+		instances = excluded
+	}
+
+	instance, err := b.route(selector, instances)
 	if err != nil {
 		return nil, err
 	}
@@ -160,6 +336,10 @@ func (b *BackendImpl) Close() {
 		return
 	}
 
+	if b.healthChecker != nil {
+		b.healthChecker.Stop()
+	}
+
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
@@ -172,8 +352,8 @@ func (b *BackendImpl) Close() {
 	metrics.BackendEventCounter.WithLabelValues(b.ns, metrics.BackendEventClosed).Inc()
 }
 
-func (b *BackendImpl) route(instances []*Instance) (*Instance, error) {
-	instance, err := b.selector.Select(b.instances)
+func (b *BackendImpl) route(selector Selector, instances []*Instance) (*Instance, error) {
+	instance, err := selector.Select(instances)
 	if err != nil {
 		return nil, err
 	}
@@ -181,14 +361,72 @@ func (b *BackendImpl) route(instances []*Instance) (*Instance, error) {
 	return instance, nil
 }
 
+// selectableInstances returns healthy instances, or all the instances if none of them is healthy,
+// since the health checker itself may be wrong, e.g. in network partition.
+func selectableInstances(instances []*Instance) []*Instance {
+	healthy := filterHealthyInstances(instances)
+	if len(healthy) == 0 {
+		return instances
+	}
+	return healthy
+}
+
+func filterHealthyInstances(instances []*Instance) []*Instance {
+	ret := make([]*Instance, 0, len(instances))
+	for _, ins := range instances {
+		if ins.IsHealthy() {
+			ret = append(ret, ins)
+		}
+	}
+	return ret
+}
+
+func appendInstance(instances []*Instance, ins *Instance) []*Instance {
+	ret := make([]*Instance, 0, len(instances)+1)
+	ret = append(ret, instances...)
+	return append(ret, ins)
+}
+
+func excludeInstance(instances []*Instance, addr string) []*Instance {
+	if addr == "" {
+		return instances
+	}
+	ret := make([]*Instance, 0, len(instances))
+	for _, ins := range instances {
+		if ins.Addr() != addr {
+			ret = append(ret, ins)
+		}
+	}
+	return ret
+}
+
+// the excluded addr is set when retrying on another instance
+func getExcludedAddrFromCtx(ctx context.Context) string {
+	addr, _ := ctx.Value(constant.ContextKeyExcludedBackendAddr).(string)
+	return addr
+}
+
 func createInstances(cfg *BackendConfig) ([]*Instance, error) {
 	if len(cfg.Addrs) == 0 {
 		return nil, ErrNoBackendAddr
 	}
 
+	for _, weight := range cfg.Weights {
+		if weight <= 0 {
+			return nil, ErrInvalidInstanceWeight
+		}
+	}
+
 	var ret []*Instance
 	for addr := range cfg.Addrs {
-		ins := &Instance{addr: addr}
+		ins := &Instance{addr: addr, role: InstanceRolePrimary, weight: cfg.Weights[addr]}
+		ret = append(ret, ins)
+	}
+	for addr := range cfg.ReplicaAddrs {
+		if _, ok := cfg.Addrs[addr]; ok {
+			return nil, ErrDuplicatedBackendAddr
+		}
+		ins := &Instance{addr: addr, role: InstanceRoleReplica, weight: cfg.Weights[addr]}
 		ret = append(ret, ins)
 	}
 	return ret, nil
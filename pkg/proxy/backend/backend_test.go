@@ -0,0 +1,252 @@
+package backend
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/siddontang/go-mysql/packet"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidb-incubator/weir/pkg/proxy/backend/client"
+	"github.com/tidb-incubator/weir/pkg/proxy/driver"
+	"github.com/tidb-incubator/weir/pkg/proxy/metrics"
+	"github.com/tidb-incubator/weir/pkg/util/pool"
+)
+
+func TestMain(m *testing.M) {
+	metrics.RegisterProxyMetrics("test_cluster")
+	os.Exit(m.Run())
+}
+
+func TestBackendImpl_InitInstances_Roles(t *testing.T) {
+	cfg := &BackendConfig{
+		Addrs:        map[string]struct{}{"127.0.0.1:4000": {}},
+		ReplicaAddrs: map[string]struct{}{"127.0.0.1:4001": {}, "127.0.0.1:4002": {}},
+	}
+	b := NewBackendImpl("test_ns", cfg)
+	assert.NoError(t, b.initInstances())
+
+	assert.Len(t, b.instances, 3)
+	assert.Len(t, b.primaries, 1)
+	assert.Equal(t, "127.0.0.1:4000", b.primaries[0].Addr())
+	assert.False(t, b.primaries[0].IsReplica())
+	assert.Len(t, b.replicas, 2)
+	for _, ins := range b.replicas {
+		assert.True(t, ins.IsReplica())
+	}
+}
+
+func TestBackendImpl_InitInstances_ErrDuplicatedBackendAddr(t *testing.T) {
+	cfg := &BackendConfig{
+		Addrs:        map[string]struct{}{"127.0.0.1:4000": {}},
+		ReplicaAddrs: map[string]struct{}{"127.0.0.1:4000": {}},
+	}
+	b := NewBackendImpl("test_ns", cfg)
+	assert.EqualError(t, b.initInstances(), ErrDuplicatedBackendAddr.Error())
+}
+
+func TestBackendImpl_InitInstances_ErrNoBackendAddr(t *testing.T) {
+	cfg := &BackendConfig{
+		ReplicaAddrs: map[string]struct{}{"127.0.0.1:4001": {}},
+	}
+	b := NewBackendImpl("test_ns", cfg)
+	assert.EqualError(t, b.initInstances(), ErrNoBackendAddr.Error())
+}
+
+func TestBackendImpl_InitInstances_Weights(t *testing.T) {
+	cfg := &BackendConfig{
+		Addrs:   map[string]struct{}{"127.0.0.1:4000": {}, "127.0.0.1:4001": {}},
+		Weights: map[string]int{"127.0.0.1:4000": 3},
+	}
+	b := NewBackendImpl("test_ns", cfg)
+	assert.NoError(t, b.initInstances())
+	for _, ins := range b.instances {
+		if ins.Addr() == "127.0.0.1:4000" {
+			assert.Equal(t, 3, ins.Weight())
+		} else {
+			assert.Equal(t, DefaultInstanceWeight, ins.Weight())
+		}
+	}
+}
+
+func TestBackendImpl_InitInstances_ErrInvalidInstanceWeight(t *testing.T) {
+	cfg := &BackendConfig{
+		Addrs:   map[string]struct{}{"127.0.0.1:4000": {}},
+		Weights: map[string]int{"127.0.0.1:4000": 0},
+	}
+	b := NewBackendImpl("test_ns", cfg)
+	assert.EqualError(t, b.initInstances(), ErrInvalidInstanceWeight.Error())
+}
+
+func TestExcludeInstance(t *testing.T) {
+	host := "127.0.0.1"
+	instances := prepareInstances(host, []int{4000, 4001})
+
+	assert.Len(t, excludeInstance(instances, ""), 2)
+	ret := excludeInstance(instances, getAddr(host, 4000))
+	assert.Len(t, ret, 1)
+	assert.Equal(t, getAddr(host, 4001), ret[0].Addr())
+	assert.Len(t, excludeInstance(ret, getAddr(host, 4001)), 0)
+}
+
+func prepareInitedBackend(t *testing.T, addrs ...string) *BackendImpl {
+	cfg := &BackendConfig{
+		Addrs:        make(map[string]struct{}),
+		Capacity:     1,
+		SelectorType: SelectorTypeRoundRobin,
+	}
+	for _, addr := range addrs {
+		cfg.Addrs[addr] = struct{}{}
+	}
+	b := NewBackendImpl("test_ns", cfg)
+	assert.NoError(t, b.Init())
+	return b
+}
+
+func TestBackendImpl_Route_SelectorPerRole(t *testing.T) {
+	cfg := &BackendConfig{
+		Addrs:        map[string]struct{}{"127.0.0.1:4000": {}, "127.0.0.1:4001": {}},
+		ReplicaAddrs: map[string]struct{}{"127.0.0.1:4002": {}, "127.0.0.1:4003": {}},
+		SelectorType: SelectorTypeRoundRobin,
+	}
+	b := NewBackendImpl("test_ns", cfg)
+	assert.NoError(t, b.initSelector())
+	assert.NoError(t, b.initInstances())
+
+	// interleaved writes and reads are both balanced
+	primaryCounts := make(map[string]int)
+	replicaCounts := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		ins, err := b.route(b.primarySelector, b.getPrimaries())
+		assert.NoError(t, err)
+		primaryCounts[ins.Addr()]++
+		ins, err = b.route(b.replicaSelector, b.getReplicas())
+		assert.NoError(t, err)
+		replicaCounts[ins.Addr()]++
+	}
+	assert.Equal(t, map[string]int{"127.0.0.1:4000": 2, "127.0.0.1:4001": 2}, primaryCounts)
+	assert.Equal(t, map[string]int{"127.0.0.1:4002": 2, "127.0.0.1:4003": 2}, replicaCounts)
+}
+
+func TestBackendImpl_AddInstance(t *testing.T) {
+	b := prepareInitedBackend(t, "127.0.0.1:4000")
+	defer b.Close()
+	oldPool := b.connPools["127.0.0.1:4000"]
+
+	assert.NoError(t, b.AddInstance("127.0.0.1:4001", InstanceRolePrimary, 0))
+	assert.NoError(t, b.AddInstance("127.0.0.1:4002", InstanceRoleReplica, 2))
+	assert.Len(t, b.getInstances(), 3)
+	assert.Len(t, b.getPrimaries(), 2)
+	assert.Len(t, b.getReplicas(), 1)
+	assert.Equal(t, 2, b.getReplicas()[0].Weight())
+	assert.Len(t, b.connPools, 3)
+	assert.Same(t, oldPool, b.connPools["127.0.0.1:4000"])
+
+	assert.EqualError(t, b.AddInstance("127.0.0.1:4002", InstanceRolePrimary, 0), ErrDuplicatedBackendAddr.Error())
+	assert.EqualError(t, b.AddInstance("127.0.0.1:4003", InstanceRolePrimary, -1), ErrInvalidInstanceWeight.Error())
+	assert.EqualError(t, b.AddInstance("", InstanceRolePrimary, 0), ErrNoBackendAddr.Error())
+}
+
+func TestBackendImpl_RemoveInstance(t *testing.T) {
+	b := prepareInitedBackend(t, "127.0.0.1:4000", "127.0.0.1:4001")
+	defer b.Close()
+	assert.NoError(t, b.AddInstance("127.0.0.1:4002", InstanceRoleReplica, 0))
+	primaries := b.getPrimaries()
+
+	assert.NoError(t, b.RemoveInstance("127.0.0.1:4002"))
+	assert.Len(t, b.getReplicas(), 0)
+	assert.NoError(t, b.RemoveInstance("127.0.0.1:4000"))
+	assert.Len(t, b.getInstances(), 1)
+	assert.Len(t, b.getPrimaries(), 1)
+	assert.Equal(t, "127.0.0.1:4001", b.getPrimaries()[0].Addr())
+	assert.Len(t, b.connPools, 1)
+	// slices got before removing are not modified
+	assert.Len(t, primaries, 2)
+
+	assert.EqualError(t, b.RemoveInstance("127.0.0.1:4000"), ErrBackendNotFound.Error())
+	assert.EqualError(t, b.RemoveInstance("127.0.0.1:4001"), ErrRemoveLastPrimary.Error())
+}
+
+func TestBackendImpl_AddRemoveInstance_ErrBackendClosed(t *testing.T) {
+	b := prepareInitedBackend(t, "127.0.0.1:4000")
+	b.Close()
+	assert.EqualError(t, b.AddInstance("127.0.0.1:4001", InstanceRolePrimary, 0), ErrBackendClosed.Error())
+	assert.EqualError(t, b.RemoveInstance("127.0.0.1:4000"), ErrBackendClosed.Error())
+}
+
+// testPinnedConn emulates the conn pinned by a session, which is never put back to pool.
+type testPinnedConn struct {
+	driver.PooledBackendConn
+}
+
+func (c *testPinnedConn) PutBack() {}
+
+// prepareTestConnPool replaces the pool of connPool with one creating conns on net.Pipe,
+// the server side of each conn is sent to the returned channel.
+func prepareTestConnPool(connPool *ConnPool) <-chan net.Conn {
+	serverConns := make(chan net.Conn, connPool.cfg.Capacity)
+	connPool.Close()
+	connPool.initPool(func(context.Context) (pool.Resource, error) {
+		clientConn, serverConn := net.Pipe()
+		serverConns <- serverConn
+		conn := &client.Conn{Conn: packet.NewConn(clientConn)}
+		return &noErrorCloseConnWrapper{newConnWrapper(connPool, conn, connPool.ns, connPool.cfg.Addr, connPool.cfg.UserName)}, nil
+	})
+	return serverConns
+}
+
+func TestBackendImpl_RemoveInstance_ForceClosePinnedConn(t *testing.T) {
+	oldTimeout := removedConnPoolDrainTimeout
+	removedConnPoolDrainTimeout = 100 * time.Millisecond
+	defer func() {
+		removedConnPoolDrainTimeout = oldTimeout
+	}()
+
+	b := prepareInitedBackend(t, "127.0.0.1:4000", "127.0.0.1:4001")
+	defer b.Close()
+	connPool := b.connPools["127.0.0.1:4000"]
+	serverConns := prepareTestConnPool(connPool)
+
+	conn, err := connPool.GetConn(context.Background())
+	assert.NoError(t, err)
+	pinnedConn := &testPinnedConn{PooledBackendConn: conn}
+	serverConn := <-serverConns
+	pinnedConn.PutBack()
+	assert.Equal(t, int64(1), connPool.InUse())
+
+	assert.NoError(t, b.RemoveInstance("127.0.0.1:4000"))
+
+	// the pinned conn is force closed after drain timeout
+	assert.NoError(t, serverConn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = serverConn.Read(make([]byte, 1))
+	assert.NotNil(t, err)
+	assert.False(t, isTimeoutErr(err))
+	assert.Eventually(t, func() bool {
+		return connPool.InUse() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the session closes the pinned conn on bad conn error, and it doesn't touch the closed pool again
+	_, err = pinnedConn.Execute("select 1")
+	assert.NotNil(t, err)
+	assert.NoError(t, pinnedConn.ErrorClose())
+	assert.Equal(t, int64(0), connPool.InUse())
+}
+
+func TestConnPool_Drain_NoForceClose(t *testing.T) {
+	b := prepareInitedBackend(t, "127.0.0.1:4000")
+	defer b.Close()
+	connPool := b.connPools["127.0.0.1:4000"]
+	prepareTestConnPool(connPool)
+
+	conn, err := connPool.GetConn(context.Background())
+	assert.NoError(t, err)
+	conn.PutBack()
+	assert.Equal(t, 0, connPool.Drain(time.Second))
+}
+
+func isTimeoutErr(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
@@ -142,7 +142,7 @@ func (c *Conn) writeAuthHandshake() error {
 	}
 	// Adjust client capability flags based on server support
 	capability := CLIENT_PROTOCOL_41 | CLIENT_SECURE_CONNECTION |
-		CLIENT_LONG_PASSWORD | CLIENT_TRANSACTIONS | CLIENT_PLUGIN_AUTH | c.capability&CLIENT_LONG_FLAG
+		CLIENT_LONG_PASSWORD | CLIENT_TRANSACTIONS | CLIENT_PLUGIN_AUTH | CLIENT_LOCAL_FILES | c.capability&CLIENT_LONG_FLAG
 
 	// To enable TLS / SSL
 	if c.tlsConfig != nil {
@@ -25,6 +25,8 @@ type Conn struct {
 	capability uint32
 
 	status uint16
+	// warnings is the warning count of the last executed statement
+	warnings uint16
 
 	charset string
 
@@ -166,6 +168,127 @@ func (c *Conn) Execute(command string, args ...interface{}) (*Result, error) {
 	}
 }
 
+// ExecuteLocalInfile executes LOAD DATA LOCAL INFILE query. When backend requests the file, fileHandler is called
+// to send the file content by writeData, and an empty packet is sent to end the file after it returns.
+// If fileHandler fails, backend is still waiting for the file, so ErrBadConn is returned.
+func (c *Conn) ExecuteLocalInfile(query string, fileHandler func(filename string, writeData func(data []byte) error) error) (*Result, error) {
+	if err := c.writeCommandStr(COM_QUERY, query); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	data, err := c.ReadPacket()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	switch data[0] {
+	case OK_HEADER:
+		return c.handleOKPacket(data)
+	case ERR_HEADER:
+		return nil, c.handleErrorPacket(data)
+	case LocalInFile_HEADER:
+	default:
+		return c.readResultset(data, false)
+	}
+
+	if err := fileHandler(string(data[1:]), c.writeLocalInfileData); err != nil {
+		return nil, errors.Wrapf(ErrBadConn, "send local infile failed. err %v", err)
+	}
+	if err := c.writeLocalInfileData(nil); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return c.readOK()
+}
+
+// refuseLocalInfile is called if backend requests local file for a query not expected to load it. Backend is waiting
+// for the file, so an empty file is sent and the result is read, then the conn can be used again. ErrMalformPacket is
+// returned if the conn is drained, otherwise ErrBadConn is returned.
+func (c *Conn) refuseLocalInfile() error {
+	if err := c.writeLocalInfileData(nil); err != nil {
+		return errors.Wrapf(ErrBadConn, "refuse local infile failed. err %v", err)
+	}
+	if _, err := c.readOK(); err != nil {
+		if _, ok := errors.Cause(err).(*MyError); !ok {
+			return errors.Wrapf(ErrBadConn, "refuse local infile failed. err %v", err)
+		}
+	}
+	return ErrMalformPacket
+}
+
+// ExecuteStream executes query and passes the packets of text result set to handlers as soon as they are read,
+// so that the rows are never buffered. columnHandler is called with the column definition packets, then rowHandler
+// is called with each row packet, EOF packets are not passed. The returned Result has no Resultset.
+// If a handler fails, the rest rows are not read, so ErrBadConn is returned.
+func (c *Conn) ExecuteStream(query string, columnHandler func(columns [][]byte) error, rowHandler func(row []byte) error) (*Result, error) {
+	if err := c.writeCommandStr(COM_QUERY, query); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	data, err := c.ReadPacket()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	switch data[0] {
+	case OK_HEADER:
+		return c.handleOKPacket(data)
+	case ERR_HEADER:
+		return nil, c.handleErrorPacket(data)
+	case LocalInFile_HEADER:
+		return nil, c.refuseLocalInfile()
+	}
+
+	count, _, n := LengthEncodedInt(data)
+	if n-len(data) != 0 {
+		return nil, ErrMalformPacket
+	}
+	columns, err := c.readStreamColumns(int(count))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = columnHandler(columns); err != nil {
+		return nil, errors.Wrapf(ErrBadConn, "stream columns failed. err %v", err)
+	}
+	return c.readStreamRows(rowHandler)
+}
+
+// ExecutePassthrough executes query and passes the response packets to packetHandler as they are, including
+// the OK packet, column definitions, rows and EOF packets, so that they can be relayed without being encoded again.
+// The ERR packet is returned as error instead. The returned Result only has the fields of OK and EOF packets.
+// If packetHandler fails, the rest packets are not read, so ErrBadConn is returned.
+func (c *Conn) ExecutePassthrough(query string, packetHandler func(data []byte) error) (*Result, error) {
+	if err := c.writeCommandStr(COM_QUERY, query); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	data, err := c.ReadPacket()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	switch data[0] {
+	case OK_HEADER:
+		r, err := c.handleOKPacket(data)
+		if err != nil {
+			return nil, err
+		}
+		if err = packetHandler(data); err != nil {
+			return nil, errors.Wrapf(ErrBadConn, "relay ok packet failed. err %v", err)
+		}
+		return r, nil
+	case ERR_HEADER:
+		return nil, c.handleErrorPacket(data)
+	case LocalInFile_HEADER:
+		return nil, c.refuseLocalInfile()
+	}
+
+	if err = packetHandler(data); err != nil {
+		return nil, errors.Wrapf(ErrBadConn, "relay column count failed. err %v", err)
+	}
+	// column definitions and rows are both ended by EOF packet
+	if _, err = c.relayUntilEOF(packetHandler); err != nil {
+		return nil, err
+	}
+	return c.relayUntilEOF(packetHandler)
+}
+
 func (c *Conn) Begin() error {
 	_, err := c.exec("BEGIN")
 	return errors.Trace(err)
@@ -259,6 +382,10 @@ func (c *Conn) GetStatus() uint16 {
 	return c.status
 }
 
+func (c *Conn) GetWarnings() uint16 {
+	return c.warnings
+}
+
 func (c *Conn) HandleOKPacket(data []byte) *Result {
 	r, _ := c.handleOKPacket(data)
 	return r
@@ -0,0 +1,224 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+
+	. "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/packet"
+	"github.com/stretchr/testify/require"
+)
+
+// testBackendConn replies resps in order to the packets written to it, the last one is replied repeatedly.
+type testBackendConn struct {
+	net.Conn
+	resps  [][]byte
+	writes int
+	r      bytes.Reader
+}
+
+func (c *testBackendConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *testBackendConn) Write(b []byte) (int, error) {
+	if c.writes < len(c.resps) {
+		c.r.Reset(c.resps[c.writes])
+	} else {
+		c.r.Reset(c.resps[len(c.resps)-1])
+	}
+	c.writes++
+	return len(b), nil
+}
+
+// encodeTestPackets encodes packets with sequence ids starting from seq.
+func encodeTestPackets(seq int, packets ...[]byte) []byte {
+	var resp []byte
+	for i, p := range packets {
+		resp = append(resp, byte(len(p)), byte(len(p)>>8), byte(len(p)>>16), byte(seq+i))
+		resp = append(resp, p...)
+	}
+	return resp
+}
+
+func newTestConn(packets ...[]byte) *Conn {
+	return newTestConnWithResps(encodeTestPackets(1, packets...))
+}
+
+func newTestConnWithResps(resps ...[]byte) *Conn {
+	return &Conn{
+		Conn:       packet.NewConn(&testBackendConn{resps: resps}),
+		capability: CLIENT_PROTOCOL_41,
+	}
+}
+
+func newTestEOFPacket(status uint16) []byte {
+	return []byte{EOF_HEADER, 0, 0, byte(status), byte(status >> 8)}
+}
+
+// newTestResultsetPackets returns the packets of a text result set with 3 columns and rowCount rows.
+func newTestResultsetPackets(t testing.TB, rowCount int) [][]byte {
+	values := make([][]interface{}, 0, rowCount)
+	for i := 0; i < rowCount; i++ {
+		values = append(values, []interface{}{i, fmt.Sprintf("name_%d", i), 3.14})
+	}
+	rs, err := BuildSimpleTextResultset([]string{"id", "name", "score"}, values)
+	require.NoError(t, err)
+
+	packets := [][]byte{PutLengthEncodedInt(uint64(len(rs.Fields)))}
+	for _, field := range rs.Fields {
+		packets = append(packets, field.Dump())
+	}
+	packets = append(packets, newTestEOFPacket(SERVER_STATUS_AUTOCOMMIT))
+	for _, row := range rs.RowDatas {
+		packets = append(packets, row)
+	}
+	return append(packets, newTestEOFPacket(SERVER_STATUS_AUTOCOMMIT|SERVER_MORE_RESULTS_EXISTS))
+}
+
+func TestConn_ExecutePassthrough(t *testing.T) {
+	packets := newTestResultsetPackets(t, 2)
+	c := newTestConn(packets...)
+
+	var relayed [][]byte
+	r, err := c.ExecutePassthrough("select * from t", func(data []byte) error {
+		relayed = append(relayed, append([]byte{}, data...))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, packets, relayed)
+	require.Equal(t, uint16(SERVER_STATUS_AUTOCOMMIT|SERVER_MORE_RESULTS_EXISTS), r.Status)
+	require.Nil(t, r.Resultset)
+}
+
+func TestConn_ExecutePassthrough_OK(t *testing.T) {
+	okPacket := []byte{OK_HEADER, 2, 3, byte(SERVER_STATUS_AUTOCOMMIT), 0, 1, 0}
+	c := newTestConn(okPacket)
+
+	var relayed [][]byte
+	r, err := c.ExecutePassthrough("update t set a = 1", func(data []byte) error {
+		relayed = append(relayed, append([]byte{}, data...))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{okPacket}, relayed)
+	require.Equal(t, uint64(2), r.AffectedRows)
+	require.Equal(t, uint64(3), r.InsertId)
+}
+
+func TestConn_ExecutePassthrough_Error(t *testing.T) {
+	errPacket := append([]byte{ERR_HEADER, 0x7a, 0x04, '#'}, "42S02Table 't' doesn't exist"...)
+	c := newTestConn(errPacket)
+
+	_, err := c.ExecutePassthrough("select * from t", func(data []byte) error {
+		require.Fail(t, "ERR packet should not be relayed")
+		return nil
+	})
+	myErr, ok := err.(*MyError)
+	require.True(t, ok)
+	require.Equal(t, uint16(ER_NO_SUCH_TABLE), myErr.Code)
+
+	// the rest packets are not read if the handler fails
+	c = newTestConn(newTestResultsetPackets(t, 2)...)
+	_, err = c.ExecutePassthrough("select * from t", func(data []byte) error {
+		return fmt.Errorf("mock error")
+	})
+	require.Contains(t, err.Error(), ErrBadConn.Error())
+}
+
+func TestConn_ExecutePassthrough_LocalInfile(t *testing.T) {
+	okPacket := []byte{OK_HEADER, 0, 0, byte(SERVER_STATUS_AUTOCOMMIT), 0, 0, 0}
+	localInfile := append([]byte{LocalInFile_HEADER}, "/tmp/t.csv"...)
+	c := newTestConnWithResps(encodeTestPackets(1, localInfile), encodeTestPackets(3, okPacket))
+	_, err := c.ExecutePassthrough("load data local infile '/tmp/t.csv' into table t", func(data []byte) error {
+		require.Fail(t, "local infile request should not be relayed")
+		return nil
+	})
+	// the empty file is sent and the OK packet is read, the conn is not broken
+	require.Equal(t, ErrMalformPacket, err)
+
+	c = newTestConnWithResps(encodeTestPackets(1, localInfile), encodeTestPackets(3, newTestEOFPacket(0)))
+	_, err = c.ExecutePassthrough("load data local infile '/tmp/t.csv' into table t", func(data []byte) error {
+		return nil
+	})
+	require.Contains(t, err.Error(), ErrBadConn.Error())
+}
+
+func TestConn_ExecuteStream_LocalInfile(t *testing.T) {
+	okPacket := []byte{OK_HEADER, 0, 0, byte(SERVER_STATUS_AUTOCOMMIT), 0, 0, 0}
+	localInfile := append([]byte{LocalInFile_HEADER}, "/tmp/t.csv"...)
+	c := newTestConnWithResps(encodeTestPackets(1, localInfile), encodeTestPackets(3, okPacket))
+	_, err := c.ExecuteStream("load data local infile '/tmp/t.csv' into table t", func(columns [][]byte) error {
+		return nil
+	}, func(row []byte) error {
+		return nil
+	})
+	// the empty file is sent and the OK packet is read, the conn is not broken
+	require.Equal(t, ErrMalformPacket, err)
+
+	// the conn is broken if the result of the request can't be read
+	c = newTestConnWithResps(encodeTestPackets(1, localInfile), encodeTestPackets(3, newTestEOFPacket(0)))
+	_, err = c.ExecuteStream("load data local infile '/tmp/t.csv' into table t", func(columns [][]byte) error {
+		return nil
+	}, func(row []byte) error {
+		return nil
+	})
+	require.Contains(t, err.Error(), ErrBadConn.Error())
+}
+
+func TestConn_GetWarnings(t *testing.T) {
+	okPacket := []byte{OK_HEADER, 1, 0, byte(SERVER_STATUS_AUTOCOMMIT), 0, 2, 0}
+	c := newTestConn(okPacket)
+	_, err := c.Execute("insert into t values ('abc')")
+	require.NoError(t, err)
+	require.Equal(t, uint16(2), c.GetWarnings())
+
+	// the warning count is in the last EOF packet of result set
+	packets := newTestResultsetPackets(t, 1)
+	packets[len(packets)-1][1] = 3
+	c = newTestConn(packets...)
+	_, err = c.ExecuteStream("select * from t", func(columns [][]byte) error {
+		return nil
+	}, func(row []byte) error {
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint16(3), c.GetWarnings())
+
+	c.Conn = newTestConn(append([]byte{ERR_HEADER, 0x7a, 0x04, '#'}, "42S02Table 't' doesn't exist"...)).Conn
+	_, err = c.Execute("select * from t")
+	require.Error(t, err)
+	require.Equal(t, uint16(0), c.GetWarnings())
+}
+
+func benchmarkExecute(b *testing.B, rowCount int, execute func(c *Conn) error) {
+	c := newTestConn(newTestResultsetPackets(b, rowCount)...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := execute(c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkConn_Execute reads the result set into Result, which is encoded again before it's sent to client.
+func BenchmarkConn_Execute(b *testing.B) {
+	benchmarkExecute(b, 100, func(c *Conn) error {
+		_, err := c.Execute("select * from t")
+		return err
+	})
+}
+
+func BenchmarkConn_ExecutePassthrough(b *testing.B) {
+	var out []byte
+	benchmarkExecute(b, 100, func(c *Conn) error {
+		_, err := c.ExecutePassthrough("select * from t", func(data []byte) error {
+			out = append(out[:0], data...)
+			return nil
+		})
+		return err
+	})
+}
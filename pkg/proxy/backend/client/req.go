@@ -32,6 +32,18 @@ func (c *Conn) writeCommandBuf(command byte, arg []byte) error {
 	return err
 }
 
+// writeLocalInfileData writes a packet of LOAD DATA LOCAL INFILE content, empty data ends the file.
+func (c *Conn) writeLocalInfileData(data []byte) error {
+	buf := utils.ByteSliceGet(len(data) + 4)
+	copy(buf[4:], data)
+
+	err := c.WritePacket(buf)
+
+	utils.ByteSlicePut(buf)
+
+	return err
+}
+
 func (c *Conn) writeCommandStr(command byte, arg string) error {
 	return c.writeCommandBuf(command, utils.StringToByteSlice(arg))
 }
@@ -52,8 +52,8 @@ func (c *Conn) handleOKPacket(data []byte) (*Result, error) {
 		pos += 2
 
 		//todo:strict_mode, check warnings as error
-		//Warnings := binary.LittleEndian.Uint16(data[pos:])
-		//pos += 2
+		c.warnings = binary.LittleEndian.Uint16(data[pos:])
+		pos += 2
 	} else if c.capability&CLIENT_TRANSACTIONS > 0 {
 		r.Status = binary.LittleEndian.Uint16(data[pos:])
 		c.status = r.Status
@@ -68,6 +68,7 @@ func (c *Conn) handleOKPacket(data []byte) (*Result, error) {
 
 func (c *Conn) handleErrorPacket(data []byte) error {
 	e := new(MyError)
+	c.warnings = 0
 
 	var pos = 1
 
@@ -246,6 +247,11 @@ func (c *Conn) readResultset(data []byte, binary bool) (*Result, error) {
 		return nil, errors.Trace(err)
 	}
 
+	// rows of an opened cursor are read by COM_STMT_FETCH
+	if result.Status&SERVER_STATUS_CURSOR_EXISTS > 0 {
+		return result, nil
+	}
+
 	if err := c.readResultRows(result, binary); err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -268,8 +274,8 @@ func (c *Conn) readResultColumns(result *Result) (err error) {
 		// EOF Packet
 		if c.isEOFPacket(data) {
 			if c.capability&CLIENT_PROTOCOL_41 > 0 {
-				//result.Warnings = binary.LittleEndian.Uint16(data[1:])
 				//todo add strict_mode, warning will be treat as error
+				c.warnings = binary.LittleEndian.Uint16(data[1:])
 				result.Status = binary.LittleEndian.Uint16(data[3:])
 				c.status = result.Status
 			}
@@ -309,8 +315,8 @@ func (c *Conn) readResultRows(result *Result, isBinary bool) (err error) {
 		// EOF Packet
 		if c.isEOFPacket(data) {
 			if c.capability&CLIENT_PROTOCOL_41 > 0 {
-				//result.Warnings = binary.LittleEndian.Uint16(data[1:])
 				//todo add strict_mode, warning will be treat as error
+				c.warnings = binary.LittleEndian.Uint16(data[1:])
 				result.Status = binary.LittleEndian.Uint16(data[3:])
 				c.status = result.Status
 			}
@@ -341,3 +347,126 @@ func (c *Conn) readResultRows(result *Result, isBinary bool) (err error) {
 
 	return nil
 }
+
+// readFetchRows reads rows of COM_STMT_FETCH until EOF packet.
+// If rowHandler fails, the remaining rows are drained to keep the connection usable.
+func (c *Conn) readFetchRows(rowHandler func(row []byte) error) (uint16, error) {
+	var data []byte
+	var err, handlerErr error
+
+	for {
+		data, err = c.ReadPacketReuseMem(data[:0])
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+
+		// EOF Packet
+		if c.isEOFPacket(data) {
+			var status uint16
+			if c.capability&CLIENT_PROTOCOL_41 > 0 {
+				c.warnings = binary.LittleEndian.Uint16(data[1:])
+				status = binary.LittleEndian.Uint16(data[3:])
+				c.status = status
+			}
+			return status, handlerErr
+		}
+
+		if data[0] == ERR_HEADER {
+			return 0, c.handleErrorPacket(data)
+		}
+
+		if handlerErr == nil {
+			handlerErr = rowHandler(data)
+		}
+	}
+}
+
+// readStreamColumns reads column definition packets of a result set until EOF packet.
+func (c *Conn) readStreamColumns(count int) ([][]byte, error) {
+	columns := make([][]byte, 0, count)
+	for {
+		data, err := c.ReadPacket()
+		if err != nil {
+			return nil, err
+		}
+
+		// EOF Packet
+		if c.isEOFPacket(data) {
+			if c.capability&CLIENT_PROTOCOL_41 > 0 {
+				c.warnings = binary.LittleEndian.Uint16(data[1:])
+				c.status = binary.LittleEndian.Uint16(data[3:])
+			}
+			if len(columns) != count {
+				return nil, ErrMalformPacket
+			}
+			return columns, nil
+		}
+
+		columns = append(columns, data)
+	}
+}
+
+// readStreamRows reads rows of a result set until EOF packet, each row is passed to rowHandler.
+// Unlike readFetchRows, the rest rows are not drained if rowHandler fails, since the result set may be huge.
+func (c *Conn) readStreamRows(rowHandler func(row []byte) error) (*Result, error) {
+	var data []byte
+	var err error
+
+	for {
+		data, err = c.ReadPacketReuseMem(data[:0])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		// EOF Packet
+		if c.isEOFPacket(data) {
+			r := new(Result)
+			if c.capability&CLIENT_PROTOCOL_41 > 0 {
+				c.warnings = binary.LittleEndian.Uint16(data[1:])
+				r.Status = binary.LittleEndian.Uint16(data[3:])
+				c.status = r.Status
+			}
+			return r, nil
+		}
+
+		if data[0] == ERR_HEADER {
+			return nil, c.handleErrorPacket(append([]byte{}, data...))
+		}
+
+		if err = rowHandler(data); err != nil {
+			return nil, errors.Wrapf(ErrBadConn, "stream rows failed. err %v", err)
+		}
+	}
+}
+
+// relayUntilEOF passes packets to packetHandler until EOF packet, which is passed too.
+func (c *Conn) relayUntilEOF(packetHandler func(data []byte) error) (*Result, error) {
+	var data []byte
+	var err error
+
+	for {
+		data, err = c.ReadPacketReuseMem(data[:0])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		if data[0] == ERR_HEADER {
+			return nil, c.handleErrorPacket(append([]byte{}, data...))
+		}
+
+		if err = packetHandler(data); err != nil {
+			return nil, errors.Wrapf(ErrBadConn, "relay packet failed. err %v", err)
+		}
+
+		// EOF Packet
+		if c.isEOFPacket(data) {
+			r := new(Result)
+			if c.capability&CLIENT_PROTOCOL_41 > 0 {
+				c.warnings = binary.LittleEndian.Uint16(data[1:])
+				r.Status = binary.LittleEndian.Uint16(data[3:])
+				c.status = r.Status
+			}
+			return r, nil
+		}
+	}
+}
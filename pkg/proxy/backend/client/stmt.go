@@ -234,12 +234,38 @@ func (c *Conn) StmtExecuteForward(data []byte) (*Result, error) {
 	writeData = append(writeData, COM_STMT_EXECUTE)
 	writeData = append(writeData, data...)
 	c.ResetSequence()
-	if err := c.WritePacket(data); err != nil {
+	if err := c.WritePacket(writeData); err != nil {
 		return nil, errors.Trace(err)
 	}
 	return c.readResult(true)
 }
 
+// StmtFetchForward forwards COM_STMT_FETCH to backend and passes each binary row to rowHandler
+// once it is read, so that rows of a cursor are never buffered. It returns status of the last EOF packet.
+func (c *Conn) StmtFetchForward(data []byte, rowHandler func(row []byte) error) (uint16, error) {
+	writeData := make([]byte, 4, len(data)+5)
+	writeData = append(writeData, COM_STMT_FETCH)
+	writeData = append(writeData, data...)
+	c.ResetSequence()
+	if err := c.WritePacket(writeData); err != nil {
+		return 0, errors.Trace(err)
+	}
+	return c.readFetchRows(rowHandler)
+}
+
+// StmtSendLongDataForward forwards COM_STMT_SEND_LONG_DATA to backend, backend doesn't reply to it.
+func (c *Conn) StmtSendLongDataForward(data []byte) error {
+	return errors.Trace(c.writeCommandBuf(COM_STMT_SEND_LONG_DATA, data))
+}
+
+func (c *Conn) StmtReset(stmtId int) error {
+	if err := c.writeCommandUint32(COM_STMT_RESET, uint32(stmtId)); err != nil {
+		return errors.Trace(err)
+	}
+	_, err := c.readOK()
+	return err
+}
+
 func (c *Conn) StmtClosePrepare(stmtId int) error {
 	return c.writeCommandUint32(COM_STMT_CLOSE, uint32(stmtId))
 }
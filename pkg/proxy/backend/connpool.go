@@ -2,8 +2,10 @@ package backend
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tidb-incubator/weir/pkg/proxy/backend/client"
@@ -25,15 +27,21 @@ type ConnPoolConfig struct {
 }
 
 type Config struct {
-	Addr     string
-	UserName string
-	Password string
+	Addr      string
+	UserName  string
+	Password  string
+	TLSConfig *tls.Config // nil if tls is disabled
 }
 
 type ConnPool struct {
 	ns   string
 	cfg  *ConnPoolConfig
 	pool *pool.ResourcePool
+
+	// borrowed holds the conns taken from pool and not released yet,
+	// so that they can be force closed if they are not put back when draining the pool.
+	borrowedLock sync.Mutex
+	borrowed     map[*backendPooledConnWrapper]struct{}
 }
 
 type backendPooledConnWrapper struct {
@@ -41,7 +49,7 @@ type backendPooledConnWrapper struct {
 	ns       string
 	addr     string
 	username string
-	pool     *pool.ResourcePool
+	connPool *ConnPool
 	sysvars  map[string]*ast.VariableAssignment
 }
 
@@ -52,18 +60,19 @@ type noErrorCloseConnWrapper struct {
 
 func NewConnPool(ns string, cfg *ConnPoolConfig) *ConnPool {
 	return &ConnPool{
-		ns:  ns,
-		cfg: cfg,
+		ns:       ns,
+		cfg:      cfg,
+		borrowed: make(map[*backendPooledConnWrapper]struct{}),
 	}
 }
 
-func newConnWrapper(pool *pool.ResourcePool, conn *client.Conn, ns, addr, username string) *backendPooledConnWrapper {
+func newConnWrapper(connPool *ConnPool, conn *client.Conn, ns, addr, username string) *backendPooledConnWrapper {
 	return &backendPooledConnWrapper{
 		Conn:     conn,
 		ns:       ns,
 		addr:     addr,
 		username: username,
-		pool:     pool,
+		connPool: connPool,
 		sysvars:  make(map[string]*ast.VariableAssignment),
 	}
 }
@@ -71,17 +80,21 @@ func newConnWrapper(pool *pool.ResourcePool, conn *client.Conn, ns, addr, userna
 func (c *ConnPool) Init() error {
 	connFactory := func(context.Context) (pool.Resource, error) {
 		// TODO: add connect timeout
-		conn, err := client.Connect(c.cfg.Addr, c.cfg.UserName, c.cfg.Password, "")
+		conn, err := connect(c.cfg.Addr, c.cfg.UserName, c.cfg.Password, c.cfg.TLSConfig)
 		if err != nil {
 			return nil, err
 		}
-		return &noErrorCloseConnWrapper{newConnWrapper(c.pool, conn, c.ns, c.cfg.Addr, c.cfg.UserName)}, nil
+		return &noErrorCloseConnWrapper{newConnWrapper(c, conn, c.ns, c.cfg.Addr, c.cfg.UserName)}, nil
 	}
 
-	c.pool = pool.NewResourcePool(connFactory, c.cfg.Capacity, c.cfg.Capacity, c.cfg.IdleTimeout, 0, nil)
+	c.initPool(connFactory)
 	return nil
 }
 
+func (c *ConnPool) initPool(connFactory pool.Factory) {
+	c.pool = pool.NewResourcePool(connFactory, c.cfg.Capacity, c.cfg.Capacity, c.cfg.IdleTimeout, 0, nil)
+}
+
 func (c *ConnPool) GetConn(ctx context.Context) (driver.PooledBackendConn, error) {
 	rs, err := c.pool.Get(ctx)
 	if err != nil {
@@ -91,42 +104,123 @@ func (c *ConnPool) GetConn(ctx context.Context) (driver.PooledBackendConn, error
 	recordCurrentBackendMetrics(c.ns, c.cfg.Addr, c.pool)
 
 	conn := rs.(*noErrorCloseConnWrapper).backendPooledConnWrapper
-	if err := conn.syncSessionVariables(ctx); err != nil {
+	c.borrow(conn)
+	if err := conn.SyncSessionVariables(ctx); err != nil {
+		// the conn is taken from pool, it must be released so that the pool slot is not leaked
+		if errClose := conn.ErrorClose(); errClose != nil {
+			logutil.BgLogger().Error("close backend conn error", zap.String("addr", c.cfg.Addr), zap.Error(errClose))
+		}
 		return nil, errors.WithMessage(err, "sync sysvar error")
 	}
 
 	return conn, nil
 }
 
+// InUse returns the count of conns which are taken from pool and not put back yet.
+func (c *ConnPool) InUse() int64 {
+	return c.pool.InUse()
+}
+
 func (c *ConnPool) Close() error {
 	c.pool.Close()
 	return nil
 }
 
+// Drain closes the pool like Close, but it waits at most timeout for the conns in use to be put back.
+// The conns still in use after timeout, such as the ones pinned by sessions or attached to long transactions,
+// are force closed, so the sessions get a bad conn error on the next statement and switch to other instances.
+// It returns the count of force closed conns.
+func (c *ConnPool) Drain(timeout time.Duration) int {
+	done := make(chan struct{})
+	go func() {
+		c.pool.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return 0
+	case <-time.After(timeout):
+	}
+
+	forceClosed := 0
+	for _, conn := range c.getBorrowedConns() {
+		if err := conn.ErrorClose(); err != nil {
+			logutil.BgLogger().Warn("force close backend conn error", zap.String("namespace", c.ns),
+				zap.String("addr", c.cfg.Addr), zap.Error(err))
+		}
+		forceClosed++
+	}
+	<-done
+	return forceClosed
+}
+
+func (c *ConnPool) borrow(conn *backendPooledConnWrapper) {
+	c.borrowedLock.Lock()
+	defer c.borrowedLock.Unlock()
+	c.borrowed[conn] = struct{}{}
+}
+
+// release returns false if the conn is already released, which happens
+// when the session closes or puts back a conn that has been force closed by Drain.
+func (c *ConnPool) release(conn *backendPooledConnWrapper) bool {
+	c.borrowedLock.Lock()
+	defer c.borrowedLock.Unlock()
+	if _, ok := c.borrowed[conn]; !ok {
+		return false
+	}
+	delete(c.borrowed, conn)
+	return true
+}
+
+func (c *ConnPool) getBorrowedConns() []*backendPooledConnWrapper {
+	c.borrowedLock.Lock()
+	defer c.borrowedLock.Unlock()
+	conns := make([]*backendPooledConnWrapper, 0, len(c.borrowed))
+	for conn := range c.borrowed {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
 func recordCurrentBackendMetrics(ns, addr string, resourcePool *pool.ResourcePool) {
 	metrics.BackendConnInUseGauge.WithLabelValues(ns, addr).Set(float64(resourcePool.InUse()))
 }
 
 func (cw *backendPooledConnWrapper) PutBack() {
+	if !cw.connPool.release(cw) {
+		return
+	}
 	w := &noErrorCloseConnWrapper{cw}
-	cw.pool.Put(w)
-	recordCurrentBackendMetrics(cw.ns, cw.addr, cw.pool)
+	cw.connPool.pool.Put(w)
+	recordCurrentBackendMetrics(cw.ns, cw.addr, cw.connPool.pool)
 }
 
 func (cw *backendPooledConnWrapper) ErrorClose() error {
-	cw.pool.Put(nil)
-	recordCurrentBackendMetrics(cw.ns, cw.addr, cw.pool)
+	// the conn is force closed by Drain already
+	if !cw.connPool.release(cw) {
+		return nil
+	}
+	cw.connPool.pool.Put(nil)
+	recordCurrentBackendMetrics(cw.ns, cw.addr, cw.connPool.pool)
 	if err := cw.Conn.Close(); err != nil {
 		return errors.WithMessage(err, fmt.Sprintf("close backend conn error, addr: %s, username: %s", cw.addr, cw.username))
 	}
 	return nil
 }
 
+func (cw *backendPooledConnWrapper) GetAddr() string {
+	return cw.addr
+}
+
 func (cw *backendPooledConnWrapper) Close() error {
 	return cw.Conn.Close()
 }
 
-func (cw *backendPooledConnWrapper) syncSessionVariables(ctx context.Context) error {
+// SyncSessionVariables sets the session variables in ctx to the conn and resets the ones absent from ctx.
+// A conn is synced when it's taken from pool, and an attached conn is synced before each statement
+// since the variables may be set after it's attached.
+func (cw *backendPooledConnWrapper) SyncSessionVariables(ctx context.Context) error {
 	sysVars := getSysVarsFromCtx(ctx)
 	varsToSet, varsToRemove := getDiffVariableList(sysVars, cw.sysvars)
 	if len(varsToSet) == 0 && len(varsToRemove) == 0 {
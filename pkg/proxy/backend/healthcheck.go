@@ -0,0 +1,263 @@
+package backend
+
+import (
+	"crypto/tls"
+	"sync"
+	"time"
+
+	"github.com/pingcap/tidb/util/logutil"
+	"github.com/tidb-incubator/weir/pkg/proxy/backend/client"
+	"github.com/tidb-incubator/weir/pkg/proxy/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultHealthCheckInterval         = 3 * time.Second
+	DefaultHealthCheckTimeout          = 2 * time.Second
+	DefaultHealthCheckFailureThreshold = 3
+	DefaultHealthCheckSuccessThreshold = 2
+)
+
+type HealthCheckConfig struct {
+	Interval         time.Duration
+	Timeout          time.Duration
+	ProbeSQL         string // use COM_PING if empty
+	FailureThreshold int
+	SuccessThreshold int
+}
+
+type prober interface {
+	Probe(addr string) error
+	// Release releases resources held for addr, it is called when the instance is removed.
+	Release(addr string)
+	Close()
+}
+
+// HealthChecker probes backend instances periodically.
+// An instance is marked unhealthy after FailureThreshold consecutive failures,
+// and marked healthy again after SuccessThreshold consecutive successes.
+type HealthChecker struct {
+	ns        string
+	cfg       *HealthCheckConfig
+	instances func() []*Instance
+	prober    prober
+
+	// only accessed in check loop
+	counters map[string]*healthCounter // key: addr
+
+	closeCh chan struct{}
+	wg      sync.WaitGroup
+}
+
+type healthCounter struct {
+	failures  int
+	successes int
+}
+
+func NewHealthChecker(ns string, cfg *HealthCheckConfig, instances func() []*Instance, p prober) *HealthChecker {
+	fillDefaultHealthCheckConfig(cfg)
+	return &HealthChecker{
+		ns:        ns,
+		cfg:       cfg,
+		instances: instances,
+		prober:    p,
+		counters:  make(map[string]*healthCounter),
+		closeCh:   make(chan struct{}),
+	}
+}
+
+func fillDefaultHealthCheckConfig(cfg *HealthCheckConfig) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultHealthCheckInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultHealthCheckTimeout
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = DefaultHealthCheckFailureThreshold
+	}
+	if cfg.SuccessThreshold <= 0 {
+		cfg.SuccessThreshold = DefaultHealthCheckSuccessThreshold
+	}
+}
+
+func (h *HealthChecker) Start() {
+	h.wg.Add(1)
+	go h.run()
+}
+
+func (h *HealthChecker) Stop() {
+	close(h.closeCh)
+	h.wg.Wait()
+	h.prober.Close()
+}
+
+func (h *HealthChecker) run() {
+	defer h.wg.Done()
+
+	ticker := time.NewTicker(h.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.closeCh:
+			return
+		case <-ticker.C:
+			h.check()
+		}
+	}
+}
+
+func (h *HealthChecker) check() {
+	instances := h.instances()
+	errs := make([]error, len(instances))
+
+	var wg sync.WaitGroup
+	for i, ins := range instances {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			errs[i] = h.prober.Probe(addr)
+		}(i, ins.Addr())
+	}
+	wg.Wait()
+
+	current := make(map[string]struct{}, len(instances))
+	for i, ins := range instances {
+		current[ins.Addr()] = struct{}{}
+		h.updateHealth(ins, errs[i])
+	}
+
+	for addr := range h.counters {
+		if _, ok := current[addr]; !ok {
+			delete(h.counters, addr)
+			h.prober.Release(addr)
+			metrics.BackendInstanceHealthGauge.DeleteLabelValues(h.ns, addr)
+		}
+	}
+}
+
+func (h *HealthChecker) updateHealth(ins *Instance, probeErr error) {
+	counter, ok := h.counters[ins.Addr()]
+	if !ok {
+		counter = &healthCounter{}
+		h.counters[ins.Addr()] = counter
+	}
+
+	if probeErr != nil {
+		counter.successes = 0
+		counter.failures++
+		if ins.IsHealthy() && counter.failures >= h.cfg.FailureThreshold {
+			ins.setHealthy(false)
+			logutil.BgLogger().Warn("backend instance is unhealthy", zap.String("namespace", h.ns),
+				zap.String("addr", ins.Addr()), zap.Int("failures", counter.failures), zap.Error(probeErr))
+		}
+	} else {
+		counter.failures = 0
+		counter.successes++
+		if !ins.IsHealthy() && counter.successes >= h.cfg.SuccessThreshold {
+			ins.setHealthy(true)
+			logutil.BgLogger().Info("backend instance is healthy again", zap.String("namespace", h.ns),
+				zap.String("addr", ins.Addr()), zap.Int("successes", counter.successes))
+		}
+	}
+
+	healthValue := float64(0)
+	if ins.IsHealthy() {
+		healthValue = 1
+	}
+	metrics.BackendInstanceHealthGauge.WithLabelValues(h.ns, ins.Addr()).Set(healthValue)
+}
+
+// connProber keeps a dedicated conn for each instance, so that probing does not
+// occupy conns in conn pools and is not affected by pool exhausting.
+type connProber struct {
+	username  string
+	password  string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	probeSQL  string
+
+	mu    sync.Mutex
+	conns map[string]*client.Conn // key: addr
+}
+
+func newConnProber(username, password string, tlsConfig *tls.Config, cfg *HealthCheckConfig) *connProber {
+	return &connProber{
+		username:  username,
+		password:  password,
+		tlsConfig: tlsConfig,
+		timeout:   cfg.Timeout,
+		probeSQL:  cfg.ProbeSQL,
+		conns:     make(map[string]*client.Conn),
+	}
+}
+
+func (p *connProber) Probe(addr string) error {
+	conn, err := p.getConn(addr)
+	if err != nil {
+		return err
+	}
+
+	if err = conn.SetDeadline(time.Now().Add(p.timeout)); err == nil {
+		if p.probeSQL == "" {
+			err = conn.Ping()
+		} else {
+			_, err = conn.Execute(p.probeSQL)
+		}
+	}
+
+	if err != nil {
+		p.Release(addr)
+		return err
+	}
+	return nil
+}
+
+func (p *connProber) getConn(addr string) (*client.Conn, error) {
+	p.mu.Lock()
+	conn, ok := p.conns[addr]
+	p.mu.Unlock()
+	if ok {
+		return conn, nil
+	}
+
+	deadline := time.Now().Add(p.timeout)
+	conn, err := connect(addr, p.username, p.password, p.tlsConfig, func(c *client.Conn) {
+		_ = c.SetDeadline(deadline)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.conns[addr] = conn
+	p.mu.Unlock()
+	return conn, nil
+}
+
+func (p *connProber) Release(addr string) {
+	p.mu.Lock()
+	conn, ok := p.conns[addr]
+	delete(p.conns, addr)
+	p.mu.Unlock()
+
+	if ok {
+		if err := conn.Close(); err != nil {
+			logutil.BgLogger().Debug("close probe conn error", zap.String("addr", addr), zap.Error(err))
+		}
+	}
+}
+
+func (p *connProber) Close() {
+	p.mu.Lock()
+	addrs := make([]string, 0, len(p.conns))
+	for addr := range p.conns {
+		addrs = append(addrs, addr)
+	}
+	p.mu.Unlock()
+
+	for _, addr := range addrs {
+		p.Release(addr)
+	}
+}
@@ -0,0 +1,128 @@
+package backend
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errProbe = errors.New("probe error")
+
+type testProber struct {
+	mu       sync.Mutex
+	errs     map[string]error
+	released []string
+}
+
+func newTestProber() *testProber {
+	return &testProber{errs: make(map[string]error)}
+}
+
+func (p *testProber) Probe(addr string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.errs[addr]
+}
+
+func (p *testProber) Release(addr string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.released = append(p.released, addr)
+}
+
+func (p *testProber) Close() {
+}
+
+func (p *testProber) setErr(addr string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.errs[addr] = err
+}
+
+func prepareHealthChecker(instances []*Instance, p prober) *HealthChecker {
+	cfg := &HealthCheckConfig{
+		FailureThreshold: 2,
+		SuccessThreshold: 3,
+	}
+	return NewHealthChecker("test_ns", cfg, func() []*Instance { return instances }, p)
+}
+
+func TestHealthChecker_MarkUnhealthyAndRecover(t *testing.T) {
+	host := "127.0.0.1"
+	instances := prepareInstances(host, []int{4000, 4001})
+	p := newTestProber()
+	h := prepareHealthChecker(instances, p)
+
+	addr := getAddr(host, 4000)
+	p.setErr(addr, errProbe)
+
+	h.check()
+	assert.True(t, instances[0].IsHealthy())
+	h.check()
+	assert.False(t, instances[0].IsHealthy())
+	assert.True(t, instances[1].IsHealthy())
+
+	p.setErr(addr, nil)
+	h.check()
+	h.check()
+	assert.False(t, instances[0].IsHealthy())
+	h.check()
+	assert.True(t, instances[0].IsHealthy())
+}
+
+func TestHealthChecker_FailureCounterReset(t *testing.T) {
+	host := "127.0.0.1"
+	instances := prepareInstances(host, []int{4000})
+	p := newTestProber()
+	h := prepareHealthChecker(instances, p)
+
+	addr := getAddr(host, 4000)
+	for i := 0; i < 3; i++ {
+		p.setErr(addr, errProbe)
+		h.check()
+		p.setErr(addr, nil)
+		h.check()
+	}
+	assert.True(t, instances[0].IsHealthy())
+}
+
+func TestHealthChecker_ReleaseRemovedInstance(t *testing.T) {
+	host := "127.0.0.1"
+	instances := prepareInstances(host, []int{4000, 4001})
+	p := newTestProber()
+	cfg := &HealthCheckConfig{}
+	current := instances
+	h := NewHealthChecker("test_ns", cfg, func() []*Instance { return current }, p)
+
+	h.check()
+	current = instances[:1]
+	h.check()
+	assert.Equal(t, []string{getAddr(host, 4001)}, p.released)
+	assert.Len(t, h.counters, 1)
+}
+
+func TestHealthChecker_DefaultConfig(t *testing.T) {
+	cfg := &HealthCheckConfig{}
+	NewHealthChecker("test_ns", cfg, nil, newTestProber())
+	assert.Equal(t, DefaultHealthCheckInterval, cfg.Interval)
+	assert.Equal(t, DefaultHealthCheckTimeout, cfg.Timeout)
+	assert.Equal(t, DefaultHealthCheckFailureThreshold, cfg.FailureThreshold)
+	assert.Equal(t, DefaultHealthCheckSuccessThreshold, cfg.SuccessThreshold)
+}
+
+func TestSelectableInstances(t *testing.T) {
+	host := "127.0.0.1"
+	instances := prepareInstances(host, []int{4000, 4001})
+	assert.Len(t, selectableInstances(instances), 2)
+
+	instances[0].setHealthy(false)
+	ret := selectableInstances(instances)
+	assert.Len(t, ret, 1)
+	assert.Equal(t, getAddr(host, 4001), ret[0].Addr())
+
+	instances[1].setHealthy(false)
+	assert.Len(t, selectableInstances(instances), 2)
+	assert.Len(t, filterHealthyInstances(instances), 0)
+}
@@ -1,9 +1,107 @@
 package backend
 
+import (
+	"errors"
+
+	"github.com/tidb-incubator/weir/pkg/util/sync2"
+)
+
+type InstanceRole int
+
+const (
+	InstanceRolePrimary InstanceRole = iota
+	InstanceRoleReplica
+)
+
+const (
+	InstanceRoleNamePrimary = "primary"
+	InstanceRoleNameReplica = "replica"
+)
+
+const DefaultInstanceWeight = 1
+
+var ErrInvalidInstanceRole = errors.New("invalid instance role")
+
 type Instance struct {
-	addr string
+	addr      string
+	role      InstanceRole
+	weight    int
+	pool      connCounter
+	unhealthy sync2.AtomicBool // set by health checker
+}
+
+type InstanceStatus struct {
+	Addr    string `json:"addr"`
+	Role    string `json:"role"`
+	Weight  int    `json:"weight"`
+	Healthy bool   `json:"healthy"`
+	InUse   int64  `json:"in_use"`
+}
+
+// connCounter is implemented by ConnPool
+type connCounter interface {
+	InUse() int64
+}
+
+func (r InstanceRole) String() string {
+	if r == InstanceRoleReplica {
+		return InstanceRoleNameReplica
+	}
+	return InstanceRoleNamePrimary
+}
+
+func ParseInstanceRole(name string) (InstanceRole, error) {
+	switch name {
+	case InstanceRoleNamePrimary:
+		return InstanceRolePrimary, nil
+	case InstanceRoleNameReplica:
+		return InstanceRoleReplica, nil
+	default:
+		return InstanceRolePrimary, ErrInvalidInstanceRole
+	}
 }
 
 func (i *Instance) Addr() string {
 	return i.addr
 }
+
+func (i *Instance) Role() InstanceRole {
+	return i.role
+}
+
+func (i *Instance) IsReplica() bool {
+	return i.role == InstanceRoleReplica
+}
+
+func (i *Instance) Weight() int {
+	if i.weight <= 0 {
+		return DefaultInstanceWeight
+	}
+	return i.weight
+}
+
+// InUse returns the in use conn count of the instance's conn pool.
+func (i *Instance) InUse() int64 {
+	if i.pool == nil {
+		return 0
+	}
+	return i.pool.InUse()
+}
+
+func (i *Instance) IsHealthy() bool {
+	return !i.unhealthy.Get()
+}
+
+func (i *Instance) setHealthy(healthy bool) {
+	i.unhealthy.Set(!healthy)
+}
+
+func (i *Instance) Status() InstanceStatus {
+	return InstanceStatus{
+		Addr:    i.addr,
+		Role:    i.role.String(),
+		Weight:  i.Weight(),
+		Healthy: i.IsHealthy(),
+		InUse:   i.InUse(),
+	}
+}
@@ -3,26 +3,40 @@ package backend
 import (
 	"errors"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/tidb-incubator/weir/pkg/util/rand2"
+	"github.com/tidb-incubator/weir/pkg/util/sync2"
 )
 
 const (
 	SelectorTypeRandom = 1 + iota
+	SelectorTypeRoundRobin
+	SelectorTypeWeightedRoundRobin
+	SelectorTypeLeastConn
 )
 
 const (
-	SelectorNameUnknown = "unknown"
-	SelectorNameRandom  = "random"
+	SelectorNameUnknown            = "unknown"
+	SelectorNameRandom             = "random"
+	SelectorNameRoundRobin         = "round_robin"
+	SelectorNameWeightedRoundRobin = "weighted_round_robin"
+	SelectorNameLeastConn          = "least_conn"
 )
 
 var (
 	selectorTypeMap = map[int]string{
-		SelectorTypeRandom: SelectorNameRandom,
+		SelectorTypeRandom:             SelectorNameRandom,
+		SelectorTypeRoundRobin:         SelectorNameRoundRobin,
+		SelectorTypeWeightedRoundRobin: SelectorNameWeightedRoundRobin,
+		SelectorTypeLeastConn:          SelectorNameLeastConn,
 	}
 	selectorNameMap = map[string]int{
-		SelectorNameRandom: SelectorTypeRandom,
+		SelectorNameRandom:             SelectorTypeRandom,
+		SelectorNameRoundRobin:         SelectorTypeRoundRobin,
+		SelectorNameWeightedRoundRobin: SelectorTypeWeightedRoundRobin,
+		SelectorNameLeastConn:          SelectorTypeLeastConn,
 	}
 )
 
@@ -35,16 +49,45 @@ type Selector interface {
 	Select(instances []*Instance) (*Instance, error)
 }
 
+// instanceStateKeeper is implemented by selectors which keep state of instances,
+// the state is dropped when the instance is removed, so that it's not reused if the instance is added again.
+type instanceStateKeeper interface {
+	RemoveInstance(addr string)
+}
+
 type RandomSelector struct {
 	rd *rand2.Rand
 }
 
+type RoundRobinSelector struct {
+	next sync2.AtomicInt64
+}
+
+// WeightedRoundRobinSelector implements the smooth weighted round-robin balancing of nginx,
+// which spreads the picks of heavy instances evenly instead of picking them in a burst.
+type WeightedRoundRobinSelector struct {
+	mu             sync.Mutex
+	currentWeights map[string]int64 // key: addr
+}
+
+// LeastConnSelector selects the instance with the least in use pooled conns.
+// Instances with the same count are selected in turn.
+type LeastConnSelector struct {
+	next sync2.AtomicInt64
+}
+
 func CreateSelector(selectorType int) (Selector, error) {
 	switch selectorType {
 	case SelectorTypeRandom:
 		source := rand.NewSource(time.Now().Unix())
 		rd := rand2.New(source)
 		return NewRandomSelector(rd), nil
+	case SelectorTypeRoundRobin:
+		return NewRoundRobinSelector(), nil
+	case SelectorTypeWeightedRoundRobin:
+		return NewWeightedRoundRobinSelector(), nil
+	case SelectorTypeLeastConn:
+		return NewLeastConnSelector(), nil
 	default:
 		return nil, ErrInvalidSelectorType
 	}
@@ -65,6 +108,85 @@ func (s *RandomSelector) Select(instances []*Instance) (*Instance, error) {
 	return instances[idx], nil
 }
 
+func NewRoundRobinSelector() *RoundRobinSelector {
+	return &RoundRobinSelector{}
+}
+
+func (s *RoundRobinSelector) Select(instances []*Instance) (*Instance, error) {
+	length := len(instances)
+	if length == 0 {
+		return nil, ErrNoInstanceToSelect
+	}
+	idx := (s.next.Add(1) - 1) % int64(length)
+	return instances[idx], nil
+}
+
+func NewWeightedRoundRobinSelector() *WeightedRoundRobinSelector {
+	return &WeightedRoundRobinSelector{
+		currentWeights: make(map[string]int64),
+	}
+}
+
+func (s *WeightedRoundRobinSelector) Select(instances []*Instance) (*Instance, error) {
+	if len(instances) == 0 {
+		return nil, ErrNoInstanceToSelect
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var selected *Instance
+	var totalWeight, maxCurrentWeight int64
+	for _, ins := range instances {
+		weight := int64(ins.Weight())
+		totalWeight += weight
+		currentWeight := s.currentWeights[ins.Addr()] + weight
+		s.currentWeights[ins.Addr()] = currentWeight
+		if selected == nil || currentWeight > maxCurrentWeight {
+			selected = ins
+			maxCurrentWeight = currentWeight
+		}
+	}
+	s.currentWeights[selected.Addr()] -= totalWeight
+	return selected, nil
+}
+
+func (s *WeightedRoundRobinSelector) RemoveInstance(addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.currentWeights, addr)
+}
+
+func NewLeastConnSelector() *LeastConnSelector {
+	return &LeastConnSelector{}
+}
+
+func (s *LeastConnSelector) Select(instances []*Instance) (*Instance, error) {
+	length := len(instances)
+	if length == 0 {
+		return nil, ErrNoInstanceToSelect
+	}
+
+	start := (s.next.Add(1) - 1) % int64(length)
+	var selected *Instance
+	var minInUse int64
+	for i := int64(0); i < int64(length); i++ {
+		ins := instances[(start+i)%int64(length)]
+		inUse := ins.InUse()
+		if selected == nil || inUse < minInUse {
+			selected = ins
+			minInUse = inUse
+		}
+	}
+	return selected, nil
+}
+
+func removeSelectorInstance(selector Selector, addr string) {
+	if keeper, ok := selector.(instanceStateKeeper); ok {
+		keeper.RemoveInstance(addr)
+	}
+}
+
 func SelectorNameToType(name string) (int, bool) {
 	t, ok := selectorNameMap[name]
 	return t, ok
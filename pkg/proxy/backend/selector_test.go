@@ -50,6 +50,151 @@ func TestRandomSelector_Select_ErrNoInstanceToSelect(t *testing.T) {
 	assert.EqualError(t, err, ErrNoInstanceToSelect.Error())
 }
 
+func TestRoundRobinSelector_Select_Success(t *testing.T) {
+	selector := NewRoundRobinSelector()
+
+	host := "127.0.0.1"
+	ports := []int{4000, 4001, 4002}
+	instances := prepareInstances(host, ports)
+
+	for i := 0; i < 2*len(ports); i++ {
+		instance, err := selector.Select(instances)
+		assert.NoError(t, err)
+		assert.Equal(t, getAddr(host, ports[i%len(ports)]), instance.addr)
+	}
+}
+
+func TestRoundRobinSelector_Select_ErrNoInstanceToSelect(t *testing.T) {
+	selector := NewRoundRobinSelector()
+	instance, err := selector.Select(nil)
+	assert.Nil(t, instance)
+	assert.EqualError(t, err, ErrNoInstanceToSelect.Error())
+}
+
+func TestWeightedRoundRobinSelector_Select_Success(t *testing.T) {
+	selector := NewWeightedRoundRobinSelector()
+
+	host := "127.0.0.1"
+	ports := []int{4000, 4001, 4002}
+	instances := prepareInstances(host, ports)
+	instances[0].weight = 5
+	instances[1].weight = 1
+	instances[2].weight = 1
+
+	// smooth weighted round-robin sequence of weights {5, 1, 1}
+	expectedPorts := []int{4000, 4000, 4001, 4000, 4002, 4000, 4000}
+	for round := 0; round < 2; round++ {
+		for _, port := range expectedPorts {
+			instance, err := selector.Select(instances)
+			assert.NoError(t, err)
+			assert.Equal(t, getAddr(host, port), instance.addr)
+		}
+	}
+}
+
+func TestWeightedRoundRobinSelector_Select_DefaultWeight(t *testing.T) {
+	selector := NewWeightedRoundRobinSelector()
+
+	host := "127.0.0.1"
+	ports := []int{4000, 4001}
+	instances := prepareInstances(host, ports)
+
+	counts := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		instance, err := selector.Select(instances)
+		assert.NoError(t, err)
+		counts[instance.addr]++
+	}
+	assert.Equal(t, 5, counts[getAddr(host, 4000)])
+	assert.Equal(t, 5, counts[getAddr(host, 4001)])
+}
+
+func TestWeightedRoundRobinSelector_RemoveInstance(t *testing.T) {
+	selector := NewWeightedRoundRobinSelector()
+
+	host := "127.0.0.1"
+	instances := prepareInstances(host, []int{4000, 4001})
+	instances[0].weight = 2
+	_, err := selector.Select(instances)
+	assert.NoError(t, err)
+	assert.Len(t, selector.currentWeights, 2)
+
+	// the current weight of a removed instance is not reused when it's added again
+	removeSelectorInstance(selector, getAddr(host, 4001))
+	assert.Len(t, selector.currentWeights, 1)
+	_, ok := selector.currentWeights[getAddr(host, 4001)]
+	assert.False(t, ok)
+}
+
+func TestWeightedRoundRobinSelector_Select_ErrNoInstanceToSelect(t *testing.T) {
+	selector := NewWeightedRoundRobinSelector()
+	instance, err := selector.Select(nil)
+	assert.Nil(t, instance)
+	assert.EqualError(t, err, ErrNoInstanceToSelect.Error())
+}
+
+type testConnCounter struct {
+	inUse int64
+}
+
+func (t *testConnCounter) InUse() int64 {
+	return t.inUse
+}
+
+func TestLeastConnSelector_Select_Success(t *testing.T) {
+	selector := NewLeastConnSelector()
+
+	host := "127.0.0.1"
+	ports := []int{4000, 4001, 4002}
+	instances := prepareInstances(host, ports)
+	counters := []*testConnCounter{{inUse: 3}, {inUse: 1}, {inUse: 2}}
+	for i := range instances {
+		instances[i].pool = counters[i]
+	}
+
+	for i := 0; i < len(ports); i++ {
+		instance, err := selector.Select(instances)
+		assert.NoError(t, err)
+		assert.Equal(t, getAddr(host, 4001), instance.addr)
+	}
+
+	counters[1].inUse = 5
+	instance, err := selector.Select(instances)
+	assert.NoError(t, err)
+	assert.Equal(t, getAddr(host, 4002), instance.addr)
+}
+
+func TestLeastConnSelector_Select_Tie(t *testing.T) {
+	selector := NewLeastConnSelector()
+
+	host := "127.0.0.1"
+	ports := []int{4000, 4001, 4002}
+	instances := prepareInstances(host, ports)
+
+	for i := 0; i < len(ports); i++ {
+		instance, err := selector.Select(instances)
+		assert.NoError(t, err)
+		assert.Equal(t, getAddr(host, ports[i]), instance.addr)
+	}
+}
+
+func TestLeastConnSelector_Select_ErrNoInstanceToSelect(t *testing.T) {
+	selector := NewLeastConnSelector()
+	instance, err := selector.Select(nil)
+	assert.Nil(t, instance)
+	assert.EqualError(t, err, ErrNoInstanceToSelect.Error())
+}
+
+func TestCreateSelector(t *testing.T) {
+	for name, tp := range selectorNameMap {
+		selector, err := CreateSelector(tp)
+		assert.NoError(t, err, name)
+		assert.NotNil(t, selector, name)
+	}
+	_, err := CreateSelector(0)
+	assert.EqualError(t, err, ErrInvalidSelectorType.Error())
+}
+
 func prepareInstances(host string, ports []int) []*Instance {
 	var instances []*Instance
 	for _, p := range ports {
@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+
+	"github.com/pingcap/errors"
+	"github.com/tidb-incubator/weir/pkg/proxy/backend/client"
+)
+
+// NewTLSConfig creates tls config for backend connections, system root CAs are used if caPath is empty.
+func NewTLSConfig(caPath, certPath, keyPath, serverName string, insecureSkipVerify bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+
+	if caPath != "" {
+		caData, err := ioutil.ReadFile(caPath)
+		if err != nil {
+			return nil, errors.WithMessage(err, "read ca file error")
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caData) {
+			return nil, errors.Errorf("invalid ca file: %s", caPath)
+		}
+		tlsConfig.RootCAs = certPool
+	}
+
+	if certPath != "" || keyPath != "" {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, errors.WithMessage(err, "load client cert and key error")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// connect connects to backend with tls if tlsConfig is not nil.
+func connect(addr, username, password string, tlsConfig *tls.Config, options ...func(*client.Conn)) (*client.Conn, error) {
+	if tlsConfig != nil {
+		cfg := tlsConfigForAddr(tlsConfig, addr)
+		options = append(options, func(c *client.Conn) {
+			c.SetTLSConfig(cfg)
+		})
+	}
+	return client.Connect(addr, username, password, "", options...)
+}
+
+// tlsConfigForAddr fills ServerName with host of addr if it's not specified,
+// because tls handshake fails without ServerName unless InsecureSkipVerify is set.
+func tlsConfigForAddr(tlsConfig *tls.Config, addr string) *tls.Config {
+	if tlsConfig.ServerName != "" || tlsConfig.InsecureSkipVerify {
+		return tlsConfig
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return tlsConfig
+	}
+	cfg := tlsConfig.Clone()
+	cfg.ServerName = host
+	return cfg
+}
@@ -0,0 +1,84 @@
+package backend
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCertFiles writes a self-signed cert and its key, the cert can also be used as ca.
+func writeTestCertFiles(t *testing.T, dir string) (certPath, keyPath string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tidb"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath = filepath.Join(dir, "cert.pem")
+	keyPath = filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certPath, keyPath
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "weir_backend_tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certPath, keyPath := writeTestCertFiles(t, dir)
+
+	tlsConfig, err := NewTLSConfig("", "", "", "", false)
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig.RootCAs)
+	assert.Empty(t, tlsConfig.Certificates)
+
+	tlsConfig, err = NewTLSConfig(certPath, certPath, keyPath, "tidb", true)
+	assert.NoError(t, err)
+	assert.NotNil(t, tlsConfig.RootCAs)
+	assert.Len(t, tlsConfig.Certificates, 1)
+	assert.Equal(t, "tidb", tlsConfig.ServerName)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+
+	_, err = NewTLSConfig(filepath.Join(dir, "not_exist.pem"), "", "", "", false)
+	assert.Error(t, err)
+	_, err = NewTLSConfig(keyPath, "", "", "", false)
+	assert.Error(t, err)
+	_, err = NewTLSConfig("", certPath, "", "", false)
+	assert.Error(t, err)
+}
+
+func TestTLSConfigForAddr(t *testing.T) {
+	tlsConfig := &tls.Config{}
+	cfg := tlsConfigForAddr(tlsConfig, "tidb-0.tidb:4000")
+	assert.Equal(t, "tidb-0.tidb", cfg.ServerName)
+	assert.Empty(t, tlsConfig.ServerName)
+
+	tlsConfig = &tls.Config{ServerName: "tidb"}
+	assert.Same(t, tlsConfig, tlsConfigForAddr(tlsConfig, "tidb-0.tidb:4000"))
+
+	tlsConfig = &tls.Config{InsecureSkipVerify: true}
+	assert.Same(t, tlsConfig, tlsConfigForAddr(tlsConfig, "tidb-0.tidb:4000"))
+}
@@ -3,3 +3,5 @@ package constant
 const ContextKeyPrefix = "__w_"
 
 const ContextKeySessionVariable = ContextKeyPrefix + "session_sysvars"
+
+const ContextKeyExcludedBackendAddr = ContextKeyPrefix + "excluded_backend_addr"
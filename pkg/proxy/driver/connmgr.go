@@ -3,9 +3,14 @@ package driver
 import (
 	"context"
 	"database/sql/driver"
+	"errors"
+	"fmt"
 	"sync"
 
+	"github.com/tidb-incubator/weir/pkg/proxy/constant"
 	"github.com/tidb-incubator/weir/pkg/proxy/metrics"
+	"github.com/tidb-incubator/weir/pkg/proxy/server"
+	wast "github.com/tidb-incubator/weir/pkg/util/ast"
 	utilerrors "github.com/tidb-incubator/weir/pkg/util/errors"
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/tidb/util/logutil"
@@ -13,6 +18,13 @@ import (
 	"go.uber.org/zap"
 )
 
+var errPreparedStmtNotReleased = errors.New("prepared statements are not released")
+
+// newUnknownStmtError returns the same error as MySQL when stmt id is not prepared in session.
+func newUnknownStmtError(stmtId int, command string) error {
+	return gomysql.NewError(gomysql.ER_UNKNOWN_STMT_HANDLER, fmt.Sprintf("Unknown prepared statement handler (%d) given to %s", stmtId, command))
+}
+
 type BackendConnManager struct {
 	fsm   *FSM
 	state FSMState
@@ -22,16 +34,30 @@ type BackendConnManager struct {
 	mu      sync.Mutex
 	txnConn PooledBackendConn
 
-	// TODO: use stmt id set
-	isPrepared bool
+	// if pinned is true, all the requests are served by pinnedConn, see PinConn
+	pinned     bool
+	pinnedConn *pinnedBackendConn
+	// userVarsLost is set if the pinned conn is closed on error, see pinnedBackendConn.ErrorClose
+	userVarsLost bool
+
+	// ids of statements prepared on txnConn, FSM is in prepare states if it's not empty
+	stmtIDs map[int]struct{}
+
+	// if emulatePrepare is true, prepared statements are kept in emulatedStmts instead,
+	// and FSM never enters prepare states
+	emulatePrepare bool
+	emulatedStmts  map[int]*emulatedStmt
+	lastStmtID     int
 }
 
 func NewBackendConnManager(fsm *FSM, ns Namespace) *BackendConnManager {
 	return &BackendConnManager{
-		fsm:        fsm,
-		state:      stateInitial,
-		ns:         ns,
-		isPrepared: false,
+		fsm:            fsm,
+		state:          stateInitial,
+		ns:             ns,
+		stmtIDs:        make(map[int]struct{}),
+		emulatePrepare: ns.IsPrepareEmulated(),
+		emulatedStmts:  make(map[int]*emulatedStmt),
 	}
 }
 
@@ -87,6 +113,10 @@ func (f *BackendConnManager) StmtPrepare(ctx context.Context, db, sql string) (S
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
+	if f.emulatePrepare {
+		return f.prepareEmulatedStmt(ctx, db, sql)
+	}
+
 	ret, err := f.fsm.Call(ctx, EventStmtPrepare, f, db, sql)
 	if err != nil {
 		return nil, err
@@ -98,6 +128,14 @@ func (f *BackendConnManager) StmtExecuteForward(ctx context.Context, stmtId int,
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
+	if f.emulatePrepare {
+		return f.executeEmulatedStmt(ctx, stmtId, data)
+	}
+
+	if !f.hasStmtID(stmtId) {
+		return nil, newUnknownStmtError(stmtId, "mysqld_stmt_execute")
+	}
+
 	ret, err := f.fsm.Call(ctx, EventStmtForwardData, f, stmtId, data)
 	if err != nil {
 		return nil, err
@@ -108,14 +146,141 @@ func (f *BackendConnManager) StmtExecuteForward(ctx context.Context, stmtId int,
 	return ret.(*gomysql.Result), nil
 }
 
+// StmtFetch forwards COM_STMT_FETCH to the attached conn, rows are passed to rowHandler without buffering.
+func (f *BackendConnManager) StmtFetch(ctx context.Context, stmtId int, data []byte, rowHandler func(row []byte) error) (uint16, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.emulatePrepare {
+		if _, ok := f.emulatedStmts[stmtId]; !ok {
+			return 0, newUnknownStmtError(stmtId, "mysqld_stmt_fetch")
+		}
+		return 0, newNoOpenCursorError(stmtId)
+	}
+
+	if !f.hasStmtID(stmtId) {
+		return 0, newUnknownStmtError(stmtId, "mysqld_stmt_fetch")
+	}
+
+	ret, err := f.fsm.Call(ctx, EventStmtFetch, f, stmtId, data, rowHandler)
+	if err != nil {
+		return 0, err
+	}
+	return ret.(*gomysql.Result).Status, nil
+}
+
+func (f *BackendConnManager) StmtSendLongData(ctx context.Context, stmtId int, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.emulatePrepare {
+		stmt, ok := f.emulatedStmts[stmtId]
+		if !ok {
+			return newUnknownStmtError(stmtId, "mysqld_stmt_send_long_data")
+		}
+		stmt.appendLongData(data)
+		return nil
+	}
+
+	if !f.hasStmtID(stmtId) {
+		return newUnknownStmtError(stmtId, "mysqld_stmt_send_long_data")
+	}
+
+	_, err := f.fsm.Call(ctx, EventStmtSendLongData, f, stmtId, data)
+	return err
+}
+
+func (f *BackendConnManager) StmtReset(ctx context.Context, stmtId int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.emulatePrepare {
+		stmt, ok := f.emulatedStmts[stmtId]
+		if !ok {
+			return newUnknownStmtError(stmtId, "mysqld_stmt_reset")
+		}
+		stmt.resetLongData()
+		return nil
+	}
+
+	if !f.hasStmtID(stmtId) {
+		return newUnknownStmtError(stmtId, "mysqld_stmt_reset")
+	}
+
+	_, err := f.fsm.Call(ctx, EventStmtReset, f, stmtId)
+	return err
+}
+
 func (f *BackendConnManager) StmtClose(ctx context.Context, stmtId int) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	_, err := f.fsm.Call(ctx, EventStmtClose, f, stmtId)
+	if f.emulatePrepare {
+		delete(f.emulatedStmts, stmtId)
+		return nil
+	}
+
+	// MySQL ignores unknown stmt id in COM_STMT_CLOSE
+	if !f.hasStmtID(stmtId) {
+		return nil
+	}
+	event := EventStmtClose
+	if len(f.stmtIDs) == 1 {
+		event = EventStmtCloseLast
+	}
+	_, err := f.fsm.Call(ctx, event, f, stmtId)
 	return err
 }
 
+// SyncSessionVariables syncs the session variables in ctx to a backend conn, so that the values rejected by
+// backend fail the SET statement instead of the following statements. The attached conn is synced if any,
+// otherwise the pinned conn or a conn from pool is synced by getPooledConn.
+func (f *BackendConnManager) SyncSessionVariables(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.txnConn != nil {
+		return f.txnConn.SyncSessionVariables(ctx)
+	}
+	conn, err := f.getPooledConn(ctx)
+	if err != nil {
+		return err
+	}
+	conn.PutBack()
+	return nil
+}
+
+// ResetSession rolls back the open transaction, releases the attached conn and resets state to stateInitial.
+// The attached conn is closed if it holds prepared statements, otherwise it's put back to pool after reset.
+// The pinned conn is always closed.
+func (f *BackendConnManager) ResetSession(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.txnConn != nil {
+		f.releaseAttachedConn(f.resetAttachedConn())
+	}
+	f.unpinConn()
+	f.state = stateInitial
+	f.clearStmts()
+	return nil
+}
+
+func (f *BackendConnManager) resetAttachedConn() error {
+	// prepared statements are released by closing the conn
+	if f.state.IsPrepare() {
+		return errPreparedStmtNotReleased
+	}
+	// rollback even if not in transaction, because statements run with autocommit disabled start a transaction implicitly.
+	if err := f.txnConn.Rollback(); err != nil {
+		return err
+	}
+	if !f.state.IsAutoCommit() {
+		return f.txnConn.SetAutoCommit(true)
+	}
+	return nil
+}
+
 // TODO(eastfisher): is it possible to use FSM to manage close?
 func (f *BackendConnManager) Close() error {
 	f.mu.Lock()
@@ -124,16 +289,42 @@ func (f *BackendConnManager) Close() error {
 	if f.txnConn != nil {
 		errClosePooledBackendConn(f.txnConn, f.ns.Name())
 	}
+	f.unpinConn()
 	f.state = stateInitial
+	f.clearStmts()
 	f.unsetAttachedConn()
 	return nil
 }
 
+// queryWithoutTxn is only called in autocommit mode without attached conn,
+// so read only statements can be routed to replicas safely.
+// Read only statements are also safe to repeat, so they are retried once on conn error,
+// preferably on another backend instance.
 func (f *BackendConnManager) queryWithoutTxn(ctx context.Context, db, sql string) (*gomysql.Result, error) {
+	ret, conn, err := f.queryWithPooledConn(ctx, db, sql)
+	if err == nil || conn == nil || !isConnError(err) || !wast.IsReadOnlyStmtFromCtx(ctx) {
+		return ret, err
+	}
+	// part of the result set has been written to client
+	if stream, ok := getResultsetStream(ctx); ok && stream.started {
+		return ret, err
+	}
+
+	addr := conn.GetAddr()
+	logutil.BgLogger().Warn("retry read only query on backend conn error", zap.String("namespace", f.ns.Name()),
+		zap.String("addr", addr), zap.Error(err))
+	ctx = context.WithValue(ctx, constant.ContextKeyExcludedBackendAddr, addr)
+	ret, _, err = f.queryWithPooledConn(ctx, db, sql)
+	metrics.QueryCtxQueryRetryCounter.WithLabelValues(f.ns.Name(), metrics.RetLabel(err)).Inc()
+	return ret, err
+}
+
+// queryWithPooledConn returns the used conn for retrying, it must not be used any more.
+func (f *BackendConnManager) queryWithPooledConn(ctx context.Context, db, sql string) (*gomysql.Result, PooledBackendConn, error) {
 	var err error
-	conn, err := f.ns.GetPooledConn(ctx)
+	conn, err := f.getPooledConnForQuery(ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	defer func() {
@@ -146,20 +337,99 @@ func (f *BackendConnManager) queryWithoutTxn(ctx context.Context, db, sql string
 		}
 	}()
 
+	recordBackendAddr(ctx, conn)
 	if err = conn.UseDB(db); err != nil {
-		return nil, err
+		return nil, conn, err
 	}
 
 	var ret *gomysql.Result
-	ret, err = conn.Execute(sql)
-	return ret, err
+	ret, err = executeQuery(ctx, conn, sql)
+	return ret, conn, err
+}
+
+func (f *BackendConnManager) getPooledConnForQuery(ctx context.Context) (PooledBackendConn, error) {
+	if wast.IsReadOnlyStmtFromCtx(ctx) && !f.pinned {
+		return f.ns.GetPooledReadConn(ctx)
+	}
+	return f.getPooledConn(ctx)
 }
 
 func (f *BackendConnManager) queryInTxn(ctx context.Context, db, sql string) (*gomysql.Result, error) {
+	recordBackendAddr(ctx, f.txnConn)
+	if err := f.txnConn.SyncSessionVariables(ctx); err != nil {
+		return nil, err
+	}
 	if err := f.txnConn.UseDB(db); err != nil {
 		return nil, err
 	}
-	return f.txnConn.Execute(sql)
+	return executeQuery(ctx, f.txnConn, sql)
+}
+
+const ctxLocalFileHandlerKey = constant.ContextKeyPrefix + "local_file_handler"
+
+// ctxWithLocalFileHandler marks the query as LOAD DATA LOCAL INFILE, whose file is read by handler.
+func ctxWithLocalFileHandler(ctx context.Context, handler server.LocalFileHandler) context.Context {
+	return context.WithValue(ctx, ctxLocalFileHandlerKey, handler)
+}
+
+const ctxResultsetStreamKey = constant.ContextKeyPrefix + "resultset_stream"
+
+// resultsetStream writes the result set of query to client by streamer instead of returning it.
+type resultsetStream struct {
+	streamer server.ResultsetStreamer
+	// status is the session status written to the EOF packet following columns
+	status uint16
+	// passthrough means all the response packets from backend are relayed to client as they are
+	passthrough bool
+	started     bool
+}
+
+func (s *resultsetStream) writeColumns(columns [][]byte) error {
+	s.started = true
+	return s.streamer.WriteColumns(columns, s.status)
+}
+
+func (s *resultsetStream) writePacket(data []byte) error {
+	s.started = true
+	return s.streamer.WritePacket(data)
+}
+
+// end finishes the response after it's written to client, the EOF packet is already relayed in passthrough mode.
+func (s *resultsetStream) end(status uint16) error {
+	if s.passthrough {
+		return s.streamer.Flush()
+	}
+	return s.streamer.WriteEnd(status)
+}
+
+func ctxWithResultsetStream(ctx context.Context, stream *resultsetStream) context.Context {
+	return context.WithValue(ctx, ctxResultsetStreamKey, stream)
+}
+
+func getResultsetStream(ctx context.Context) (*resultsetStream, bool) {
+	stream, ok := ctx.Value(ctxResultsetStreamKey).(*resultsetStream)
+	return stream, ok && stream != nil
+}
+
+func executeQuery(ctx context.Context, conn BackendConn, sql string) (*gomysql.Result, error) {
+	ret, err := doExecuteQuery(ctx, conn, sql)
+	if err == nil {
+		recordBackendWarnings(ctx, conn)
+	}
+	return ret, err
+}
+
+func doExecuteQuery(ctx context.Context, conn BackendConn, sql string) (*gomysql.Result, error) {
+	if handler, ok := ctx.Value(ctxLocalFileHandlerKey).(server.LocalFileHandler); ok {
+		return conn.ExecuteLocalInfile(sql, handler)
+	}
+	if stream, ok := getResultsetStream(ctx); ok {
+		if stream.passthrough {
+			return conn.ExecutePassthrough(sql, stream.writePacket)
+		}
+		return conn.ExecuteStream(sql, stream.writeColumns, stream.streamer.WriteRow)
+	}
+	return conn.Execute(sql)
 }
 
 func (f *BackendConnManager) releaseAttachedConn(err error) {
@@ -171,6 +441,24 @@ func (f *BackendConnManager) releaseAttachedConn(err error) {
 	f.unsetAttachedConn()
 }
 
+func (f *BackendConnManager) hasStmtID(stmtId int) bool {
+	_, ok := f.stmtIDs[stmtId]
+	return ok
+}
+
+func (f *BackendConnManager) addStmtID(stmtId int) {
+	f.stmtIDs[stmtId] = struct{}{}
+}
+
+func (f *BackendConnManager) removeStmtID(stmtId int) {
+	delete(f.stmtIDs, stmtId)
+}
+
+func (f *BackendConnManager) clearStmts() {
+	f.stmtIDs = make(map[int]struct{})
+	f.emulatedStmts = make(map[int]*emulatedStmt)
+}
+
 func (f *BackendConnManager) setAttachedConn(conn PooledBackendConn) {
 	f.txnConn = conn
 	metrics.QueryCtxAttachedConnGauge.WithLabelValues(f.ns.Name()).Inc()
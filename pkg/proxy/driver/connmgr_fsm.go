@@ -43,10 +43,17 @@ const (
 	EventDisableAutoCommit
 	EventEnableAutoCommit
 
-	// currently we does not support stmt_reset
 	EventStmtPrepare
-	EventStmtForwardData // execute, send_long_data
-	EventStmtClose
+	EventStmtForwardData // execute
+	EventStmtClose       // close statement while others are still prepared
+	EventStmtCloseLast   // close the last prepared statement
+	EventStmtFetch
+	EventStmtSendLongData
+	EventStmtReset
+
+	// prepared statements are emulated in proxy, see emulatedStmt
+	EventStmtPrepareEmulated
+	EventStmtExecuteEmulated
 )
 
 var ErrFsmActionNowAllowed = errors.New("fsm action not allowed")
@@ -95,6 +102,10 @@ func errHandler(conn *BackendConnManager, ctx context.Context, args ...interface
 	return nil, ErrFsmActionNowAllowed
 }
 
+func errStmtPrepareHandler(conn *BackendConnManager, ctx context.Context, args ...interface{}) (Stmt, error) {
+	return nil, ErrFsmActionNowAllowed
+}
+
 func NewFSM() *FSM {
 	return &FSM{
 		handlersV2: make(map[FSMState]map[FSMEvent]*FSMHandlerWrapper),
@@ -111,6 +122,12 @@ func (q *FSM) Init() {
 	q.MustRegisterHandler(State0, State4, EventStmtPrepare, false, FSMStmtPrepareHandlerFunc(fsmHandler_NoPrepare_WithAttachedConn_EventStmtPrepare))
 	q.MustRegisterHandler(State0, State0, EventStmtForwardData, true, FSMHandlerFunc(errHandler)) // TODO(eastfisher): test
 	q.MustRegisterHandler(State0, State0, EventStmtClose, true, FSMHandlerFunc(noopHandler))      // TODO(eastfisher): test
+	q.MustRegisterHandler(State0, State0, EventStmtCloseLast, true, FSMHandlerFunc(noopHandler))
+	q.MustRegisterHandler(State0, State0, EventStmtFetch, true, FSMHandlerFunc(errHandler))
+	q.MustRegisterHandler(State0, State0, EventStmtSendLongData, true, FSMHandlerFunc(errHandler))
+	q.MustRegisterHandler(State0, State0, EventStmtReset, true, FSMHandlerFunc(errHandler))
+	q.MustRegisterHandler(State0, State0, EventStmtPrepareEmulated, false, FSMStmtPrepareHandlerFunc(fsmHandler_WithAttachedConn_EventStmtPrepareEmulated))
+	q.MustRegisterHandler(State0, State1, EventStmtExecuteEmulated, false, FSMHandlerFunc(fsmHandler_WithAttachedConn_EventStmtExecuteEmulated))
 
 	q.MustRegisterHandler(State1, State1, EventDisableAutoCommit, true, FSMHandlerFunc(noopHandler))
 	q.MustRegisterHandler(State1, State1, EventBegin, false, FSMHandlerFunc(noopHandler))
@@ -122,6 +139,12 @@ func (q *FSM) Init() {
 	q.MustRegisterHandler(State1, State5, EventStmtPrepare, false, FSMStmtPrepareHandlerFunc(fsmHandler_NoPrepare_WithAttachedConn_EventStmtPrepare))
 	q.MustRegisterHandler(State1, State1, EventStmtForwardData, true, FSMHandlerFunc(errHandler)) // TODO(eastfisher): test // ERROR 1243 (HY000): Unknown prepared statement handler (10) given to mysqld_stmt_execute
 	q.MustRegisterHandler(State1, State1, EventStmtClose, true, FSMHandlerFunc(noopHandler))
+	q.MustRegisterHandler(State1, State1, EventStmtCloseLast, true, FSMHandlerFunc(noopHandler))
+	q.MustRegisterHandler(State1, State1, EventStmtFetch, true, FSMHandlerFunc(errHandler))
+	q.MustRegisterHandler(State1, State1, EventStmtSendLongData, true, FSMHandlerFunc(errHandler))
+	q.MustRegisterHandler(State1, State1, EventStmtReset, true, FSMHandlerFunc(errHandler))
+	q.MustRegisterHandler(State1, State1, EventStmtPrepareEmulated, false, FSMStmtPrepareHandlerFunc(fsmHandler_WithAttachedConn_EventStmtPrepareEmulated))
+	q.MustRegisterHandler(State1, State1, EventStmtExecuteEmulated, false, FSMHandlerFunc(fsmHandler_WithAttachedConn_EventStmtExecuteEmulated))
 
 	q.MustRegisterHandler(State2, State2, EventEnableAutoCommit, false, FSMHandlerFunc(noopHandler))
 	q.MustRegisterHandler(State2, State2, EventCommitOrRollback, false, FSMHandlerFunc(noopHandler))
@@ -131,6 +154,12 @@ func (q *FSM) Init() {
 	q.MustRegisterHandler(State2, State6, EventStmtPrepare, false, FSMStmtPrepareHandlerFunc(fsmHandler_NoPrepare_PreFetchConn_EventStmtPrepare))
 	q.MustRegisterHandler(State2, State2, EventStmtForwardData, true, FSMHandlerFunc(errHandler)) // TODO(eastfisher): test
 	q.MustRegisterHandler(State2, State2, EventStmtClose, true, FSMHandlerFunc(noopHandler))
+	q.MustRegisterHandler(State2, State2, EventStmtCloseLast, true, FSMHandlerFunc(noopHandler))
+	q.MustRegisterHandler(State2, State2, EventStmtFetch, true, FSMHandlerFunc(errHandler))
+	q.MustRegisterHandler(State2, State2, EventStmtSendLongData, true, FSMHandlerFunc(errHandler))
+	q.MustRegisterHandler(State2, State2, EventStmtReset, true, FSMHandlerFunc(errHandler))
+	q.MustRegisterHandler(State2, State2, EventStmtPrepareEmulated, false, FSMStmtPrepareHandlerFunc(fsmHandler_ConnPool_EventStmtPrepareEmulated))
+	q.MustRegisterHandler(State2, State2, EventStmtExecuteEmulated, false, FSMHandlerFunc(fsmHandler_ConnPool_EventStmtExecuteEmulated))
 
 	q.MustRegisterHandler(State3, State3, EventEnableAutoCommit, false, FSMHandlerFunc(noopHandler))
 	q.MustRegisterHandler(State3, State3, EventBegin, false, FSMHandlerFunc(noopHandler))
@@ -140,11 +169,22 @@ func (q *FSM) Init() {
 	q.MustRegisterHandler(State3, State7, EventStmtPrepare, false, FSMStmtPrepareHandlerFunc(fsmHandler_NoPrepare_WithAttachedConn_EventStmtPrepare))
 	q.MustRegisterHandler(State3, State3, EventStmtForwardData, true, FSMHandlerFunc(errHandler)) // TODO(eastfisher): test
 	q.MustRegisterHandler(State3, State3, EventStmtClose, true, FSMHandlerFunc(noopHandler))
+	q.MustRegisterHandler(State3, State3, EventStmtCloseLast, true, FSMHandlerFunc(noopHandler))
+	q.MustRegisterHandler(State3, State3, EventStmtFetch, true, FSMHandlerFunc(errHandler))
+	q.MustRegisterHandler(State3, State3, EventStmtSendLongData, true, FSMHandlerFunc(errHandler))
+	q.MustRegisterHandler(State3, State3, EventStmtReset, true, FSMHandlerFunc(errHandler))
+	q.MustRegisterHandler(State3, State3, EventStmtPrepareEmulated, false, FSMStmtPrepareHandlerFunc(fsmHandler_WithAttachedConn_EventStmtPrepareEmulated))
+	q.MustRegisterHandler(State3, State3, EventStmtExecuteEmulated, false, FSMHandlerFunc(fsmHandler_WithAttachedConn_EventStmtExecuteEmulated))
 
 	q.MustRegisterHandler(State4, State4, EventStmtPrepare, true, FSMStmtPrepareHandlerFunc(fsmHandler_IsPrepare_EventStmtPrepare))
 	q.MustRegisterHandler(State4, State5, EventStmtForwardData, false, FSMHandlerFunc(fsmHandler_IsPrepare_EventStmtForwardData))
-	q.MustRegisterHandler(State4, State0, EventStmtClose, true, FSMHandlerFunc(fsmHandler_NotReleaseConn_EventStmtClose))
-	//q.MustRegisterHandler(State4, State4, EventStmtClose, true, nil)  // FIXME(eastfisher): stmt close success may change to State4 or State0
+	q.MustRegisterHandler(State4, State4, EventStmtClose, true, FSMHandlerFunc(fsmHandler_NotReleaseConn_EventStmtClose))
+	q.MustRegisterHandler(State4, State0, EventStmtCloseLast, true, FSMHandlerFunc(fsmHandler_NotReleaseConn_EventStmtClose))
+	q.MustRegisterHandler(State4, State4, EventStmtFetch, true, FSMHandlerFunc(fsmHandler_IsPrepare_EventStmtFetch))
+	q.MustRegisterHandler(State4, State4, EventStmtSendLongData, true, FSMHandlerFunc(fsmHandler_IsPrepare_EventStmtSendLongData))
+	q.MustRegisterHandler(State4, State4, EventStmtReset, true, FSMHandlerFunc(fsmHandler_IsPrepare_EventStmtReset))
+	q.MustRegisterHandler(State4, State4, EventStmtPrepareEmulated, true, FSMStmtPrepareHandlerFunc(errStmtPrepareHandler))
+	q.MustRegisterHandler(State4, State4, EventStmtExecuteEmulated, true, FSMHandlerFunc(errHandler))
 	q.MustRegisterHandler(State4, State5, EventBegin, false, FSMHandlerFunc(fsmHandler_WithAttachedConn_EventBegin))
 	q.MustRegisterHandler(State4, State4, EventCommitOrRollback, true, FSMHandlerFunc(noopHandler))
 	q.MustRegisterHandler(State4, State4, EventDisableAutoCommit, true, FSMHandlerFunc(noopHandler))
@@ -153,7 +193,13 @@ func (q *FSM) Init() {
 
 	q.MustRegisterHandler(State5, State5, EventStmtPrepare, true, FSMStmtPrepareHandlerFunc(fsmHandler_IsPrepare_EventStmtPrepare))
 	q.MustRegisterHandler(State5, State5, EventStmtForwardData, true, FSMHandlerFunc(fsmHandler_IsPrepare_EventStmtForwardData))
-	q.MustRegisterHandler(State5, State1, EventStmtClose, true, FSMHandlerFunc(fsmHandler_NotReleaseConn_EventStmtClose))
+	q.MustRegisterHandler(State5, State5, EventStmtClose, true, FSMHandlerFunc(fsmHandler_NotReleaseConn_EventStmtClose))
+	q.MustRegisterHandler(State5, State1, EventStmtCloseLast, true, FSMHandlerFunc(fsmHandler_NotReleaseConn_EventStmtClose))
+	q.MustRegisterHandler(State5, State5, EventStmtFetch, true, FSMHandlerFunc(fsmHandler_IsPrepare_EventStmtFetch))
+	q.MustRegisterHandler(State5, State5, EventStmtSendLongData, true, FSMHandlerFunc(fsmHandler_IsPrepare_EventStmtSendLongData))
+	q.MustRegisterHandler(State5, State5, EventStmtReset, true, FSMHandlerFunc(fsmHandler_IsPrepare_EventStmtReset))
+	q.MustRegisterHandler(State5, State5, EventStmtPrepareEmulated, true, FSMStmtPrepareHandlerFunc(errStmtPrepareHandler))
+	q.MustRegisterHandler(State5, State5, EventStmtExecuteEmulated, true, FSMHandlerFunc(errHandler))
 	q.MustRegisterHandler(State5, State5, EventBegin, true, FSMHandlerFunc(noopHandler))
 	q.MustRegisterHandler(State5, State4, EventCommitOrRollback, true, FSMHandlerFunc(fsmHandler_NotReleaseConn_EventCommitOrRollback))
 	q.MustRegisterHandler(State5, State5, EventDisableAutoCommit, true, FSMHandlerFunc(noopHandler))
@@ -162,7 +208,13 @@ func (q *FSM) Init() {
 
 	q.MustRegisterHandler(State6, State6, EventStmtPrepare, true, FSMStmtPrepareHandlerFunc(fsmHandler_IsPrepare_EventStmtPrepare))
 	q.MustRegisterHandler(State6, State6, EventStmtForwardData, true, FSMHandlerFunc(fsmHandler_IsPrepare_EventStmtForwardData))
-	q.MustRegisterHandler(State6, State2, EventStmtClose, true, FSMHandlerFunc(fsmHandler_ReleaseConn_EventStmtClose))
+	q.MustRegisterHandler(State6, State6, EventStmtClose, true, FSMHandlerFunc(fsmHandler_NotReleaseConn_EventStmtClose))
+	q.MustRegisterHandler(State6, State2, EventStmtCloseLast, true, FSMHandlerFunc(fsmHandler_ReleaseConn_EventStmtClose))
+	q.MustRegisterHandler(State6, State6, EventStmtFetch, true, FSMHandlerFunc(fsmHandler_IsPrepare_EventStmtFetch))
+	q.MustRegisterHandler(State6, State6, EventStmtSendLongData, true, FSMHandlerFunc(fsmHandler_IsPrepare_EventStmtSendLongData))
+	q.MustRegisterHandler(State6, State6, EventStmtReset, true, FSMHandlerFunc(fsmHandler_IsPrepare_EventStmtReset))
+	q.MustRegisterHandler(State6, State6, EventStmtPrepareEmulated, true, FSMStmtPrepareHandlerFunc(errStmtPrepareHandler))
+	q.MustRegisterHandler(State6, State6, EventStmtExecuteEmulated, true, FSMHandlerFunc(errHandler))
 	q.MustRegisterHandler(State6, State7, EventBegin, false, FSMHandlerFunc(fsmHandler_WithAttachedConn_EventBegin))
 	q.MustRegisterHandler(State6, State6, EventCommitOrRollback, true, FSMHandlerFunc(noopHandler))
 	q.MustRegisterHandler(State6, State4, EventDisableAutoCommit, false, FSMHandlerFunc(fsmHandler_NotReleaseConn_EventDisableAutoCommit))
@@ -171,7 +223,13 @@ func (q *FSM) Init() {
 
 	q.MustRegisterHandler(State7, State7, EventStmtPrepare, true, FSMStmtPrepareHandlerFunc(fsmHandler_IsPrepare_EventStmtPrepare))
 	q.MustRegisterHandler(State7, State7, EventStmtForwardData, true, FSMHandlerFunc(fsmHandler_IsPrepare_EventStmtForwardData))
-	q.MustRegisterHandler(State7, State3, EventStmtClose, true, FSMHandlerFunc(fsmHandler_NotReleaseConn_EventStmtClose))
+	q.MustRegisterHandler(State7, State7, EventStmtClose, true, FSMHandlerFunc(fsmHandler_NotReleaseConn_EventStmtClose))
+	q.MustRegisterHandler(State7, State3, EventStmtCloseLast, true, FSMHandlerFunc(fsmHandler_NotReleaseConn_EventStmtClose))
+	q.MustRegisterHandler(State7, State7, EventStmtFetch, true, FSMHandlerFunc(fsmHandler_IsPrepare_EventStmtFetch))
+	q.MustRegisterHandler(State7, State7, EventStmtSendLongData, true, FSMHandlerFunc(fsmHandler_IsPrepare_EventStmtSendLongData))
+	q.MustRegisterHandler(State7, State7, EventStmtReset, true, FSMHandlerFunc(fsmHandler_IsPrepare_EventStmtReset))
+	q.MustRegisterHandler(State7, State7, EventStmtPrepareEmulated, true, FSMStmtPrepareHandlerFunc(errStmtPrepareHandler))
+	q.MustRegisterHandler(State7, State7, EventStmtExecuteEmulated, true, FSMHandlerFunc(errHandler))
 	q.MustRegisterHandler(State7, State7, EventBegin, true, FSMHandlerFunc(noopHandler))
 	q.MustRegisterHandler(State7, State6, EventCommitOrRollback, true, FSMHandlerFunc(fsmHandler_NotReleaseConn_EventCommitOrRollback))
 	q.MustRegisterHandler(State7, State5, EventDisableAutoCommit, false, FSMHandlerFunc(fsmHandler_NotReleaseConn_EventDisableAutoCommit))
@@ -182,7 +240,79 @@ func (q *FSM) Init() {
 func fsmHandler_IsPrepare_EventStmtForwardData(b *BackendConnManager, ctx context.Context, args ...interface{}) (*mysql.Result, error) {
 	_ = args[0].(int) // stmtId
 	data := args[1].([]byte)
-	return b.txnConn.StmtExecuteForward(data)
+	if err := b.txnConn.SyncSessionVariables(ctx); err != nil {
+		return nil, err
+	}
+	ret, err := b.txnConn.StmtExecuteForward(data)
+	if err != nil {
+		return nil, err
+	}
+	recordBackendWarnings(ctx, b.txnConn)
+	return ret, nil
+}
+
+// fetching rows from an opened cursor doesn't change state, only status of the last EOF packet is returned.
+func fsmHandler_IsPrepare_EventStmtFetch(b *BackendConnManager, ctx context.Context, args ...interface{}) (*mysql.Result, error) {
+	_ = args[0].(int) // stmtId
+	data := args[1].([]byte)
+	rowHandler := args[2].(func([]byte) error)
+	status, err := b.txnConn.StmtFetchForward(data, rowHandler)
+	if err != nil {
+		return nil, err
+	}
+	return &mysql.Result{Status: status}, nil
+}
+
+func fsmHandler_IsPrepare_EventStmtSendLongData(b *BackendConnManager, ctx context.Context, args ...interface{}) (*mysql.Result, error) {
+	_ = args[0].(int) // stmtId
+	data := args[1].([]byte)
+	return nil, b.txnConn.StmtSendLongDataForward(data)
+}
+
+func fsmHandler_IsPrepare_EventStmtReset(b *BackendConnManager, ctx context.Context, args ...interface{}) (*mysql.Result, error) {
+	stmtId := args[0].(int)
+	return nil, b.txnConn.StmtReset(stmtId)
+}
+
+func fsmHandler_WithAttachedConn_EventStmtPrepareEmulated(b *BackendConnManager, ctx context.Context, args ...interface{}) (Stmt, error) {
+	db := args[0].(string)
+	sql := args[1].(string)
+	return prepareStmtMeta(b.txnConn, db, sql)
+}
+
+func fsmHandler_ConnPool_EventStmtPrepareEmulated(b *BackendConnManager, ctx context.Context, args ...interface{}) (Stmt, error) {
+	db := args[0].(string)
+	sql := args[1].(string)
+
+	var stmt Stmt
+	err := b.withPooledConn(ctx, func(conn PooledBackendConn) (err error) {
+		stmt, err = prepareStmtMeta(conn, db, sql)
+		return err
+	})
+	return stmt, err
+}
+
+func fsmHandler_WithAttachedConn_EventStmtExecuteEmulated(b *BackendConnManager, ctx context.Context, args ...interface{}) (*mysql.Result, error) {
+	stmt := args[0].(*emulatedStmt)
+	data := args[1].([]byte)
+	recordBackendAddr(ctx, b.txnConn)
+	if err := b.txnConn.SyncSessionVariables(ctx); err != nil {
+		return nil, err
+	}
+	return stmt.execute(ctx, b.txnConn, data)
+}
+
+func fsmHandler_ConnPool_EventStmtExecuteEmulated(b *BackendConnManager, ctx context.Context, args ...interface{}) (*mysql.Result, error) {
+	stmt := args[0].(*emulatedStmt)
+	data := args[1].([]byte)
+
+	var ret *mysql.Result
+	err := b.withPooledConn(ctx, func(conn PooledBackendConn) (err error) {
+		recordBackendAddr(ctx, conn)
+		ret, err = stmt.execute(ctx, conn, data)
+		return err
+	})
+	return ret, err
 }
 
 func (q *FSM) MustRegisterHandler(state FSMState, newState FSMState, event FSMEvent, mustChangeState bool, handler FSMHandler) {
@@ -265,7 +395,7 @@ func fsmHandler_ConnPool_EventQuery(b *BackendConnManager, ctx context.Context,
 }
 
 func fsmHandler_PreFetchConn_EventDisableAutoCommit(b *BackendConnManager, ctx context.Context, args ...interface{}) (*mysql.Result, error) {
-	conn, err := b.ns.GetPooledConn(ctx)
+	conn, err := b.getPooledConn(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -279,7 +409,7 @@ func fsmHandler_PreFetchConn_EventDisableAutoCommit(b *BackendConnManager, ctx c
 }
 
 func fsmHandler_PreFetchConn_EventBegin(b *BackendConnManager, ctx context.Context, args ...interface{}) (*mysql.Result, error) {
-	conn, err := b.ns.GetPooledConn(ctx)
+	conn, err := b.getPooledConn(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -318,17 +448,11 @@ func fsmHandler_PostReleaseConn_EventCommitOrRollback(b *BackendConnManager, ctx
 
 // TODO(eastfisher): currently we don't change db
 func fsmHandler_NoPrepare_WithAttachedConn_EventStmtPrepare(b *BackendConnManager, ctx context.Context, args ...interface{}) (Stmt, error) {
-	stmt, err := fsmHandler_IsPrepare_EventStmtPrepare(b, ctx, args...)
-	if err != nil {
-		return nil, err
-	}
-
-	b.isPrepared = true
-	return stmt, nil
+	return fsmHandler_IsPrepare_EventStmtPrepare(b, ctx, args...)
 }
 
 func fsmHandler_NoPrepare_PreFetchConn_EventStmtPrepare(b *BackendConnManager, ctx context.Context, args ...interface{}) (Stmt, error) {
-	conn, err := b.ns.GetPooledConn(ctx)
+	conn, err := b.getPooledConn(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -348,7 +472,7 @@ func fsmHandler_NoPrepare_PreFetchConn_EventStmtPrepare(b *BackendConnManager, c
 	}
 
 	b.setAttachedConn(conn)
-	b.isPrepared = true
+	b.addStmtID(stmt.ID())
 	return stmt, nil
 }
 
@@ -365,6 +489,7 @@ func fsmHandler_IsPrepare_EventStmtPrepare(b *BackendConnManager, ctx context.Co
 		return nil, err
 	}
 
+	b.addStmtID(stmt.ID())
 	return stmt, nil
 }
 
@@ -372,15 +497,15 @@ func fsmHandler_NotReleaseConn_EventStmtClose(b *BackendConnManager, ctx context
 	stmtId := args[0].(int)
 	err := b.txnConn.StmtClosePrepare(stmtId)
 
-	b.isPrepared = false
+	b.removeStmtID(stmtId)
 	return nil, err
 }
 
 func fsmHandler_ReleaseConn_EventStmtClose(b *BackendConnManager, ctx context.Context, args ...interface{}) (*mysql.Result, error) {
 	stmtId := args[0].(int)
 	err := b.txnConn.StmtClosePrepare(stmtId)
+	b.removeStmtID(stmtId)
 	b.releaseAttachedConn(err)
-	b.isPrepared = false
 	return nil, err
 }
 
@@ -0,0 +1,94 @@
+package driver
+
+import (
+	"context"
+
+	"github.com/pingcap/tidb/util/logutil"
+	"go.uber.org/zap"
+)
+
+// pinnedBackendConn is a pooled conn which serves the session exclusively,
+// it's held by BackendConnManager instead of being put back to pool.
+type pinnedBackendConn struct {
+	PooledBackendConn
+	mgr *BackendConnManager
+}
+
+func (c *pinnedBackendConn) PutBack() {}
+
+// ErrorClose closes the underlying conn, the next request of session is served by a newly pinned conn.
+// The user variables set on the closed conn are lost, so the next statement using user variables
+// fails if it reads them, see TakeUserVarsLost.
+func (c *pinnedBackendConn) ErrorClose() error {
+	if c.mgr.pinnedConn == c {
+		c.mgr.pinnedConn = nil
+		c.mgr.userVarsLost = true
+	}
+	return c.PooledBackendConn.ErrorClose()
+}
+
+// PinConn makes all the following requests of the session served by the same backend conn,
+// which is required once the session relies on state that can't be synced between backend conns,
+// such as user variables. The attached conn is pinned if any, otherwise a conn is fetched from pool.
+func (f *BackendConnManager) PinConn(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.pinned {
+		return nil
+	}
+	f.pinned = true
+	if f.txnConn != nil {
+		f.pinnedConn = &pinnedBackendConn{PooledBackendConn: f.txnConn, mgr: f}
+		f.txnConn = f.pinnedConn
+		return nil
+	}
+	_, err := f.getPooledConn(ctx)
+	return err
+}
+
+func (f *BackendConnManager) IsConnPinned() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pinned
+}
+
+// TakeUserVarsLost returns true if the user variables are lost since the last call, and resets it.
+func (f *BackendConnManager) TakeUserVarsLost() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	lost := f.userVarsLost
+	f.userVarsLost = false
+	return lost
+}
+
+// getPooledConn returns the pinned conn if session is pinned, otherwise a conn from pool.
+// The pinned conn is synced before each use like the conns taken from pool, since session variables
+// may be changed after it's pinned.
+func (f *BackendConnManager) getPooledConn(ctx context.Context) (PooledBackendConn, error) {
+	if f.pinnedConn != nil {
+		if err := f.pinnedConn.SyncSessionVariables(ctx); err != nil {
+			return nil, err
+		}
+		return f.pinnedConn, nil
+	}
+	conn, err := f.ns.GetPooledConn(ctx)
+	if err != nil || !f.pinned {
+		return conn, err
+	}
+	if f.userVarsLost {
+		logutil.BgLogger().Warn("pin a new backend conn since the pinned one is closed on error, user variables are lost",
+			zap.String("namespace", f.ns.Name()), zap.String("addr", conn.GetAddr()))
+	}
+	f.pinnedConn = &pinnedBackendConn{PooledBackendConn: conn, mgr: f}
+	return f.pinnedConn, nil
+}
+
+// unpinConn closes the pinned conn rather than putting it back, so that user variables are not leaked to other sessions.
+func (f *BackendConnManager) unpinConn() {
+	if f.pinnedConn != nil {
+		errClosePooledBackendConn(f.pinnedConn, f.ns.Name())
+	}
+	f.pinned = false
+	f.userVarsLost = false
+}
@@ -0,0 +1,134 @@
+package driver
+
+import (
+	"context"
+
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	wast "github.com/tidb-incubator/weir/pkg/util/ast"
+)
+
+func (b *BackendConnManagerTestSuite) Test_State2_PinConn_Query() {
+	ctx := context.Background()
+	b.mockNs.On("GetPooledConn", mock.Anything).Return(b.mockConn, nil).Once()
+	b.mockConn.On("Execute", testSQL).Return(queryResult, nil)
+
+	require.NoError(b.T(), b.mockMgr.PinConn(ctx))
+	require.True(b.T(), b.mockMgr.IsConnPinned())
+
+	// read only statements are not routed to replicas once pinned
+	for _, ctx := range []context.Context{ctx, wast.CtxWithReadOnlyStmt(ctx, true)} {
+		ret, err := b.mockMgr.Query(ctx, testDB, testSQL)
+		require.NoError(b.T(), err)
+		require.Equal(b.T(), queryResult, ret)
+	}
+
+	b.mockNs.AssertNumberOfCalls(b.T(), "GetPooledConn", 1)
+	b.mockNs.AssertNotCalled(b.T(), "GetPooledReadConn", mock.Anything)
+	b.mockConn.AssertNotCalled(b.T(), "PutBack")
+	require.Equal(b.T(), stateInitial, b.mockMgr.state)
+	require.Nil(b.T(), b.mockMgr.txnConn)
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_PinConn_Txn() {
+	ctx := context.Background()
+	b.mockNs.On("GetPooledConn", mock.Anything).Return(b.mockConn, nil).Once()
+	b.mockConn.On("Begin").Return(nil).Once()
+	b.mockConn.On("Commit").Return(nil).Once()
+
+	require.NoError(b.T(), b.mockMgr.PinConn(ctx))
+	require.NoError(b.T(), b.mockMgr.Begin(ctx))
+	require.Equal(b.T(), b.mockMgr.pinnedConn, b.mockMgr.txnConn)
+	require.NoError(b.T(), b.mockMgr.CommitOrRollback(ctx, true))
+
+	b.mockConn.AssertNotCalled(b.T(), "PutBack")
+	require.NotNil(b.T(), b.mockMgr.pinnedConn)
+	require.Nil(b.T(), b.mockMgr.txnConn)
+}
+
+func (b *BackendConnManagerTestSuite) Test_State1_PinConn_AttachedConn() {
+	ctx := context.Background()
+	b.prepareConnMgrStatus(State1)
+	b.mockConn.On("Commit").Return(nil).Once()
+
+	require.NoError(b.T(), b.mockMgr.PinConn(ctx))
+	require.Equal(b.T(), b.mockMgr.pinnedConn, b.mockMgr.txnConn)
+	require.NoError(b.T(), b.mockMgr.CommitOrRollback(ctx, true))
+
+	b.mockNs.AssertNotCalled(b.T(), "GetPooledConn", mock.Anything)
+	b.mockConn.AssertNotCalled(b.T(), "PutBack")
+	require.Equal(b.T(), b.mockConn, b.mockMgr.pinnedConn.PooledBackendConn)
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_PinConn_ConnError() {
+	ctx := context.Background()
+	otherConn := new(MockPooledBackendConn)
+	otherConn.On("UseDB", testDB).Return(nil)
+	otherConn.On("GetAddr").Return("127.0.0.1:4001")
+	otherConn.On("Execute", testSQL).Return(queryResult, nil)
+	b.mockNs.On("GetPooledConn", mock.Anything).Return(b.mockConn, nil).Once()
+	b.mockNs.On("GetPooledConn", mock.Anything).Return(otherConn, nil).Once()
+	b.mockConn.On("Execute", testSQL).Return(nil, gomysql.ErrBadConn).Once()
+	b.mockConn.On("ErrorClose").Return(nil).Once()
+
+	require.NoError(b.T(), b.mockMgr.PinConn(ctx))
+	_, err := b.mockMgr.Query(ctx, testDB, testSQL)
+	require.Equal(b.T(), gomysql.ErrBadConn, err)
+	require.Nil(b.T(), b.mockMgr.pinnedConn)
+	require.True(b.T(), b.mockMgr.TakeUserVarsLost())
+	require.False(b.T(), b.mockMgr.TakeUserVarsLost())
+
+	// the session is pinned to another conn
+	_, err = b.mockMgr.Query(ctx, testDB, testSQL)
+	require.NoError(b.T(), err)
+	require.True(b.T(), b.mockMgr.IsConnPinned())
+	require.Equal(b.T(), otherConn, b.mockMgr.pinnedConn.PooledBackendConn)
+	otherConn.AssertNotCalled(b.T(), "PutBack")
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_PinConn_SyncSessionVariables() {
+	ctx := context.Background()
+	b.mockNs.On("GetPooledConn", mock.Anything).Return(b.mockConn, nil).Once()
+	b.mockConn.On("Execute", testSQL).Return(queryResult, nil)
+	require.NoError(b.T(), b.mockMgr.PinConn(ctx))
+
+	// the pinned conn is synced before each query
+	_, err := b.mockMgr.Query(ctx, testDB, testSQL)
+	require.NoError(b.T(), err)
+	b.mockConn.AssertNumberOfCalls(b.T(), "SyncSessionVariables", 1)
+
+	b.mockConn.ExpectedCalls = nil
+	b.mockConn.On("SyncSessionVariables", mock.Anything).Return(connmgrMockError).Once()
+	_, err = b.mockMgr.Query(ctx, testDB, testSQL)
+	require.Equal(b.T(), connmgrMockError, err)
+	b.mockConn.AssertNumberOfCalls(b.T(), "Execute", 1)
+	b.mockNs.AssertNumberOfCalls(b.T(), "GetPooledConn", 1)
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_PinConn_ResetSession() {
+	ctx := context.Background()
+	b.mockNs.On("GetPooledConn", mock.Anything).Return(b.mockConn, nil).Once()
+	b.mockConn.On("ErrorClose").Return(nil).Once()
+
+	require.NoError(b.T(), b.mockMgr.PinConn(ctx))
+	require.NoError(b.T(), b.mockMgr.ResetSession(ctx))
+
+	// the pinned conn is closed to drop user variables
+	b.mockConn.AssertCalled(b.T(), "ErrorClose")
+	b.mockConn.AssertNotCalled(b.T(), "PutBack")
+	require.False(b.T(), b.mockMgr.IsConnPinned())
+	require.Nil(b.T(), b.mockMgr.pinnedConn)
+}
+
+func (b *BackendConnManagerTestSuite) Test_State0_PinConn_Close() {
+	b.prepareConnMgrStatus(State0)
+	b.mockConn.On("ErrorClose").Return(nil).Once()
+
+	require.NoError(b.T(), b.mockMgr.PinConn(context.Background()))
+	require.NoError(b.T(), b.mockMgr.Close())
+
+	b.mockConn.AssertNumberOfCalls(b.T(), "ErrorClose", 1)
+	require.Nil(b.T(), b.mockMgr.pinnedConn)
+	require.Nil(b.T(), b.mockMgr.txnConn)
+}
@@ -0,0 +1,202 @@
+package driver
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/util/logutil"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"go.uber.org/zap"
+)
+
+// emulatedStmt is a prepared statement cached in proxy when prepare emulation is enabled.
+// It's prepared on the backend conn serving each execution and closed after the execution,
+// so the session doesn't pin a backend conn.
+type emulatedStmt struct {
+	id      int
+	db      string
+	sql     string
+	params  int
+	columns int
+
+	// param types bound by the last execution, client may not send them again
+	paramTypes []byte
+	// COM_STMT_SEND_LONG_DATA packets since the last execution or reset
+	longData [][]byte
+}
+
+func (s *emulatedStmt) ID() int {
+	return s.id
+}
+
+func (s *emulatedStmt) ParamNum() int {
+	return s.params
+}
+
+func (s *emulatedStmt) ColumnNum() int {
+	return s.columns
+}
+
+func (s *emulatedStmt) appendLongData(data []byte) {
+	s.longData = append(s.longData, append([]byte(nil), data...))
+}
+
+func (s *emulatedStmt) resetLongData() {
+	s.longData = nil
+}
+
+// buildExecuteData returns a copy of COM_STMT_EXECUTE data to be sent to backend.
+// The cursor flag is cleared because backend statement is closed after execution,
+// and param types of the last execution are filled in if client doesn't send them.
+func (s *emulatedStmt) buildExecuteData(data []byte) ([]byte, error) {
+	// stmt id (4), flags (1), iteration count (4)
+	pos := 9
+	if len(data) < pos {
+		return nil, mysql.ErrMalformPacket
+	}
+	ret := make([]byte, 0, len(data)+1+len(s.paramTypes))
+	ret = append(ret, data[:pos]...)
+	ret[4] = 0
+	if s.params == 0 {
+		return append(ret, data[pos:]...), nil
+	}
+
+	nullBitmapLen := (s.params + 7) >> 3
+	if len(data) < pos+nullBitmapLen+1 {
+		return nil, mysql.ErrMalformPacket
+	}
+	ret = append(ret, data[pos:pos+nullBitmapLen]...)
+	pos += nullBitmapLen
+
+	// new params bound flag
+	if data[pos] == 1 {
+		typesEnd := pos + 1 + s.params<<1
+		if len(data) < typesEnd {
+			return nil, mysql.ErrMalformPacket
+		}
+		s.paramTypes = append(s.paramTypes[:0], data[pos+1:typesEnd]...)
+		return append(ret, data[pos:]...), nil
+	}
+	if len(s.paramTypes) == 0 {
+		return nil, mysql.ErrMalformPacket
+	}
+	ret = append(ret, 1)
+	ret = append(ret, s.paramTypes...)
+	return append(ret, data[pos+1:]...), nil
+}
+
+// execute prepares the statement on conn, replays long data and forwards execute data to it.
+func (s *emulatedStmt) execute(ctx context.Context, conn BackendConn, data []byte) (*gomysql.Result, error) {
+	if err := conn.UseDB(s.db); err != nil {
+		return nil, err
+	}
+	backendStmt, err := conn.StmtPrepare(s.sql)
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := s.executeBackendStmt(conn, backendStmt.ID(), data)
+	if err == nil {
+		// the warnings are read before the backend statement is closed
+		recordBackendWarnings(ctx, conn)
+	}
+	if errClose := conn.StmtClosePrepare(backendStmt.ID()); errClose != nil && err == nil {
+		return nil, errClose
+	}
+	return ret, err
+}
+
+func (s *emulatedStmt) executeBackendStmt(conn BackendConn, backendStmtID int, data []byte) (*gomysql.Result, error) {
+	for _, longData := range s.longData {
+		setStmtID(longData, backendStmtID)
+		if err := conn.StmtSendLongDataForward(longData); err != nil {
+			return nil, err
+		}
+	}
+	setStmtID(data, backendStmtID)
+	return conn.StmtExecuteForward(data)
+}
+
+func setStmtID(data []byte, stmtId int) {
+	binary.LittleEndian.PutUint32(data[0:4], uint32(stmtId))
+}
+
+func newNoOpenCursorError(stmtId int) error {
+	return gomysql.NewError(gomysql.ER_STMT_HAS_NO_OPEN_CURSOR, fmt.Sprintf("The statement (%d) has no open cursor.", stmtId))
+}
+
+// prepareStmtMeta prepares sql on conn to get the param and column count, the backend statement is closed at once.
+func prepareStmtMeta(conn BackendConn, db, sql string) (Stmt, error) {
+	if err := conn.UseDB(db); err != nil {
+		return nil, err
+	}
+	stmt, err := conn.StmtPrepare(sql)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.StmtClosePrepare(stmt.ID()); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+func (f *BackendConnManager) prepareEmulatedStmt(ctx context.Context, db, sql string) (Stmt, error) {
+	ret, err := f.fsm.Call(ctx, EventStmtPrepareEmulated, f, db, sql)
+	if err != nil {
+		return nil, err
+	}
+	backendStmt := ret.(Stmt)
+
+	f.lastStmtID++
+	stmt := &emulatedStmt{
+		id:      f.lastStmtID,
+		db:      db,
+		sql:     sql,
+		params:  backendStmt.ParamNum(),
+		columns: backendStmt.ColumnNum(),
+	}
+	f.emulatedStmts[stmt.id] = stmt
+	return stmt, nil
+}
+
+func (f *BackendConnManager) executeEmulatedStmt(ctx context.Context, stmtId int, data []byte) (*gomysql.Result, error) {
+	stmt, ok := f.emulatedStmts[stmtId]
+	if !ok {
+		return nil, newUnknownStmtError(stmtId, "mysqld_stmt_execute")
+	}
+	// long data is cleared after execution, the same as MySQL
+	defer stmt.resetLongData()
+
+	execData, err := stmt.buildExecuteData(data)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := f.fsm.Call(ctx, EventStmtExecuteEmulated, f, stmt, execData)
+	if err != nil {
+		return nil, err
+	}
+	if ret == nil {
+		return nil, nil
+	}
+	return ret.(*gomysql.Result), nil
+}
+
+// withPooledConn runs fn with a conn from pool, the conn is closed if fn returns conn error.
+func (f *BackendConnManager) withPooledConn(ctx context.Context, fn func(conn PooledBackendConn) error) error {
+	conn, err := f.getPooledConn(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(conn)
+	if err != nil && isConnError(err) {
+		if errClose := conn.ErrorClose(); errClose != nil {
+			logutil.BgLogger().Error("close backend conn error", zap.Error(errClose))
+		}
+	} else {
+		conn.PutBack()
+	}
+	return err
+}
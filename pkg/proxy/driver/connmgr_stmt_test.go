@@ -0,0 +1,281 @@
+package driver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pingcap/parser/mysql"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// buildTestExecuteData builds COM_STMT_EXECUTE data of stmt with 2 params.
+func buildTestExecuteData(stmtId byte, flag byte, types []byte) []byte {
+	data := []byte{stmtId, 0, 0, 0, flag, 1, 0, 0, 0, 0}
+	if types == nil {
+		data = append(data, 0)
+	} else {
+		data = append(data, 1)
+		data = append(data, types...)
+	}
+	return append(data, 1, 0, 0, 0, 2, 0, 0, 0)
+}
+
+func TestEmulatedStmt_BuildExecuteData(t *testing.T) {
+	types := []byte{mysql.TypeLong, 0, mysql.TypeLong, 0}
+	stmt := &emulatedStmt{id: 1, params: 2}
+
+	// types are not bound yet
+	_, err := stmt.buildExecuteData(buildTestExecuteData(1, 0, nil))
+	require.Equal(t, mysql.ErrMalformPacket, err)
+
+	data := buildTestExecuteData(1, 1, types)
+	ret, err := stmt.buildExecuteData(data)
+	require.NoError(t, err)
+	require.Equal(t, buildTestExecuteData(1, 0, types), ret)
+	require.Equal(t, types, stmt.paramTypes)
+	// data from client is not modified
+	require.Equal(t, byte(1), data[4])
+
+	ret, err = stmt.buildExecuteData(buildTestExecuteData(1, 0, nil))
+	require.NoError(t, err)
+	require.Equal(t, buildTestExecuteData(1, 0, types), ret)
+
+	_, err = stmt.buildExecuteData([]byte{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, mysql.TypeLong})
+	require.Equal(t, mysql.ErrMalformPacket, err)
+
+	stmt = &emulatedStmt{id: 2}
+	ret, err = stmt.buildExecuteData([]byte{2, 0, 0, 0, 1, 1, 0, 0, 0})
+	require.NoError(t, err)
+	require.Equal(t, []byte{2, 0, 0, 0, 0, 1, 0, 0, 0}, ret)
+	_, err = stmt.buildExecuteData([]byte{2, 0, 0, 0})
+	require.Equal(t, mysql.ErrMalformPacket, err)
+}
+
+// emulatedExecData returns COM_STMT_EXECUTE data of stmt without params.
+func emulatedExecData(stmtId int) []byte {
+	return []byte{byte(stmtId), 0, 0, 0, 0, 1, 0, 0, 0}
+}
+
+func (b *BackendConnManagerTestSuite) prepareEmulatedStmt() *emulatedStmt {
+	b.mockMgr.emulatePrepare = true
+	stmt := &emulatedStmt{id: testOtherStmtID, db: testDB, sql: testSQL}
+	b.mockMgr.emulatedStmts[stmt.id] = stmt
+	return stmt
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_StmtPrepareEmulated_Success() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State2,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.mockMgr.emulatePrepare = true
+			b.mockStmt.On("ParamNum").Return(2)
+			b.mockStmt.On("ColumnNum").Return(3)
+			b.mockNs.On("GetPooledConn", ctx).Return(b.mockConn, nil)
+			b.mockConn.On("StmtPrepare", testSQL).Return(b.mockStmt, nil).Twice()
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(nil).Twice()
+			b.mockConn.On("PutBack").Return().Twice()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			stmt, err := b.mockMgr.StmtPrepare(ctx, testDB, testSQL)
+			require.NoError(b.T(), err)
+			require.Equal(b.T(), 1, stmt.ID())
+			require.Equal(b.T(), 2, stmt.ParamNum())
+			require.Equal(b.T(), 3, stmt.ColumnNum())
+			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+			b.mockConn.AssertCalled(b.T(), "PutBack")
+
+			stmt, err = b.mockMgr.StmtPrepare(ctx, testDB, testSQL)
+			require.NoError(b.T(), err)
+			require.Equal(b.T(), 2, stmt.ID())
+			require.Len(b.T(), b.mockMgr.emulatedStmts, 2)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State3_StmtPrepareEmulated_Error_StmtPrepare() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State3,
+		TargetState:  State3,
+		Prepare: func(ctx context.Context) {
+			b.mockMgr.emulatePrepare = true
+			b.mockConn.On("StmtPrepare", testSQL).Return(nil, connmgrMockError).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			_, err := b.mockMgr.StmtPrepare(ctx, testDB, testSQL)
+			require.EqualError(b.T(), err, connmgrMockError.Error())
+			require.Empty(b.T(), b.mockMgr.emulatedStmts)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_StmtExecuteEmulated_Success() {
+	longData := []byte{byte(testOtherStmtID), 0, 0, 0, 0, 0, 'a'}
+	backendLongData := []byte{byte(testStmtID), 0, 0, 0, 0, 0, 'a'}
+	backendExecData := emulatedExecData(testStmtID)
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State2,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			stmt := b.prepareEmulatedStmt()
+			stmt.appendLongData(longData)
+			b.mockNs.On("GetPooledConn", ctx).Return(b.mockConn, nil)
+			b.mockConn.On("StmtPrepare", testSQL).Return(b.mockStmt, nil).Once()
+			b.mockConn.On("StmtSendLongDataForward", backendLongData).Return(nil).Once()
+			b.mockConn.On("StmtExecuteForward", backendExecData).Return(queryResult, nil).Once()
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(nil).Once()
+			b.mockConn.On("PutBack").Return().Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			ret, err := b.mockMgr.StmtExecuteForward(ctx, testOtherStmtID, []byte{byte(testOtherStmtID), 0, 0, 0, 1, 1, 0, 0, 0})
+			require.NoError(b.T(), err)
+			require.Equal(b.T(), queryResult, ret)
+			b.mockConn.AssertCalled(b.T(), "StmtSendLongDataForward", backendLongData)
+			b.mockConn.AssertCalled(b.T(), "StmtExecuteForward", backendExecData)
+			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+			b.mockConn.AssertCalled(b.T(), "PutBack")
+			// long data is cleared after execution
+			require.Empty(b.T(), b.mockMgr.emulatedStmts[testOtherStmtID].longData)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_StmtExecuteEmulated_Warnings() {
+	backendExecData := emulatedExecData(testStmtID)
+	warnings, err := createSimpleTextResult([]string{"Level", "Code", "Message"}, [][]interface{}{
+		{"Warning", int64(1265), "Data truncated for column 'a' at row 1"},
+	})
+	require.NoError(b.T(), err)
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State2,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.prepareEmulatedStmt()
+			b.mockNs.On("GetPooledConn", mock.Anything).Return(b.mockConn, nil)
+			b.mockConn.On("StmtPrepare", testSQL).Return(b.mockStmt, nil).Once()
+			b.mockConn.On("StmtExecuteForward", backendExecData).Return(queryResult, nil).Once()
+			b.mockConn.On("GetWarnings").Return(uint16(1)).Once()
+			b.mockConn.On("Execute", "SHOW WARNINGS").Return(warnings, nil).Once()
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(nil).Once()
+			b.mockConn.On("PutBack").Return().Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			ctx, recorder := ctxWithWarningsRecorder(ctx)
+			_, err := b.mockMgr.StmtExecuteForward(ctx, testOtherStmtID, []byte{byte(testOtherStmtID), 0, 0, 0, 1, 1, 0, 0, 0})
+			require.NoError(b.T(), err)
+			require.Equal(b.T(), uint16(1), recorder.count)
+			require.Len(b.T(), recorder.warns, 1)
+			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_StmtExecuteEmulated_Error_ConnError() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State2,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.prepareEmulatedStmt()
+			b.mockNs.On("GetPooledConn", ctx).Return(b.mockConn, nil)
+			b.mockConn.On("StmtPrepare", testSQL).Return(b.mockStmt, nil).Once()
+			b.mockConn.On("StmtExecuteForward", emulatedExecData(testStmtID)).Return(nil, gomysql.ErrBadConn).Once()
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(gomysql.ErrBadConn).Once()
+			b.mockConn.On("ErrorClose").Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			_, err := b.mockMgr.StmtExecuteForward(ctx, testOtherStmtID, emulatedExecData(testOtherStmtID))
+			require.Equal(b.T(), gomysql.ErrBadConn, err)
+			b.mockConn.AssertCalled(b.T(), "ErrorClose")
+			b.mockConn.AssertNotCalled(b.T(), "PutBack")
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State0_StmtExecuteEmulated_Success() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State0,
+		TargetState:  State1,
+		Prepare: func(ctx context.Context) {
+			b.prepareEmulatedStmt()
+			b.mockConn.On("StmtPrepare", testSQL).Return(b.mockStmt, nil).Once()
+			b.mockConn.On("StmtExecuteForward", emulatedExecData(testStmtID)).Return(queryResult, nil).Once()
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			ret, err := b.mockMgr.StmtExecuteForward(ctx, testOtherStmtID, emulatedExecData(testOtherStmtID))
+			require.NoError(b.T(), err)
+			require.Equal(b.T(), queryResult, ret)
+			b.mockConn.AssertNotCalled(b.T(), "PutBack")
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_StmtEmulated_UnknownStmt() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State2,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.prepareEmulatedStmt()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			_, err := b.mockMgr.StmtExecuteForward(ctx, testStmtID, stmtExecData)
+			requireUnknownStmtError(b.T(), err)
+			_, err = b.mockMgr.StmtFetch(ctx, testStmtID, stmtFetchData, nil)
+			requireUnknownStmtError(b.T(), err)
+			requireUnknownStmtError(b.T(), b.mockMgr.StmtSendLongData(ctx, testStmtID, stmtLongData))
+			requireUnknownStmtError(b.T(), b.mockMgr.StmtReset(ctx, testStmtID))
+			require.NoError(b.T(), b.mockMgr.StmtClose(ctx, testStmtID))
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_StmtEmulated_FetchResetClose() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State2,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.prepareEmulatedStmt()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			_, err := b.mockMgr.StmtFetch(ctx, testOtherStmtID, stmtFetchData, nil)
+			myErr, ok := err.(*gomysql.MyError)
+			require.True(b.T(), ok)
+			require.Equal(b.T(), uint16(gomysql.ER_STMT_HAS_NO_OPEN_CURSOR), myErr.Code)
+
+			require.NoError(b.T(), b.mockMgr.StmtSendLongData(ctx, testOtherStmtID, stmtLongData))
+			require.Equal(b.T(), [][]byte{stmtLongData}, b.mockMgr.emulatedStmts[testOtherStmtID].longData)
+			require.NoError(b.T(), b.mockMgr.StmtReset(ctx, testOtherStmtID))
+			require.Empty(b.T(), b.mockMgr.emulatedStmts[testOtherStmtID].longData)
+
+			require.NoError(b.T(), b.mockMgr.StmtClose(ctx, testOtherStmtID))
+			require.Empty(b.T(), b.mockMgr.emulatedStmts)
+			b.mockConn.AssertNotCalled(b.T(), "StmtClosePrepare", testOtherStmtID)
+		},
+	}
+
+	tc.Run()
+}
@@ -3,23 +3,36 @@ package driver
 import (
 	"context"
 	"errors"
+	"os"
 	"testing"
 
 	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/tidb-incubator/weir/pkg/proxy/constant"
+	"github.com/tidb-incubator/weir/pkg/proxy/metrics"
+	wast "github.com/tidb-incubator/weir/pkg/util/ast"
 )
 
 const (
-	testDB     = "test_db"
-	testSQL    = "SELECT * FROM test_tbl"
-	testStmtID = 1
+	testDB          = "test_db"
+	testSQL         = "SELECT * FROM test_tbl"
+	testStmtID      = 1
+	testOtherStmtID = 2
 )
 
 var queryResult = &gomysql.Result{}
 var stmtExecData = []byte("exec")
+var stmtFetchData = []byte("fetch")
+var stmtLongData = []byte("long data")
 var connmgrMockError = errors.New("mock error")
 
+func TestMain(m *testing.M) {
+	metrics.RegisterProxyMetrics("test_cluster")
+	os.Exit(m.Run())
+}
+
 type BackendConnManagerTestSuite struct {
 	suite.Suite
 
@@ -29,16 +42,15 @@ type BackendConnManagerTestSuite struct {
 	mockStmt *MockStmt
 }
 
-func (b *BackendConnManagerTestSuite) SetupSuite() {
-}
-
 func (b *BackendConnManagerTestSuite) SetupTest() {
 	b.mockConn = new(MockPooledBackendConn)
 	b.mockNs = new(MockNamespace)
 	b.mockStmt = new(MockStmt)
 	b.mockStmt.On("ID").Return(testStmtID)
 	b.mockNs.On("Name").Return("mock_namespace")
+	b.mockNs.On("IsPrepareEmulated").Return(false)
 	b.mockConn.On("UseDB", testDB).Return(nil)
+	b.mockConn.On("SyncSessionVariables", mock.Anything).Return(nil)
 	b.mockMgr = NewBackendConnManager(getGlobalFSM(), b.mockNs)
 }
 
@@ -54,7 +66,7 @@ func (b *BackendConnManagerTestSuite) prepareConnMgrStatus(state FSMState) {
 		b.mockMgr.txnConn = b.mockConn
 	}
 	if b.mockMgr.state.IsPrepare() {
-		b.mockMgr.isPrepared = true
+		b.mockMgr.addStmtID(testStmtID)
 	}
 }
 
@@ -62,33 +74,39 @@ func (b *BackendConnManagerTestSuite) assertConnMgrStatusCorrect(state FSMState)
 	switch state {
 	case State0:
 		require.NotNil(b.T(), b.mockMgr.txnConn)
-		require.False(b.T(), b.mockMgr.isPrepared)
+		require.Empty(b.T(), b.mockMgr.stmtIDs)
 	case State1:
 		require.NotNil(b.T(), b.mockMgr.txnConn)
-		require.False(b.T(), b.mockMgr.isPrepared)
+		require.Empty(b.T(), b.mockMgr.stmtIDs)
 	case State2:
 		require.Nil(b.T(), b.mockMgr.txnConn)
-		require.False(b.T(), b.mockMgr.isPrepared)
+		require.Empty(b.T(), b.mockMgr.stmtIDs)
 	case State3:
 		require.NotNil(b.T(), b.mockMgr.txnConn)
-		require.False(b.T(), b.mockMgr.isPrepared)
+		require.Empty(b.T(), b.mockMgr.stmtIDs)
 	case State4:
 		require.NotNil(b.T(), b.mockMgr.txnConn)
-		require.True(b.T(), b.mockMgr.isPrepared)
+		require.NotEmpty(b.T(), b.mockMgr.stmtIDs)
 	case State5:
 		require.NotNil(b.T(), b.mockMgr.txnConn)
-		require.True(b.T(), b.mockMgr.isPrepared)
+		require.NotEmpty(b.T(), b.mockMgr.stmtIDs)
 	case State6:
 		require.NotNil(b.T(), b.mockMgr.txnConn)
-		require.True(b.T(), b.mockMgr.isPrepared)
+		require.NotEmpty(b.T(), b.mockMgr.stmtIDs)
 	case State7:
 		require.NotNil(b.T(), b.mockMgr.txnConn)
-		require.True(b.T(), b.mockMgr.isPrepared)
+		require.NotEmpty(b.T(), b.mockMgr.stmtIDs)
 	default:
 		b.T().FailNow()
 	}
 }
 
+func requireUnknownStmtError(t *testing.T, err error) {
+	myErr, ok := err.(*gomysql.MyError)
+	require.True(t, ok)
+	require.Equal(t, uint16(gomysql.ER_UNKNOWN_STMT_HANDLER), myErr.Code)
+}
+
 type BackendConnManagerTestCase struct {
 	suite *BackendConnManagerTestSuite
 
@@ -499,6 +517,157 @@ func (b *BackendConnManagerTestSuite) Test_State2_Query_Error_Execute() {
 	tc.Run()
 }
 
+func (b *BackendConnManagerTestSuite) Test_State2_Query_ReadOnly_Success() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State2,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.mockNs.On("GetPooledReadConn", mock.Anything).Return(b.mockConn, nil).Once()
+			b.mockConn.On("UseDB", testDB).Return(nil).Once()
+			b.mockConn.On("Execute", testSQL).Return(queryResult, nil).Once()
+			b.mockConn.On("PutBack").Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			ctx = wast.CtxWithReadOnlyStmt(ctx, true)
+			ret, err := b.mockMgr.Query(ctx, testDB, testSQL)
+			require.NotNil(b.T(), ret)
+			require.NoError(b.T(), err)
+			b.mockNs.AssertCalled(b.T(), "GetPooledReadConn", ctx)
+			b.mockNs.AssertNotCalled(b.T(), "GetPooledConn", mock.Anything)
+			b.mockConn.AssertCalled(b.T(), "Execute", testSQL)
+			b.mockConn.AssertCalled(b.T(), "PutBack")
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_Query_ReadOnly_Retry_Success() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State2,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.mockNs.On("GetPooledReadConn", mock.Anything).Return(b.mockConn, nil).Twice()
+			b.mockConn.On("UseDB", testDB).Return(nil).Twice()
+			b.mockConn.On("Execute", testSQL).Return(nil, gomysql.ErrBadConn).Once()
+			b.mockConn.On("Execute", testSQL).Return(queryResult, nil).Once()
+			b.mockConn.On("ErrorClose").Return(nil).Once()
+			b.mockConn.On("GetAddr").Return("127.0.0.1:4000").Once()
+			b.mockConn.On("PutBack").Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			ctx = wast.CtxWithReadOnlyStmt(ctx, true)
+			ret, err := b.mockMgr.Query(ctx, testDB, testSQL)
+			require.NotNil(b.T(), ret)
+			require.NoError(b.T(), err)
+			b.mockNs.AssertNumberOfCalls(b.T(), "GetPooledReadConn", 2)
+			b.mockNs.AssertCalled(b.T(), "GetPooledReadConn", mock.MatchedBy(func(c context.Context) bool {
+				return c.Value(constant.ContextKeyExcludedBackendAddr) == "127.0.0.1:4000"
+			}))
+			b.mockConn.AssertNumberOfCalls(b.T(), "Execute", 2)
+			b.mockConn.AssertCalled(b.T(), "ErrorClose")
+			b.mockConn.AssertCalled(b.T(), "PutBack")
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_Query_ReadOnly_Retry_Error() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State2,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.mockNs.On("GetPooledReadConn", mock.Anything).Return(b.mockConn, nil).Twice()
+			b.mockConn.On("UseDB", testDB).Return(nil).Twice()
+			b.mockConn.On("Execute", testSQL).Return(nil, gomysql.ErrBadConn).Twice()
+			b.mockConn.On("ErrorClose").Return(nil).Twice()
+			b.mockConn.On("GetAddr").Return("127.0.0.1:4000").Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			ctx = wast.CtxWithReadOnlyStmt(ctx, true)
+			ret, err := b.mockMgr.Query(ctx, testDB, testSQL)
+			require.Nil(b.T(), ret)
+			require.EqualError(b.T(), err, gomysql.ErrBadConn.Error())
+			b.mockNs.AssertNumberOfCalls(b.T(), "GetPooledReadConn", 2)
+			b.mockConn.AssertNumberOfCalls(b.T(), "ErrorClose", 2)
+			b.mockConn.AssertNotCalled(b.T(), "PutBack")
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_Query_Write_NoRetry() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State2,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.mockNs.On("GetPooledConn", ctx).Return(b.mockConn, nil).Once()
+			b.mockConn.On("UseDB", testDB).Return(nil).Once()
+			b.mockConn.On("Execute", testSQL).Return(nil, gomysql.ErrBadConn).Once()
+			b.mockConn.On("ErrorClose").Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			ret, err := b.mockMgr.Query(ctx, testDB, testSQL)
+			require.Nil(b.T(), ret)
+			require.EqualError(b.T(), err, gomysql.ErrBadConn.Error())
+			b.mockNs.AssertNumberOfCalls(b.T(), "GetPooledConn", 1)
+			b.mockConn.AssertNotCalled(b.T(), "GetAddr")
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State3_Query_ReadOnly_Error_NoRetry() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State3,
+		TargetState:  State3,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("UseDB", testDB).Return(nil).Once()
+			b.mockConn.On("Execute", testSQL).Return(nil, gomysql.ErrBadConn).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			ctx = wast.CtxWithReadOnlyStmt(ctx, true)
+			ret, err := b.mockMgr.Query(ctx, testDB, testSQL)
+			require.Nil(b.T(), ret)
+			require.EqualError(b.T(), err, gomysql.ErrBadConn.Error())
+			b.mockConn.AssertNumberOfCalls(b.T(), "Execute", 1)
+			b.mockConn.AssertNotCalled(b.T(), "GetAddr")
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State3_Query_ReadOnly_Success() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State3,
+		TargetState:  State3,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("UseDB", testDB).Return(nil).Once()
+			b.mockConn.On("Execute", testSQL).Return(queryResult, nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			ctx = wast.CtxWithReadOnlyStmt(ctx, true)
+			ret, err := b.mockMgr.Query(ctx, testDB, testSQL)
+			require.NotNil(b.T(), ret)
+			require.NoError(b.T(), err)
+			b.mockNs.AssertNotCalled(b.T(), "GetPooledReadConn", mock.Anything)
+			b.mockConn.AssertCalled(b.T(), "Execute", testSQL)
+		},
+	}
+
+	tc.Run()
+}
+
 func (b *BackendConnManagerTestSuite) Test_State3_Query_Success() {
 	tc := &BackendConnManagerTestCase{
 		suite:        b,
@@ -1845,155 +2014,955 @@ func (b *BackendConnManagerTestSuite) Test_State7_StmtExecute_Error_StmtExecuteF
 
 // run StmtExecute in State0 to State3 will return fsm action not allowed error
 // so we skip these cases
-func (b *BackendConnManagerTestSuite) Test_State4_StmtClose_Success() {
+func (b *BackendConnManagerTestSuite) Test_State2_StmtFetch_Error_UnknownStmt() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State2,
+		TargetState:  State2,
+		Prepare:      func(ctx context.Context) {},
+		RunAndAssert: func(ctx context.Context) {
+			_, err := b.mockMgr.StmtFetch(ctx, testStmtID, stmtFetchData, func([]byte) error { return nil })
+			requireUnknownStmtError(b.T(), err)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State4_StmtFetch_Success() {
 	tc := &BackendConnManagerTestCase{
 		suite:        b,
 		CurrentState: State4,
-		TargetState:  State0,
+		TargetState:  State4,
 		Prepare: func(ctx context.Context) {
-			b.mockConn.On("StmtClosePrepare", testStmtID).Return(nil).Once()
+			b.mockConn.On("StmtFetchForward", stmtFetchData, mock.Anything).Return(gomysql.SERVER_STATUS_LAST_ROW_SEND, nil).Once()
 		},
 		RunAndAssert: func(ctx context.Context) {
-			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			status, err := b.mockMgr.StmtFetch(ctx, testStmtID, stmtFetchData, func([]byte) error { return nil })
 			require.NoError(b.T(), err)
-			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+			require.Equal(b.T(), gomysql.SERVER_STATUS_LAST_ROW_SEND, status)
+			b.mockConn.AssertCalled(b.T(), "StmtFetchForward", stmtFetchData, mock.Anything)
 		},
 	}
 
 	tc.Run()
 }
 
-// currently we force close all prepared statements
-func (b *BackendConnManagerTestSuite) Test_State4_StmtClose_Error_StmtClosePrepare() {
+func (b *BackendConnManagerTestSuite) Test_State5_StmtFetch_Error_StmtFetchForward() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State5,
+		TargetState:  State5,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtFetchForward", stmtFetchData, mock.Anything).Return(uint16(0), connmgrMockError).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			_, err := b.mockMgr.StmtFetch(ctx, testStmtID, stmtFetchData, func([]byte) error { return nil })
+			require.EqualError(b.T(), err, connmgrMockError.Error())
+			b.mockConn.AssertCalled(b.T(), "StmtFetchForward", stmtFetchData, mock.Anything)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State6_StmtFetch_Success() {
+	var rows [][]byte
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State6,
+		TargetState:  State6,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtFetchForward", stmtFetchData, mock.Anything).Return(func(data []byte, rowHandler func([]byte) error) uint16 {
+				_ = rowHandler([]byte("row1"))
+				_ = rowHandler([]byte("row2"))
+				return gomysql.SERVER_STATUS_CURSOR_EXISTS
+			}, nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			status, err := b.mockMgr.StmtFetch(ctx, testStmtID, stmtFetchData, func(row []byte) error {
+				rows = append(rows, row)
+				return nil
+			})
+			require.NoError(b.T(), err)
+			require.Equal(b.T(), gomysql.SERVER_STATUS_CURSOR_EXISTS, status)
+			require.Equal(b.T(), [][]byte{[]byte("row1"), []byte("row2")}, rows)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State1_StmtSendLongData_Error_UnknownStmt() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State1,
+		TargetState:  State1,
+		Prepare:      func(ctx context.Context) {},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtSendLongData(ctx, testStmtID, stmtLongData)
+			requireUnknownStmtError(b.T(), err)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State4_StmtSendLongData_Success() {
 	tc := &BackendConnManagerTestCase{
 		suite:        b,
 		CurrentState: State4,
-		TargetState:  State0,
+		TargetState:  State4,
 		Prepare: func(ctx context.Context) {
-			b.mockConn.On("StmtClosePrepare", testStmtID).Return(connmgrMockError).Once()
+			b.mockConn.On("StmtSendLongDataForward", stmtLongData).Return(nil).Once()
 		},
 		RunAndAssert: func(ctx context.Context) {
-			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			err := b.mockMgr.StmtSendLongData(ctx, testStmtID, stmtLongData)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertCalled(b.T(), "StmtSendLongDataForward", stmtLongData)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State4_StmtSendLongData_Error_StmtSendLongDataForward() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State4,
+		TargetState:  State4,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtSendLongDataForward", stmtLongData).Return(connmgrMockError).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtSendLongData(ctx, testStmtID, stmtLongData)
 			require.EqualError(b.T(), err, connmgrMockError.Error())
-			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+			b.mockConn.AssertCalled(b.T(), "StmtSendLongDataForward", stmtLongData)
 		},
 	}
 
 	tc.Run()
 }
 
-func (b *BackendConnManagerTestSuite) Test_State5_StmtClose_Success() {
+func (b *BackendConnManagerTestSuite) Test_State5_StmtSendLongData_Success() {
 	tc := &BackendConnManagerTestCase{
 		suite:        b,
 		CurrentState: State5,
-		TargetState:  State1,
+		TargetState:  State5,
 		Prepare: func(ctx context.Context) {
-			b.mockConn.On("StmtClosePrepare", testStmtID).Return(nil).Once()
+			b.mockConn.On("StmtSendLongDataForward", stmtLongData).Return(nil).Once()
 		},
 		RunAndAssert: func(ctx context.Context) {
-			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			err := b.mockMgr.StmtSendLongData(ctx, testStmtID, stmtLongData)
 			require.NoError(b.T(), err)
-			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+			b.mockConn.AssertCalled(b.T(), "StmtSendLongDataForward", stmtLongData)
 		},
 	}
 
 	tc.Run()
 }
 
-func (b *BackendConnManagerTestSuite) Test_State5_StmtClose_Error_StmtClosePrepare() {
+func (b *BackendConnManagerTestSuite) Test_State5_StmtSendLongData_Error_StmtSendLongDataForward() {
 	tc := &BackendConnManagerTestCase{
 		suite:        b,
 		CurrentState: State5,
-		TargetState:  State1,
+		TargetState:  State5,
 		Prepare: func(ctx context.Context) {
-			b.mockConn.On("StmtClosePrepare", testStmtID).Return(connmgrMockError).Once()
+			b.mockConn.On("StmtSendLongDataForward", stmtLongData).Return(connmgrMockError).Once()
 		},
 		RunAndAssert: func(ctx context.Context) {
-			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			err := b.mockMgr.StmtSendLongData(ctx, testStmtID, stmtLongData)
 			require.EqualError(b.T(), err, connmgrMockError.Error())
-			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+			b.mockConn.AssertCalled(b.T(), "StmtSendLongDataForward", stmtLongData)
 		},
 	}
 
 	tc.Run()
 }
 
-func (b *BackendConnManagerTestSuite) Test_State6_StmtClose_Success() {
+func (b *BackendConnManagerTestSuite) Test_State6_StmtSendLongData_Success() {
 	tc := &BackendConnManagerTestCase{
 		suite:        b,
 		CurrentState: State6,
-		TargetState:  State2,
+		TargetState:  State6,
 		Prepare: func(ctx context.Context) {
-			b.mockConn.On("StmtClosePrepare", testStmtID).Return(nil).Once()
-			b.mockConn.On("PutBack").Return(nil).Once()
+			b.mockConn.On("StmtSendLongDataForward", stmtLongData).Return(nil).Once()
 		},
 		RunAndAssert: func(ctx context.Context) {
-			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			err := b.mockMgr.StmtSendLongData(ctx, testStmtID, stmtLongData)
 			require.NoError(b.T(), err)
-			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
-			b.mockConn.AssertCalled(b.T(), "PutBack")
+			b.mockConn.AssertCalled(b.T(), "StmtSendLongDataForward", stmtLongData)
 		},
 	}
 
 	tc.Run()
 }
 
-func (b *BackendConnManagerTestSuite) Test_State6_StmtClose_Error_StmtClosePrepare() {
+func (b *BackendConnManagerTestSuite) Test_State6_StmtSendLongData_Error_StmtSendLongDataForward() {
 	tc := &BackendConnManagerTestCase{
 		suite:        b,
 		CurrentState: State6,
-		TargetState:  State2,
+		TargetState:  State6,
 		Prepare: func(ctx context.Context) {
-			b.mockConn.On("StmtClosePrepare", testStmtID).Return(connmgrMockError).Once()
-			b.mockConn.On("ErrorClose").Return(nil).Once()
+			b.mockConn.On("StmtSendLongDataForward", stmtLongData).Return(connmgrMockError).Once()
 		},
 		RunAndAssert: func(ctx context.Context) {
-			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			err := b.mockMgr.StmtSendLongData(ctx, testStmtID, stmtLongData)
 			require.EqualError(b.T(), err, connmgrMockError.Error())
-			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
-			b.mockConn.AssertCalled(b.T(), "ErrorClose")
+			b.mockConn.AssertCalled(b.T(), "StmtSendLongDataForward", stmtLongData)
 		},
 	}
 
 	tc.Run()
 }
 
-func (b *BackendConnManagerTestSuite) Test_State7_StmtClose_Success() {
+func (b *BackendConnManagerTestSuite) Test_State7_StmtSendLongData_Success() {
 	tc := &BackendConnManagerTestCase{
 		suite:        b,
 		CurrentState: State7,
-		TargetState:  State3,
+		TargetState:  State7,
 		Prepare: func(ctx context.Context) {
-			b.mockConn.On("StmtClosePrepare", testStmtID).Return(nil).Once()
+			b.mockConn.On("StmtSendLongDataForward", stmtLongData).Return(nil).Once()
 		},
 		RunAndAssert: func(ctx context.Context) {
-			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			err := b.mockMgr.StmtSendLongData(ctx, testStmtID, stmtLongData)
 			require.NoError(b.T(), err)
-			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+			b.mockConn.AssertCalled(b.T(), "StmtSendLongDataForward", stmtLongData)
 		},
 	}
 
 	tc.Run()
 }
 
-func (b *BackendConnManagerTestSuite) Test_State7_StmtClose_Error_StmtClose() {
+func (b *BackendConnManagerTestSuite) Test_State7_StmtSendLongData_Error_StmtSendLongDataForward() {
 	tc := &BackendConnManagerTestCase{
 		suite:        b,
 		CurrentState: State7,
-		TargetState:  State3,
+		TargetState:  State7,
 		Prepare: func(ctx context.Context) {
-			b.mockConn.On("StmtClosePrepare", testStmtID).Return(connmgrMockError).Once()
+			b.mockConn.On("StmtSendLongDataForward", stmtLongData).Return(connmgrMockError).Once()
 		},
 		RunAndAssert: func(ctx context.Context) {
-			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			err := b.mockMgr.StmtSendLongData(ctx, testStmtID, stmtLongData)
 			require.EqualError(b.T(), err, connmgrMockError.Error())
-			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+			b.mockConn.AssertCalled(b.T(), "StmtSendLongDataForward", stmtLongData)
 		},
 	}
 
 	tc.Run()
 }
 
-func TestBackendConnManagerTestSuite(t *testing.T) {
-	suite.Run(t, new(BackendConnManagerTestSuite))
+func (b *BackendConnManagerTestSuite) Test_State3_StmtReset_Error_UnknownStmt() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State3,
+		TargetState:  State3,
+		Prepare:      func(ctx context.Context) {},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtReset(ctx, testStmtID)
+			requireUnknownStmtError(b.T(), err)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State4_StmtReset_Success() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State4,
+		TargetState:  State4,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtReset", testStmtID).Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtReset(ctx, testStmtID)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertCalled(b.T(), "StmtReset", testStmtID)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State4_StmtReset_Error_StmtReset() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State4,
+		TargetState:  State4,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtReset", testStmtID).Return(connmgrMockError).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtReset(ctx, testStmtID)
+			require.EqualError(b.T(), err, connmgrMockError.Error())
+			b.mockConn.AssertCalled(b.T(), "StmtReset", testStmtID)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State5_StmtReset_Success() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State5,
+		TargetState:  State5,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtReset", testStmtID).Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtReset(ctx, testStmtID)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertCalled(b.T(), "StmtReset", testStmtID)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State5_StmtReset_Error_StmtReset() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State5,
+		TargetState:  State5,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtReset", testStmtID).Return(connmgrMockError).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtReset(ctx, testStmtID)
+			require.EqualError(b.T(), err, connmgrMockError.Error())
+			b.mockConn.AssertCalled(b.T(), "StmtReset", testStmtID)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State6_StmtReset_Success() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State6,
+		TargetState:  State6,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtReset", testStmtID).Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtReset(ctx, testStmtID)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertCalled(b.T(), "StmtReset", testStmtID)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State6_StmtReset_Error_StmtReset() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State6,
+		TargetState:  State6,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtReset", testStmtID).Return(connmgrMockError).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtReset(ctx, testStmtID)
+			require.EqualError(b.T(), err, connmgrMockError.Error())
+			b.mockConn.AssertCalled(b.T(), "StmtReset", testStmtID)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State7_StmtReset_Success() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State7,
+		TargetState:  State7,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtReset", testStmtID).Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtReset(ctx, testStmtID)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertCalled(b.T(), "StmtReset", testStmtID)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State7_StmtReset_Error_StmtReset() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State7,
+		TargetState:  State7,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtReset", testStmtID).Return(connmgrMockError).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtReset(ctx, testStmtID)
+			require.EqualError(b.T(), err, connmgrMockError.Error())
+			b.mockConn.AssertCalled(b.T(), "StmtReset", testStmtID)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State5_StmtPrepare_Success_MultipleStmts() {
+	otherStmt := new(MockStmt)
+	otherStmt.On("ID").Return(testOtherStmtID)
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State5,
+		TargetState:  State5,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtPrepare", testSQL).Return(otherStmt, nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			stmt, err := b.mockMgr.StmtPrepare(ctx, testDB, testSQL)
+			require.NoError(b.T(), err)
+			require.Equal(b.T(), testOtherStmtID, stmt.ID())
+			require.Len(b.T(), b.mockMgr.stmtIDs, 2)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State4_StmtExecute_Error_UnknownStmt() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State4,
+		TargetState:  State4,
+		Prepare:      func(ctx context.Context) {},
+		RunAndAssert: func(ctx context.Context) {
+			_, err := b.mockMgr.StmtExecuteForward(ctx, testOtherStmtID, stmtExecData)
+			requireUnknownStmtError(b.T(), err)
+			b.mockConn.AssertNotCalled(b.T(), "StmtExecuteForward", stmtExecData)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State4_StmtClose_UnknownStmt() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State4,
+		TargetState:  State4,
+		Prepare:      func(ctx context.Context) {},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtClose(ctx, testOtherStmtID)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertNotCalled(b.T(), "StmtClosePrepare", testOtherStmtID)
+			require.True(b.T(), b.mockMgr.hasStmtID(testStmtID))
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State4_StmtClose_Success_OtherStmtsPrepared() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State4,
+		TargetState:  State4,
+		Prepare: func(ctx context.Context) {
+			b.mockMgr.addStmtID(testOtherStmtID)
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+			b.mockConn.AssertNotCalled(b.T(), "PutBack")
+			require.False(b.T(), b.mockMgr.hasStmtID(testStmtID))
+			require.True(b.T(), b.mockMgr.hasStmtID(testOtherStmtID))
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State5_StmtClose_Success_OtherStmtsPrepared() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State5,
+		TargetState:  State5,
+		Prepare: func(ctx context.Context) {
+			b.mockMgr.addStmtID(testOtherStmtID)
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+			b.mockConn.AssertNotCalled(b.T(), "PutBack")
+			require.False(b.T(), b.mockMgr.hasStmtID(testStmtID))
+			require.True(b.T(), b.mockMgr.hasStmtID(testOtherStmtID))
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State6_StmtClose_Success_OtherStmtsPrepared() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State6,
+		TargetState:  State6,
+		Prepare: func(ctx context.Context) {
+			b.mockMgr.addStmtID(testOtherStmtID)
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+			b.mockConn.AssertNotCalled(b.T(), "PutBack")
+			require.False(b.T(), b.mockMgr.hasStmtID(testStmtID))
+			require.True(b.T(), b.mockMgr.hasStmtID(testOtherStmtID))
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State7_StmtClose_Success_OtherStmtsPrepared() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State7,
+		TargetState:  State7,
+		Prepare: func(ctx context.Context) {
+			b.mockMgr.addStmtID(testOtherStmtID)
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+			b.mockConn.AssertNotCalled(b.T(), "PutBack")
+			require.False(b.T(), b.mockMgr.hasStmtID(testStmtID))
+			require.True(b.T(), b.mockMgr.hasStmtID(testOtherStmtID))
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State4_StmtClose_Success() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State4,
+		TargetState:  State0,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+		},
+	}
+
+	tc.Run()
+}
+
+// currently we force close all prepared statements
+func (b *BackendConnManagerTestSuite) Test_State4_StmtClose_Error_StmtClosePrepare() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State4,
+		TargetState:  State0,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(connmgrMockError).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			require.EqualError(b.T(), err, connmgrMockError.Error())
+			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State5_StmtClose_Success() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State5,
+		TargetState:  State1,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State5_StmtClose_Error_StmtClosePrepare() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State5,
+		TargetState:  State1,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(connmgrMockError).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			require.EqualError(b.T(), err, connmgrMockError.Error())
+			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State6_StmtClose_Success() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State6,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(nil).Once()
+			b.mockConn.On("PutBack").Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+			b.mockConn.AssertCalled(b.T(), "PutBack")
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State6_StmtClose_Error_StmtClosePrepare() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State6,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(connmgrMockError).Once()
+			b.mockConn.On("ErrorClose").Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			require.EqualError(b.T(), err, connmgrMockError.Error())
+			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+			b.mockConn.AssertCalled(b.T(), "ErrorClose")
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State7_StmtClose_Success() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State7,
+		TargetState:  State3,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State7_StmtClose_Error_StmtClose() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State7,
+		TargetState:  State3,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("StmtClosePrepare", testStmtID).Return(connmgrMockError).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.StmtClose(ctx, testStmtID)
+			require.EqualError(b.T(), err, connmgrMockError.Error())
+			b.mockConn.AssertCalled(b.T(), "StmtClosePrepare", testStmtID)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State0_ResetSession_Success() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State0,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("Rollback").Return(nil).Once()
+			b.mockConn.On("SetAutoCommit", true).Return(nil).Once()
+			b.mockConn.On("PutBack").Return().Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.ResetSession(ctx)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertCalled(b.T(), "Rollback")
+			b.mockConn.AssertCalled(b.T(), "SetAutoCommit", true)
+			b.mockConn.AssertCalled(b.T(), "PutBack")
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State3_ResetSession_Error_Rollback() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State3,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("Rollback").Return(connmgrMockError).Once()
+			b.mockConn.On("ErrorClose").Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.ResetSession(ctx)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertCalled(b.T(), "Rollback")
+			b.mockConn.AssertNotCalled(b.T(), "SetAutoCommit", true)
+			b.mockConn.AssertCalled(b.T(), "ErrorClose")
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_ResetSession_Success() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State2,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.ResetSession(ctx)
+			require.NoError(b.T(), err)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State5_ResetSession_CloseConn() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State5,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("ErrorClose").Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			err := b.mockMgr.ResetSession(ctx)
+			require.NoError(b.T(), err)
+			b.mockConn.AssertNotCalled(b.T(), "Rollback")
+			b.mockConn.AssertCalled(b.T(), "ErrorClose")
+		},
+	}
+
+	tc.Run()
+}
+
+func TestBackendConnManagerTestSuite(t *testing.T) {
+	suite.Run(t, new(BackendConnManagerTestSuite))
+}
+
+func testLocalFileHandler(filename string, writeData func(data []byte) error) error {
+	return writeData([]byte(filename))
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_Query_LoadDataLocal() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State2,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.mockNs.On("GetPooledConn", mock.Anything).Return(b.mockConn, nil).Once()
+			b.mockConn.On("ExecuteLocalInfile", testSQL, mock.Anything).Return(queryResult, nil).Once()
+			b.mockConn.On("PutBack").Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			ctx = ctxWithLocalFileHandler(ctx, testLocalFileHandler)
+			ret, err := b.mockMgr.Query(ctx, testDB, testSQL)
+			require.NoError(b.T(), err)
+			require.Equal(b.T(), queryResult, ret)
+			b.mockConn.AssertNotCalled(b.T(), "Execute", testSQL)
+			b.mockConn.AssertCalled(b.T(), "PutBack")
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State1_Query_LoadDataLocal_Error() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State1,
+		TargetState:  State1,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.On("ExecuteLocalInfile", testSQL, mock.Anything).Return(nil, connmgrMockError).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			ctx = ctxWithLocalFileHandler(ctx, testLocalFileHandler)
+			_, err := b.mockMgr.Query(ctx, testDB, testSQL)
+			require.Equal(b.T(), connmgrMockError, err)
+			b.mockConn.AssertNotCalled(b.T(), "Execute", testSQL)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State1_Query_Error_SyncSessionVariables() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State1,
+		TargetState:  State1,
+		Prepare: func(ctx context.Context) {
+			b.mockConn.ExpectedCalls = nil
+			b.mockConn.On("SyncSessionVariables", ctx).Return(connmgrMockError).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			_, err := b.mockMgr.Query(ctx, testDB, testSQL)
+			require.Equal(b.T(), connmgrMockError, err)
+			b.mockConn.AssertCalled(b.T(), "SyncSessionVariables", ctx)
+			b.mockConn.AssertNotCalled(b.T(), "Execute", testSQL)
+		},
+	}
+
+	tc.Run()
+}
+
+type testResultsetStreamer struct {
+	columns [][]byte
+	rows    [][]byte
+	packets [][]byte
+	ended   bool
+	flushed bool
+}
+
+func (s *testResultsetStreamer) WriteColumns(columns [][]byte, serverStatus uint16) error {
+	s.columns = columns
+	return nil
+}
+
+func (s *testResultsetStreamer) WriteRow(row []byte) error {
+	s.rows = append(s.rows, append([]byte{}, row...))
+	return nil
+}
+
+func (s *testResultsetStreamer) WriteEnd(serverStatus uint16) error {
+	s.ended = true
+	return nil
+}
+
+func (s *testResultsetStreamer) WritePacket(data []byte) error {
+	s.packets = append(s.packets, append([]byte{}, data...))
+	return nil
+}
+
+func (s *testResultsetStreamer) Flush() error {
+	s.flushed = true
+	return nil
+}
+
+// runTestStreamHandlers returns a mock Run function, which passes a column and a row to the stream handlers.
+func runTestStreamHandlers(args mock.Arguments) {
+	_ = args.Get(1).(func([][]byte) error)([][]byte{[]byte("column")})
+	_ = args.Get(2).(func([]byte) error)([]byte("row"))
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_Query_Stream() {
+	streamer := &testResultsetStreamer{}
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State2,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.mockNs.On("GetPooledConn", mock.Anything).Return(b.mockConn, nil).Once()
+			b.mockConn.On("ExecuteStream", testSQL, mock.Anything, mock.Anything).
+				Run(runTestStreamHandlers).Return(queryResult, nil).Once()
+			b.mockConn.On("PutBack").Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			ctx = ctxWithResultsetStream(ctx, &resultsetStream{streamer: streamer})
+			ret, err := b.mockMgr.Query(ctx, testDB, testSQL)
+			require.NoError(b.T(), err)
+			require.Equal(b.T(), queryResult, ret)
+			require.Equal(b.T(), [][]byte{[]byte("column")}, streamer.columns)
+			require.Equal(b.T(), [][]byte{[]byte("row")}, streamer.rows)
+			b.mockConn.AssertNotCalled(b.T(), "Execute", testSQL)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_Query_ReadOnly_Stream_NoRetry() {
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State2,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.mockNs.On("GetPooledReadConn", mock.Anything).Return(b.mockConn, nil).Once()
+			b.mockConn.On("ExecuteStream", testSQL, mock.Anything, mock.Anything).
+				Run(runTestStreamHandlers).Return(nil, gomysql.ErrBadConn).Once()
+			b.mockConn.On("ErrorClose").Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			ctx = wast.CtxWithReadOnlyStmt(ctx, true)
+			ctx = ctxWithResultsetStream(ctx, &resultsetStream{streamer: &testResultsetStreamer{}})
+			_, err := b.mockMgr.Query(ctx, testDB, testSQL)
+			require.Equal(b.T(), gomysql.ErrBadConn, err)
+			// rows have been written to client, so it can't be retried
+			b.mockNs.AssertNumberOfCalls(b.T(), "GetPooledReadConn", 1)
+		},
+	}
+
+	tc.Run()
+}
+
+func (b *BackendConnManagerTestSuite) Test_State2_Query_Passthrough() {
+	streamer := &testResultsetStreamer{}
+	tc := &BackendConnManagerTestCase{
+		suite:        b,
+		CurrentState: State2,
+		TargetState:  State2,
+		Prepare: func(ctx context.Context) {
+			b.mockNs.On("GetPooledConn", mock.Anything).Return(b.mockConn, nil).Once()
+			b.mockConn.On("ExecutePassthrough", testSQL, mock.Anything).
+				Run(func(args mock.Arguments) {
+					_ = args.Get(1).(func([]byte) error)([]byte("packet"))
+				}).Return(queryResult, nil).Once()
+			b.mockConn.On("PutBack").Return(nil).Once()
+		},
+		RunAndAssert: func(ctx context.Context) {
+			stream := &resultsetStream{streamer: streamer, passthrough: true}
+			ret, err := b.mockMgr.Query(ctxWithResultsetStream(ctx, stream), testDB, testSQL)
+			require.NoError(b.T(), err)
+			require.Equal(b.T(), queryResult, ret)
+			require.True(b.T(), stream.started)
+			require.Equal(b.T(), [][]byte{[]byte("packet")}, streamer.packets)
+			b.mockConn.AssertNotCalled(b.T(), "ExecuteStream", testSQL, mock.Anything, mock.Anything)
+		},
+	}
+
+	tc.Run()
 }
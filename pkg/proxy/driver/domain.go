@@ -2,6 +2,7 @@ package driver
 
 import (
 	"context"
+	"time"
 
 	"github.com/siddontang/go-mysql/mysql"
 )
@@ -12,11 +13,19 @@ type NamespaceManager interface {
 
 type Namespace interface {
 	Name() string
+	IsHostAllowed(host string) bool
+	IsSecureTransportRequired() bool
+	IsPrepareEmulated() bool
+	IsLoadDataLocalAllowed() bool
+	IsPassthrough() bool
 	IsDatabaseAllowed(db string) bool
 	ListDatabases() []string
 	IsDeniedSQL(sqlFeature uint32) bool
 	IsAllowedSQL(sqlFeature uint32) bool
+	HasSQLList() bool
+	GetSlowSQLTime() time.Duration
 	GetPooledConn(context.Context) (PooledBackendConn, error)
+	GetPooledReadConn(context.Context) (PooledBackendConn, error)
 	IncrConnCount()
 	DescConnCount()
 	GetBreaker() (Breaker, error)
@@ -46,6 +55,12 @@ type PooledBackendConn interface {
 	// ErrorClose close conn and connpool create a new conn
 	// call this function when conn is broken.
 	ErrorClose() error
+
+	// GetAddr returns the addr of backend instance
+	GetAddr() string
+
+	// SyncSessionVariables sets the session variables in ctx to the conn
+	SyncSessionVariables(ctx context.Context) error
 	BackendConn
 }
 
@@ -59,11 +74,17 @@ type BackendConn interface {
 	UseDB(dbName string) error
 	GetDB() string
 	Execute(command string, args ...interface{}) (*mysql.Result, error)
+	ExecuteLocalInfile(query string, fileHandler func(filename string, writeData func(data []byte) error) error) (*mysql.Result, error)
+	ExecutePassthrough(query string, packetHandler func(data []byte) error) (*mysql.Result, error)
+	ExecuteStream(query string, columnHandler func(columns [][]byte) error, rowHandler func(row []byte) error) (*mysql.Result, error)
 	Begin() error
 	Commit() error
 	Rollback() error
 	StmtPrepare(sql string) (Stmt, error)
 	StmtExecuteForward(data []byte) (*mysql.Result, error)
+	StmtFetchForward(data []byte, rowHandler func(row []byte) error) (uint16, error)
+	StmtSendLongDataForward(data []byte) error
+	StmtReset(stmtId int) error
 	StmtClosePrepare(stmtId int) error
 	SetCharset(charset string) error
 	FieldList(table string, wildcard string) ([]*mysql.Field, error)
@@ -73,6 +94,8 @@ type BackendConn interface {
 	GetCharset() string
 	GetConnectionID() uint32
 	GetStatus() uint16
+	// GetWarnings returns the warning count of the last executed statement
+	GetWarnings() uint16
 }
 
 type Stmt interface {
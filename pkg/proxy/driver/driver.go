@@ -17,5 +17,7 @@ func NewDriverImpl(nsmgr NamespaceManager) *DriverImpl {
 }
 
 func (d *DriverImpl) OpenCtx(connID uint64, capability uint32, collation uint8, dbname string, tlsState *tls.ConnectionState) (server.QueryCtx, error) {
-	return NewQueryCtxImpl(d.nsmgr, connID), nil
+	q := NewQueryCtxImpl(d.nsmgr, connID)
+	q.SetClientCapability(capability)
+	return q, nil
 }
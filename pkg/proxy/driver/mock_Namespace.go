@@ -6,6 +6,8 @@ import (
 	context "context"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // MockNamespace is an autogenerated mock type for the Namespace type
@@ -13,6 +15,34 @@ type MockNamespace struct {
 	mock.Mock
 }
 
+// DescConnCount provides a mock function with given fields:
+func (_m *MockNamespace) DescConnCount() {
+	_m.Called()
+}
+
+// GetBreaker provides a mock function with given fields:
+func (_m *MockNamespace) GetBreaker() (Breaker, error) {
+	ret := _m.Called()
+
+	var r0 Breaker
+	if rf, ok := ret.Get(0).(func() Breaker); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(Breaker)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetPooledConn provides a mock function with given fields: _a0
 func (_m *MockNamespace) GetPooledConn(_a0 context.Context) (PooledBackendConn, error) {
 	ret := _m.Called(_a0)
@@ -36,6 +66,92 @@ func (_m *MockNamespace) GetPooledConn(_a0 context.Context) (PooledBackendConn,
 	return r0, r1
 }
 
+// GetPooledReadConn provides a mock function with given fields: _a0
+func (_m *MockNamespace) GetPooledReadConn(_a0 context.Context) (PooledBackendConn, error) {
+	ret := _m.Called(_a0)
+
+	var r0 PooledBackendConn
+	if rf, ok := ret.Get(0).(func(context.Context) PooledBackendConn); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(PooledBackendConn)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRateLimiter provides a mock function with given fields:
+func (_m *MockNamespace) GetRateLimiter() RateLimiter {
+	ret := _m.Called()
+
+	var r0 RateLimiter
+	if rf, ok := ret.Get(0).(func() RateLimiter); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(RateLimiter)
+		}
+	}
+
+	return r0
+}
+
+// GetSlowSQLTime provides a mock function with given fields:
+func (_m *MockNamespace) GetSlowSQLTime() time.Duration {
+	ret := _m.Called()
+
+	var r0 time.Duration
+	if rf, ok := ret.Get(0).(func() time.Duration); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(time.Duration)
+	}
+
+	return r0
+}
+
+// HasSQLList provides a mock function with given fields:
+func (_m *MockNamespace) HasSQLList() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// IncrConnCount provides a mock function with given fields:
+func (_m *MockNamespace) IncrConnCount() {
+	_m.Called()
+}
+
+// IsAllowedSQL provides a mock function with given fields: sqlFeature
+func (_m *MockNamespace) IsAllowedSQL(sqlFeature uint32) bool {
+	ret := _m.Called(sqlFeature)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(uint32) bool); ok {
+		r0 = rf(sqlFeature)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // IsDatabaseAllowed provides a mock function with given fields: db
 func (_m *MockNamespace) IsDatabaseAllowed(db string) bool {
 	ret := _m.Called(db)
@@ -50,6 +166,90 @@ func (_m *MockNamespace) IsDatabaseAllowed(db string) bool {
 	return r0
 }
 
+// IsDeniedSQL provides a mock function with given fields: sqlFeature
+func (_m *MockNamespace) IsDeniedSQL(sqlFeature uint32) bool {
+	ret := _m.Called(sqlFeature)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(uint32) bool); ok {
+		r0 = rf(sqlFeature)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// IsHostAllowed provides a mock function with given fields: host
+func (_m *MockNamespace) IsHostAllowed(host string) bool {
+	ret := _m.Called(host)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = rf(host)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// IsLoadDataLocalAllowed provides a mock function with given fields:
+func (_m *MockNamespace) IsLoadDataLocalAllowed() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// IsPassthrough provides a mock function with given fields:
+func (_m *MockNamespace) IsPassthrough() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// IsPrepareEmulated provides a mock function with given fields:
+func (_m *MockNamespace) IsPrepareEmulated() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// IsSecureTransportRequired provides a mock function with given fields:
+func (_m *MockNamespace) IsSecureTransportRequired() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // ListDatabases provides a mock function with given fields:
 func (_m *MockNamespace) ListDatabases() []string {
 	ret := _m.Called()
@@ -3,6 +3,8 @@
 package driver
 
 import (
+	context "context"
+
 	mysql "github.com/siddontang/go-mysql/mysql"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -80,6 +82,75 @@ func (_m *MockPooledBackendConn) Execute(command string, args ...interface{}) (*
 	return r0, r1
 }
 
+// ExecuteLocalInfile provides a mock function with given fields: query, fileHandler
+func (_m *MockPooledBackendConn) ExecuteLocalInfile(query string, fileHandler func(string, func([]byte) error) error) (*mysql.Result, error) {
+	ret := _m.Called(query, fileHandler)
+
+	var r0 *mysql.Result
+	if rf, ok := ret.Get(0).(func(string, func(string, func([]byte) error) error) *mysql.Result); ok {
+		r0 = rf(query, fileHandler)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*mysql.Result)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, func(string, func([]byte) error) error) error); ok {
+		r1 = rf(query, fileHandler)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ExecutePassthrough provides a mock function with given fields: query, packetHandler
+func (_m *MockPooledBackendConn) ExecutePassthrough(query string, packetHandler func([]byte) error) (*mysql.Result, error) {
+	ret := _m.Called(query, packetHandler)
+
+	var r0 *mysql.Result
+	if rf, ok := ret.Get(0).(func(string, func([]byte) error) *mysql.Result); ok {
+		r0 = rf(query, packetHandler)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*mysql.Result)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, func([]byte) error) error); ok {
+		r1 = rf(query, packetHandler)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ExecuteStream provides a mock function with given fields: query, columnHandler, rowHandler
+func (_m *MockPooledBackendConn) ExecuteStream(query string, columnHandler func([][]byte) error, rowHandler func([]byte) error) (*mysql.Result, error) {
+	ret := _m.Called(query, columnHandler, rowHandler)
+
+	var r0 *mysql.Result
+	if rf, ok := ret.Get(0).(func(string, func([][]byte) error, func([]byte) error) *mysql.Result); ok {
+		r0 = rf(query, columnHandler, rowHandler)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*mysql.Result)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, func([][]byte) error, func([]byte) error) error); ok {
+		r1 = rf(query, columnHandler, rowHandler)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // FieldList provides a mock function with given fields: table, wildcard
 func (_m *MockPooledBackendConn) FieldList(table string, wildcard string) ([]*mysql.Field, error) {
 	ret := _m.Called(table, wildcard)
@@ -103,6 +174,20 @@ func (_m *MockPooledBackendConn) FieldList(table string, wildcard string) ([]*my
 	return r0, r1
 }
 
+// GetAddr provides a mock function with given fields:
+func (_m *MockPooledBackendConn) GetAddr() string {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
 // GetCharset provides a mock function with given fields:
 func (_m *MockPooledBackendConn) GetCharset() string {
 	ret := _m.Called()
@@ -159,6 +244,20 @@ func (_m *MockPooledBackendConn) GetStatus() uint16 {
 	return r0
 }
 
+// GetWarnings provides a mock function with given fields:
+func (_m *MockPooledBackendConn) GetWarnings() uint16 {
+	ret := _m.Called()
+
+	var r0 uint16
+	if rf, ok := ret.Get(0).(func() uint16); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint16)
+	}
+
+	return r0
+}
+
 // IsAutoCommit provides a mock function with given fields:
 func (_m *MockPooledBackendConn) IsAutoCommit() bool {
 	ret := _m.Called()
@@ -285,6 +384,27 @@ func (_m *MockPooledBackendConn) StmtExecuteForward(data []byte) (*mysql.Result,
 	return r0, r1
 }
 
+// StmtFetchForward provides a mock function with given fields: data, rowHandler
+func (_m *MockPooledBackendConn) StmtFetchForward(data []byte, rowHandler func([]byte) error) (uint16, error) {
+	ret := _m.Called(data, rowHandler)
+
+	var r0 uint16
+	if rf, ok := ret.Get(0).(func([]byte, func([]byte) error) uint16); ok {
+		r0 = rf(data, rowHandler)
+	} else {
+		r0 = ret.Get(0).(uint16)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func([]byte, func([]byte) error) error); ok {
+		r1 = rf(data, rowHandler)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // StmtPrepare provides a mock function with given fields: sql
 func (_m *MockPooledBackendConn) StmtPrepare(sql string) (Stmt, error) {
 	ret := _m.Called(sql)
@@ -308,6 +428,48 @@ func (_m *MockPooledBackendConn) StmtPrepare(sql string) (Stmt, error) {
 	return r0, r1
 }
 
+// StmtReset provides a mock function with given fields: stmtId
+func (_m *MockPooledBackendConn) StmtReset(stmtId int) error {
+	ret := _m.Called(stmtId)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int) error); ok {
+		r0 = rf(stmtId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// StmtSendLongDataForward provides a mock function with given fields: data
+func (_m *MockPooledBackendConn) StmtSendLongDataForward(data []byte) error {
+	ret := _m.Called(data)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]byte) error); ok {
+		r0 = rf(data)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SyncSessionVariables provides a mock function with given fields: ctx
+func (_m *MockPooledBackendConn) SyncSessionVariables(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UseDB provides a mock function with given fields: dbName
 func (_m *MockPooledBackendConn) UseDB(dbName string) error {
 	ret := _m.Called(dbName)
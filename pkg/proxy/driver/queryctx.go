@@ -12,10 +12,13 @@ import (
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/tidb/sessionctx/variable"
 	"github.com/pingcap/tidb/util"
+	"github.com/pingcap/tidb/util/logutil"
 	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/tidb-incubator/weir/pkg/proxy/metrics"
 	"github.com/tidb-incubator/weir/pkg/proxy/server"
 	wast "github.com/tidb-incubator/weir/pkg/util/ast"
 	cb "github.com/tidb-incubator/weir/pkg/util/rate_limit_breaker/circuit_breaker"
+	"go.uber.org/zap"
 )
 
 // Server information.
@@ -38,10 +41,14 @@ type QueryCtxImpl struct {
 	connId      uint64
 	nsmgr       NamespaceManager
 	ns          Namespace
+	user        *auth.UserIdentity
 	currentDB   string
 	parser      *parser.Parser
 	sessionVars *SessionVarsWrapper
 
+	localFileHandler  server.LocalFileHandler
+	resultsetStreamer server.ResultsetStreamer
+
 	connMgr *BackendConnManager
 }
 
@@ -95,38 +102,102 @@ func (*QueryCtxImpl) RollbackTxn() {
 	return
 }
 
-// TODO(eastfisher): implement this function
-func (*QueryCtxImpl) WarningCount() uint16 {
-	return 0
+func (q *QueryCtxImpl) WarningCount() uint16 {
+	return q.sessionVars.WarningCount()
 }
 
 func (q *QueryCtxImpl) CurrentDB() string {
 	return q.currentDB
 }
 
-func (q *QueryCtxImpl) Execute(ctx context.Context, sql string) (*gomysql.Result, error) {
+// Execute executes the statements in sql in order and stops at the first failed one.
+// sql may contain multiple statements only if the client sets CLIENT_MULTI_STATEMENTS.
+// The result set of a single statement is streamed to client and not returned, and so is the whole response
+// in passthrough mode, while those of multiple statements are returned to be written in order.
+func (q *QueryCtxImpl) Execute(ctx context.Context, sql string) ([]*server.StmtResult, error) {
 	charsetInfo, collation := q.sessionVars.GetCharsetInfo()
-	stmt, err := q.parser.ParseOneStmt(sql, charsetInfo, collation)
+	stmts, _, err := q.parser.Parse(sql, charsetInfo, collation)
 	if err != nil {
 		return nil, err
 	}
+	if len(stmts) == 0 || (len(stmts) > 1 && !q.isMultiStatementsEnabled()) {
+		return nil, parser.ErrSyntax
+	}
+	// the parser is reused when extracting sql paradigm, so the statements are copied out of it
+	stmts = append([]ast.StmtNode(nil), stmts...)
+
+	var stream *resultsetStream
+	if len(stmts) == 1 && q.resultsetStreamer != nil {
+		stream = &resultsetStream{
+			streamer:    q.resultsetStreamer,
+			status:      q.Status(),
+			passthrough: q.isPassthroughEnabled(),
+		}
+		ctx = ctxWithResultsetStream(ctx, stream)
+	}
+
+	rets := make([]*server.StmtResult, 0, len(stmts))
+	for _, stmt := range stmts {
+		stmtSQL := sql
+		if len(stmts) > 1 {
+			stmtSQL = stmt.Text()
+		}
+		ret, err := q.executeOneStmtWithWarnings(ctx, stmtSQL, stmt)
+		if err != nil {
+			return rets, err
+		}
+		if stream != nil && stream.started {
+			return rets, stream.end(q.Status())
+		}
+		rets = append(rets, q.newStmtResult(ret))
+	}
+	return rets, nil
+}
 
+func (q *QueryCtxImpl) isMultiStatementsEnabled() bool {
+	return q.sessionVars.GetClientCapability()&mysql.ClientMultiStatements > 0
+}
+
+// isPassthroughEnabled returns whether the backend packets can be relayed to client as they are,
+// which requires client to use the same protocol as the backend conns.
+func (q *QueryCtxImpl) isPassthroughEnabled() bool {
+	return q.ns.IsPassthrough() && q.sessionVars.GetClientCapability()&mysql.ClientProtocol41 > 0
+}
+
+// newStmtResult fills the session status into ret, or builds an OK result if the statement returns no result set.
+// The info message and warning count of the statement are kept with the result.
+func (q *QueryCtxImpl) newStmtResult(ret *gomysql.Result) *server.StmtResult {
+	if ret == nil {
+		ret = &gomysql.Result{
+			AffectedRows: q.AffectedRows(),
+			InsertId:     q.LastInsertID(),
+		}
+	}
+	ret.Status = q.Status()
+	return &server.StmtResult{Result: ret, Message: q.LastMessage(), Warnings: q.WarningCount()}
+}
+
+func (q *QueryCtxImpl) executeOneStmt(ctx context.Context, sql string, stmt ast.StmtNode) (*gomysql.Result, error) {
 	tableName := wast.ExtractFirstTableNameFromStmt(stmt)
 	ctx = wast.CtxWithAstTableName(ctx, tableName)
 
-	sqlParadigm, err := extractStmtParadigm(stmt)
-	if err != nil {
-		return nil, err
-	}
-	sqlDigest := crc32.ChecksumIEEE([]byte(sqlParadigm))
+	// extracting sql paradigm restores the whole statement, so it's skipped if there is no sql list to check
+	var sqlDigest uint32
+	if q.ns.HasSQLList() {
+		sqlParadigm, err := extractStmtParadigm(stmt)
+		if err != nil {
+			return nil, err
+		}
+		sqlDigest = crc32.ChecksumIEEE([]byte(sqlParadigm))
 
-	if q.isStmtDenied(ctx, sqlDigest) {
-		q.recordDeniedQueryMetrics(ctx, stmt)
-		return nil, mysql.NewErrf(mysql.ErrUnknown, "statement is denied")
-	}
+		if q.isStmtDenied(ctx, sqlDigest) {
+			q.recordDeniedQueryMetrics(ctx, stmt)
+			return nil, mysql.NewErrf(mysql.ErrUnknown, "statement is denied")
+		}
 
-	if q.isStmtAllowed(ctx, sqlDigest) {
-		return q.execute(ctx, sql, stmt)
+		if q.isStmtAllowed(ctx, sqlDigest) {
+			return q.execute(ctx, sql, stmt)
+		}
 	}
 
 	if !q.isStmtNeedToCheckCircuitBreaking(stmt) {
@@ -183,10 +254,13 @@ func (q *QueryCtxImpl) executeWithBreakerInterceptor(ctx context.Context, stmtNo
 }
 
 func (q *QueryCtxImpl) execute(ctx context.Context, sql string, stmtNode ast.StmtNode) (*gomysql.Result, error) {
+	ctx, backendAddr := ctxWithBackendAddrRecorder(ctx)
 	startTime := time.Now()
 	ret, err := q.executeStmt(ctx, sql, stmtNode)
-	durationMilliSecond := float64(time.Since(startTime)) / float64(time.Second)
+	duration := time.Since(startTime)
+	durationMilliSecond := float64(duration) / float64(time.Second)
 	q.recordQueryMetrics(ctx, stmtNode, err, durationMilliSecond)
+	q.recordSlowQuery(sql, startTime, duration, backendAddr.addr, err)
 	return ret, err
 }
 
@@ -199,8 +273,16 @@ func (q *QueryCtxImpl) SetClientCapability(capability uint32) {
 	q.sessionVars.SetClientCapability(capability)
 }
 
+func (q *QueryCtxImpl) SetLocalFileHandler(handler server.LocalFileHandler) {
+	q.localFileHandler = handler
+}
+
+func (q *QueryCtxImpl) SetResultsetStreamer(streamer server.ResultsetStreamer) {
+	q.resultsetStreamer = streamer
+}
+
 func (q *QueryCtxImpl) Prepare(ctx context.Context, sql string) (stmtId int, columns, params []*server.ColumnInfo, err error) {
-	stmt, err := q.connMgr.StmtPrepare(ctx, q.currentDB, sql)
+	stmt, err := q.connMgr.StmtPrepare(q.ctxWithSessionVars(ctx), q.currentDB, sql)
 	if err != nil {
 		return -1, nil, nil, err
 	}
@@ -211,7 +293,21 @@ func (q *QueryCtxImpl) Prepare(ctx context.Context, sql string) (stmtId int, col
 }
 
 func (q *QueryCtxImpl) StmtExecuteForward(ctx context.Context, stmtId int, data []byte) (*gomysql.Result, error) {
-	return q.connMgr.StmtExecuteForward(ctx, stmtId, data)
+	return q.executeWithWarnings(q.ctxWithSessionVars(ctx), func(ctx context.Context) (*gomysql.Result, error) {
+		return q.connMgr.StmtExecuteForward(ctx, stmtId, data)
+	})
+}
+
+func (q *QueryCtxImpl) StmtFetch(ctx context.Context, stmtId int, data []byte, rowHandler func(row []byte) error) (uint16, error) {
+	return q.connMgr.StmtFetch(ctx, stmtId, data, rowHandler)
+}
+
+func (q *QueryCtxImpl) StmtSendLongData(ctx context.Context, stmtId int, data []byte) error {
+	return q.connMgr.StmtSendLongData(ctx, stmtId, data)
+}
+
+func (q *QueryCtxImpl) StmtReset(ctx context.Context, stmtId int) error {
+	return q.connMgr.StmtReset(ctx, stmtId)
 }
 
 func (q *QueryCtxImpl) StmtClose(ctx context.Context, stmtId int) error {
@@ -219,7 +315,7 @@ func (q *QueryCtxImpl) StmtClose(ctx context.Context, stmtId int) error {
 }
 
 func (q *QueryCtxImpl) FieldList(tableName string) ([]*server.ColumnInfo, error) {
-	conn, err := q.ns.GetPooledConn(context.Background())
+	conn, err := q.ns.GetPooledConn(q.ctxWithSessionVars(context.Background()))
 	if err != nil {
 		return nil, err
 	}
@@ -253,12 +349,31 @@ func (q *QueryCtxImpl) Auth(user *auth.UserIdentity, pwd []byte, salt []byte) bo
 	if !ok {
 		return false
 	}
+	if !ns.IsHostAllowed(user.Hostname) {
+		metrics.QueryCtxHostDeniedCounter.WithLabelValues(ns.Name()).Inc()
+		logutil.BgLogger().Warn("client host is not allowed", zap.String("namespace", ns.Name()),
+			zap.String("username", user.Username), zap.String("host", user.Hostname))
+		return false
+	}
 	q.ns = ns
+	q.user = user
 	q.initAttachedConnHolder()
 	q.ns.IncrConnCount()
 	return true
 }
 
+// ResetSession resets session state without re-authentication, current db is kept as MySQL does.
+func (q *QueryCtxImpl) ResetSession(ctx context.Context) error {
+	capability := q.sessionVars.GetClientCapability()
+	q.sessionVars = NewSessionVarsWrapper(variable.NewSessionVars())
+	q.sessionVars.SetClientCapability(capability)
+	return q.connMgr.ResetSession(ctx)
+}
+
+func (q *QueryCtxImpl) IsSecureTransportRequired() bool {
+	return q.ns.IsSecureTransportRequired()
+}
+
 // TODO(eastfisher): does weir need to support show processlist?
 func (*QueryCtxImpl) ShowProcess() *util.ProcessInfo {
 	return nil
@@ -2,11 +2,13 @@ package driver
 
 import (
 	"context"
+	"fmt"
 	"hash/crc32"
 	"strings"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/format"
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/tidb/sessionctx/variable"
 	"github.com/pingcap/tidb/util/logutil"
@@ -80,7 +82,8 @@ func (q *QueryCtxImpl) extractSqlParadigm(ctx context.Context, sql string) (stri
 func (q *QueryCtxImpl) executeStmt(ctx context.Context, sql string, stmtNode ast.StmtNode) (*gomysql.Result, error) {
 	switch stmt := stmtNode.(type) {
 	case *ast.SetStmt:
-		return nil, q.setVariable(ctx, stmt)
+		// the SET statement is executed by proxy, responses of the backend queries are not sent to client
+		return nil, q.setVariable(ctxWithResultsetStream(ctx, nil), stmt)
 	case *ast.UseStmt:
 		return nil, q.useDB(ctx, stmt.DBName)
 	case *ast.ShowStmt:
@@ -91,6 +94,8 @@ func (q *QueryCtxImpl) executeStmt(ctx context.Context, sql string, stmtNode ast
 		return nil, q.commitOrRollback(ctx, true)
 	case *ast.RollbackStmt:
 		return nil, q.commitOrRollback(ctx, false)
+	case *ast.LoadDataStmt:
+		return q.executeLoadData(ctx, sql, stmt)
 	default:
 		return q.executeInBackend(ctx, sql, stmtNode)
 	}
@@ -102,6 +107,8 @@ func (q *QueryCtxImpl) executeShowStmt(ctx context.Context, sql string, stmt *as
 		databases := q.ns.ListDatabases()
 		result, err := createShowDatabasesResult(databases)
 		return result, err
+	case ast.ShowWarnings, ast.ShowErrors:
+		return createShowWarningsResult(q.sessionVars.GetWarnings(), stmt.Tp == ast.ShowErrors)
 	default:
 		return q.executeInBackend(ctx, sql, stmt)
 	}
@@ -112,8 +119,11 @@ func createShowDatabasesResult(dbNames []string) (*gomysql.Result, error) {
 	for _, db := range dbNames {
 		values = append(values, []interface{}{db})
 	}
+	return createSimpleTextResult([]string{"Database"}, values)
+}
 
-	rs, err := gomysql.BuildSimpleTextResultset([]string{"Database"}, values)
+func createSimpleTextResult(names []string, values [][]interface{}) (*gomysql.Result, error) {
+	rs, err := gomysql.BuildSimpleTextResultset(names, values)
 	if err != nil {
 		return nil, err
 	}
@@ -145,7 +155,18 @@ func createShowDatabasesResult(dbNames []string) (*gomysql.Result, error) {
 }
 
 func (q *QueryCtxImpl) executeInBackend(ctx context.Context, sql string, stmtNode ast.StmtNode) (*gomysql.Result, error) {
-	ctx = context.WithValue(ctx, constant.ContextKeySessionVariable, q.sessionVars.GetAllSystemVars())
+	if wast.IsUserVarOrLastInsertIDUsed(stmtNode) {
+		// reading the lost user variables silently gets NULL, so the first statement reading them fails instead
+		if q.connMgr.TakeUserVarsLost() && wast.IsUserVarRead(stmtNode) {
+			return nil, errUserVarsLost
+		}
+		if err := q.pinBackendConn(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx = q.ctxWithSessionVars(ctx)
+	ctx = wast.CtxWithReadOnlyStmt(ctx, wast.IsReadOnlyStmt(stmtNode))
 
 	result, err := q.connMgr.Query(ctx, q.currentDB, sql)
 	if err != nil {
@@ -154,6 +175,9 @@ func (q *QueryCtxImpl) executeInBackend(ctx context.Context, sql string, stmtNod
 
 	q.sessionVars.SetAffectRows(result.AffectedRows)
 	q.sessionVars.SetLastInsertID(result.InsertId)
+	if result.InsertId != 0 {
+		q.sessionVars.SetLastGeneratedID(result.InsertId)
+	}
 
 	if result.Resultset == nil {
 		return nil, nil
@@ -162,6 +186,52 @@ func (q *QueryCtxImpl) executeInBackend(ctx context.Context, sql string, stmtNod
 	return result, nil
 }
 
+func (q *QueryCtxImpl) executeLoadData(ctx context.Context, sql string, stmt *ast.LoadDataStmt) (*gomysql.Result, error) {
+	if db := stmt.Table.Schema.O; db != "" && !q.ns.IsDatabaseAllowed(db) {
+		return nil, mysql.NewErrf(mysql.ErrDBaccessDenied, "db %s access denied", db)
+	}
+	if !stmt.IsLocal {
+		return q.executeInBackend(ctx, sql, stmt)
+	}
+
+	if !q.ns.IsLoadDataLocalAllowed() || !q.isLocalFilesEnabled() || q.localFileHandler == nil {
+		return nil, mysql.NewErr(mysql.ErrNotAllowedCommand)
+	}
+	ctx = ctxWithLocalFileHandler(ctx, q.localFileHandler)
+	return q.executeInBackend(ctx, sql, stmt)
+}
+
+func (q *QueryCtxImpl) isLocalFilesEnabled() bool {
+	return q.sessionVars.GetClientCapability()&mysql.ClientLocalFiles > 0
+}
+
+var errUserVarsLost = mysql.NewErrf(mysql.ErrUnknown, "user variables are lost since the pinned backend conn is closed on error")
+
+// pinBackendConn pins a backend conn to the session once it uses user variables or LAST_INSERT_ID(),
+// because they are kept by the backend session and pooled conns can't see the values set on each other.
+// The last generated id is restored on the newly pinned conn, so LAST_INSERT_ID() keeps consistent with
+// the insert ids returned before.
+func (q *QueryCtxImpl) pinBackendConn(ctx context.Context) error {
+	if q.connMgr.IsConnPinned() {
+		return nil
+	}
+	ctx = ctxWithResultsetStream(q.ctxWithSessionVars(ctx), nil)
+	if err := q.connMgr.PinConn(ctx); err != nil {
+		return err
+	}
+	lastGeneratedID := q.sessionVars.LastGeneratedID()
+	if lastGeneratedID == 0 {
+		return nil
+	}
+	_, err := q.connMgr.Query(ctx, q.currentDB, fmt.Sprintf("SET last_insert_id = %d", lastGeneratedID))
+	return err
+}
+
+// ctxWithSessionVars puts session variables into ctx, so that they are synced to the backend conn serving the request.
+func (q *QueryCtxImpl) ctxWithSessionVars(ctx context.Context) context.Context {
+	return context.WithValue(ctx, constant.ContextKeySessionVariable, q.sessionVars.GetAllSystemVars())
+}
+
 func (q *QueryCtxImpl) useDB(ctx context.Context, db string) error {
 	if !q.ns.IsDatabaseAllowed(db) {
 		return mysql.NewErrf(mysql.ErrDBaccessDenied, "db %s access denied", db)
@@ -170,19 +240,27 @@ func (q *QueryCtxImpl) useDB(ctx context.Context, db string) error {
 	return nil
 }
 
+// setVariable stores session system variables in session once backend accepts their values,
+// they are synced to the backend conn serving each statement.
+// Global variables can't be set through proxy since they affect all the sessions of backend.
+// User variables are set on the pinned backend conn.
 func (q *QueryCtxImpl) setVariable(ctx context.Context, stmt *ast.SetStmt) error {
 	var autoCommitVar *ast.VariableAssignment
-	var sysVars []*ast.VariableAssignment
+	var sysVars, userVars []*ast.VariableAssignment
 
 	for _, v := range stmt.Variables {
+		if !isSysVarAssignment(v) {
+			userVars = append(userVars, v)
+			continue
+		}
+		if v.IsGlobal {
+			return mysql.NewErrf(mysql.ErrSpecificAccessDenied, "setting global variable %s through proxy is not allowed", v.Name)
+		}
 		switch strings.ToLower(v.Name) {
 		case variable.AutoCommit:
 			autoCommitVar = v
-			//default:
-			//	if v.IsGlobal {
-			//		return errors.Errorf("cannot set variable in global scope")
-			//	}
-			//	sysVars = append(sysVars, v)
+		default:
+			sysVars = append(sysVars, v)
 		}
 	}
 
@@ -198,28 +276,64 @@ func (q *QueryCtxImpl) setVariable(ctx context.Context, stmt *ast.SetStmt) error
 		}
 	}
 
+	if len(userVars) != 0 {
+		return q.setUserVars(ctx, userVars)
+	}
+
 	return nil
 }
 
+// isSysVarAssignment returns false for user variables.
+func isSysVarAssignment(v *ast.VariableAssignment) bool {
+	return v.IsSystem || v.Name == ast.SetNames || v.Name == ast.SetCharset
+}
+
 // set other system variables except autocommit
 func (q *QueryCtxImpl) setSysVars(ctx context.Context, vars []*ast.VariableAssignment) error {
 	for _, v := range vars {
 		if err := q.sessionVars.CheckSessionSysVarValid(v.Name); err != nil {
-			return errors.WithMessage(err, "check sysvar error")
+			return err
+		}
+	}
+
+	// the values are checked by backend before they are stored in session
+	sysVars := q.sessionVars.GetAllSystemVars()
+	for _, v := range vars {
+		name := strings.ToLower(v.Name)
+		if _, ok := v.Value.(*ast.DefaultExpr); ok {
+			delete(sysVars, name)
+		} else {
+			sysVars[name] = v
 		}
 	}
+	if err := q.connMgr.SyncSessionVariables(context.WithValue(ctx, constant.ContextKeySessionVariable, sysVars)); err != nil {
+		return err
+	}
 
 	for _, v := range vars {
+		name := strings.ToLower(v.Name)
 		if _, ok := v.Value.(*ast.DefaultExpr); ok {
-			q.sessionVars.SetSystemVarDefault(v.Name)
+			q.sessionVars.SetSystemVarDefault(name)
 		} else {
-			q.sessionVars.SetSystemVarAST(v.Name, v)
+			q.sessionVars.SetSystemVarAST(name, v)
 		}
 	}
 
 	return nil
 }
 
+// setUserVars executes SET statement of user variables only on the pinned conn,
+// system variables in the same statement are handled separately.
+func (q *QueryCtxImpl) setUserVars(ctx context.Context, vars []*ast.VariableAssignment) error {
+	stmt := &ast.SetStmt{Variables: vars}
+	sb := &strings.Builder{}
+	if err := stmt.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags, sb)); err != nil {
+		return err
+	}
+	_, err := q.executeInBackend(ctx, sb.String(), stmt)
+	return err
+}
+
 func (q *QueryCtxImpl) setAutoCommit(ctx context.Context, v *ast.VariableAssignment) error {
 	var err error
 	autocommit, err := getAutoCommitValue(v.Value)
@@ -227,7 +341,7 @@ func (q *QueryCtxImpl) setAutoCommit(ctx context.Context, v *ast.VariableAssignm
 		return err
 	}
 
-	err = q.connMgr.SetAutoCommit(ctx, autocommit)
+	err = q.connMgr.SetAutoCommit(q.ctxWithSessionVars(ctx), autocommit)
 	q.connMgr.MergeStatus(q.sessionVars)
 	return err
 }
@@ -248,7 +362,7 @@ func getAutoCommitValue(v ast.ExprNode) (bool, error) {
 }
 
 func (q *QueryCtxImpl) begin(ctx context.Context) error {
-	err := q.connMgr.Begin(ctx)
+	err := q.connMgr.Begin(q.ctxWithSessionVars(ctx))
 	q.connMgr.MergeStatus(q.sessionVars)
 	return err
 }
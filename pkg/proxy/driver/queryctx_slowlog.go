@@ -0,0 +1,60 @@
+package driver
+
+import (
+	"context"
+	"time"
+
+	"github.com/pingcap/parser"
+	"github.com/pingcap/tidb/util/logutil"
+	"github.com/tidb-incubator/weir/pkg/proxy/constant"
+	"github.com/tidb-incubator/weir/pkg/proxy/slowlog"
+	"go.uber.org/zap"
+)
+
+const ctxBackendAddrRecorderKey = constant.ContextKeyPrefix + "backend_addr_recorder"
+
+// backendAddrRecorder records the addr of backend instance which executes the statement,
+// the addr is only known by BackendConnManager.
+type backendAddrRecorder struct {
+	addr string
+}
+
+func ctxWithBackendAddrRecorder(ctx context.Context) (context.Context, *backendAddrRecorder) {
+	recorder := &backendAddrRecorder{}
+	return context.WithValue(ctx, ctxBackendAddrRecorderKey, recorder), recorder
+}
+
+func recordBackendAddr(ctx context.Context, conn PooledBackendConn) {
+	if recorder, ok := ctx.Value(ctxBackendAddrRecorderKey).(*backendAddrRecorder); ok {
+		recorder.addr = conn.GetAddr()
+	}
+}
+
+func (q *QueryCtxImpl) recordSlowQuery(sql string, startTime time.Time, duration time.Duration, backendAddr string, err error) {
+	threshold := q.ns.GetSlowSQLTime()
+	if threshold <= 0 || duration < threshold || !slowlog.Enabled() {
+		return
+	}
+
+	normalizedSQL, digest := parser.NormalizeDigest(sql)
+	entry := &slowlog.Entry{
+		Time:          startTime,
+		Namespace:     q.ns.Name(),
+		ConnID:        q.connId,
+		DB:            q.currentDB,
+		Digest:        digest,
+		NormalizedSQL: normalizedSQL,
+		BackendAddr:   backendAddr,
+		QueryTime:     duration,
+		Succ:          err == nil,
+		SQL:           sql,
+	}
+	if q.user != nil {
+		entry.User = q.user.Username
+		entry.Host = q.user.Hostname
+	}
+
+	if err := slowlog.Log(entry); err != nil {
+		logutil.BgLogger().Warn("write slow log error", zap.String("namespace", entry.Namespace), zap.Error(err))
+	}
+}
@@ -0,0 +1,532 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/auth"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tidb-incubator/weir/pkg/proxy/constant"
+)
+
+func prepareAuthQueryCtx(hostAllowed bool) (*QueryCtxImpl, *MockNamespace) {
+	ns := new(MockNamespace)
+	ns.On("Name").Return("test_ns")
+	ns.On("IsHostAllowed", "127.0.0.1").Return(hostAllowed)
+	ns.On("IncrConnCount").Return()
+	ns.On("IsPrepareEmulated").Return(false)
+	nsmgr := new(MockNamespaceManager)
+	nsmgr.On("Auth", "user", []byte("pwd"), []byte("salt")).Return(ns, true)
+	return NewQueryCtxImpl(nsmgr, 1), ns
+}
+
+func TestQueryCtxImpl_Auth_HostAllowed(t *testing.T) {
+	q, ns := prepareAuthQueryCtx(true)
+	user := &auth.UserIdentity{Username: "user", Hostname: "127.0.0.1"}
+	require.True(t, q.Auth(user, []byte("pwd"), []byte("salt")))
+	ns.AssertCalled(t, "IncrConnCount")
+}
+
+func TestQueryCtxImpl_Auth_HostDenied(t *testing.T) {
+	q, ns := prepareAuthQueryCtx(false)
+	user := &auth.UserIdentity{Username: "user", Hostname: "127.0.0.1"}
+	require.False(t, q.Auth(user, []byte("pwd"), []byte("salt")))
+	require.Nil(t, q.ns)
+	ns.AssertNotCalled(t, "IncrConnCount")
+}
+
+func TestRecordBackendAddr(t *testing.T) {
+	conn := new(MockPooledBackendConn)
+	conn.On("GetAddr").Return("127.0.0.1:4000")
+
+	// without recorder in ctx
+	recordBackendAddr(context.Background(), conn)
+	conn.AssertNotCalled(t, "GetAddr")
+
+	ctx, recorder := ctxWithBackendAddrRecorder(context.Background())
+	recordBackendAddr(ctx, conn)
+	require.Equal(t, "127.0.0.1:4000", recorder.addr)
+}
+
+func TestRecordBackendWarnings(t *testing.T) {
+	warnings, err := createSimpleTextResult([]string{"Level", "Code", "Message"}, [][]interface{}{
+		{"Warning", int64(1265), "Data truncated for column 'a' at row 1"},
+		{"Note", int64(1051), "Unknown table 'test_db.t'"},
+	})
+	require.NoError(t, err)
+	conn := new(MockPooledBackendConn)
+	conn.On("GetWarnings").Return(uint16(70))
+	conn.On("Execute", "SHOW WARNINGS").Return(warnings, nil).Once()
+
+	// without recorder in ctx
+	recordBackendWarnings(context.Background(), conn)
+	conn.AssertNotCalled(t, "GetWarnings")
+
+	// the count is kept even though backend returns part of the warnings
+	ctx, recorder := ctxWithWarningsRecorder(context.Background())
+	recordBackendWarnings(ctx, conn)
+	require.Equal(t, uint16(70), recorder.count)
+	require.Len(t, recorder.warns, 2)
+	require.Equal(t, stmtctx.WarnLevelWarning, recorder.warns[0].Level)
+	require.Equal(t, uint16(1265), toSQLError(recorder.warns[0].Err).Code)
+	require.Equal(t, "Unknown table 'test_db.t'", toSQLError(recorder.warns[1].Err).Message)
+}
+
+func TestQueryCtxImpl_Execute_Warnings(t *testing.T) {
+	q, ns := prepareExecuteQueryCtx()
+	ns.On("IsDeniedSQL", mock.Anything).Return(false)
+	warnings, err := createSimpleTextResult([]string{"Level", "Code", "Message"}, [][]interface{}{
+		{"Warning", int64(1265), "Data truncated for column 'a' at row 1"},
+	})
+	require.NoError(t, err)
+	conn := new(MockPooledBackendConn)
+	conn.On("UseDB", "").Return(nil)
+	conn.On("GetAddr").Return("127.0.0.1:4000")
+	conn.On("PutBack").Return()
+	conn.On("Execute", "insert into t values ('abc')").Return(&gomysql.Result{AffectedRows: 1}, nil).Once()
+	conn.On("GetWarnings").Return(uint16(1)).Once()
+	conn.On("Execute", "SHOW WARNINGS").Return(warnings, nil).Once()
+	conn.On("Execute", "select a from t").Return(nil, &gomysql.MyError{Code: 1146, Message: "Table 'test_db.t' doesn't exist"}).Once()
+	ns.On("GetPooledConn", mock.Anything).Return(conn, nil)
+	ns.On("GetPooledReadConn", mock.Anything).Return(conn, nil)
+
+	rets, err := q.Execute(context.Background(), "insert into t values ('abc')")
+	require.NoError(t, err)
+	require.Len(t, rets, 1)
+	require.Equal(t, uint16(1), q.WarningCount())
+
+	// SHOW WARNINGS runs in proxy, and it doesn't clear the warnings
+	for i := 0; i < 2; i++ {
+		rets, err = q.Execute(context.Background(), "show warnings")
+		require.NoError(t, err)
+		require.Equal(t, 1, rets[0].RowNumber())
+		code, err := rets[0].GetInt(0, 1)
+		require.NoError(t, err)
+		require.Equal(t, int64(1265), code)
+	}
+	rets, err = q.Execute(context.Background(), "show errors")
+	require.NoError(t, err)
+	require.Equal(t, 0, rets[0].RowNumber())
+
+	// the failure is kept as an error
+	_, err = q.Execute(context.Background(), "select a from t")
+	require.Error(t, err)
+	rets, err = q.Execute(context.Background(), "show errors")
+	require.NoError(t, err)
+	require.Equal(t, 1, rets[0].RowNumber())
+	level, err := rets[0].GetString(0, 0)
+	require.NoError(t, err)
+	require.Equal(t, stmtctx.WarnLevelError, level)
+	code, err := rets[0].GetInt(0, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(1146), code)
+
+	// statements executed in proxy clear the warnings too
+	_, err = q.Execute(context.Background(), "use test_db")
+	require.NoError(t, err)
+	require.Equal(t, uint16(0), q.WarningCount())
+	rets, err = q.Execute(context.Background(), "show warnings")
+	require.NoError(t, err)
+	require.Equal(t, 0, rets[0].RowNumber())
+	conn.AssertExpectations(t)
+}
+
+func TestQueryCtxImpl_ResetSession(t *testing.T) {
+	ns := new(MockNamespace)
+	ns.On("Name").Return("test_ns")
+	ns.On("IsPrepareEmulated").Return(false)
+	q := NewQueryCtxImpl(new(MockNamespaceManager), 1)
+	q.ns = ns
+	q.currentDB = "test_db"
+	q.initAttachedConnHolder()
+	q.SetClientCapability(mysql.ClientProtocol41)
+	q.sessionVars.SetSystemVarAST("sql_mode", &ast.VariableAssignment{Name: "sql_mode"})
+	q.sessionVars.SetAffectRows(1)
+
+	require.NoError(t, q.ResetSession(context.Background()))
+	require.Empty(t, q.sessionVars.GetAllSystemVars())
+	require.Equal(t, uint64(0), q.AffectedRows())
+	require.Equal(t, uint32(mysql.ClientProtocol41), q.sessionVars.GetClientCapability())
+	require.Equal(t, "test_db", q.CurrentDB())
+	require.Equal(t, stateInitial, q.connMgr.state)
+}
+
+func prepareExecuteQueryCtx() (*QueryCtxImpl, *MockNamespace) {
+	ns := new(MockNamespace)
+	ns.On("Name").Return("test_ns")
+	ns.On("IsPrepareEmulated").Return(false)
+	ns.On("HasSQLList").Return(true)
+	ns.On("IsAllowedSQL", mock.Anything).Return(true)
+	ns.On("IsDatabaseAllowed", "test_db").Return(true)
+	ns.On("ListDatabases").Return([]string{"test_db"})
+	ns.On("GetSlowSQLTime").Return(time.Duration(0))
+	q := NewQueryCtxImpl(new(MockNamespaceManager), 1)
+	q.ns = ns
+	q.initAttachedConnHolder()
+	return q, ns
+}
+
+func TestQueryCtxImpl_StmtExecuteForward_Warnings(t *testing.T) {
+	q, ns := prepareExecuteQueryCtx()
+	ns.On("IsDeniedSQL", mock.Anything).Return(false)
+	warnings, err := createSimpleTextResult([]string{"Level", "Code", "Message"}, [][]interface{}{
+		{"Warning", int64(1265), "Data truncated for column 'a' at row 1"},
+	})
+	require.NoError(t, err)
+	data := []byte{1, 0, 0, 0, 0, 1, 0, 0, 0}
+	conn := new(MockPooledBackendConn)
+	conn.On("SyncSessionVariables", mock.Anything).Return(nil)
+	conn.On("StmtExecuteForward", data).Return(&gomysql.Result{AffectedRows: 1}, nil).Once()
+	conn.On("GetWarnings").Return(uint16(1)).Once()
+	conn.On("Execute", "SHOW WARNINGS").Return(warnings, nil).Once()
+	conn.On("StmtExecuteForward", data).Return(nil, &gomysql.MyError{Code: 1146, Message: "Table 'test_db.t' doesn't exist"}).Once()
+	q.connMgr.state = State4
+	q.connMgr.txnConn = conn
+	q.connMgr.addStmtID(1)
+
+	// the warnings are read from the conn holding the prepared statement
+	_, err = q.StmtExecuteForward(context.Background(), 1, data)
+	require.NoError(t, err)
+	require.Equal(t, uint16(1), q.WarningCount())
+	rets, err := q.Execute(context.Background(), "show warnings")
+	require.NoError(t, err)
+	require.Equal(t, 1, rets[0].RowNumber())
+	code, err := rets[0].GetInt(0, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(1265), code)
+
+	_, err = q.StmtExecuteForward(context.Background(), 1, data)
+	require.Error(t, err)
+	rets, err = q.Execute(context.Background(), "show errors")
+	require.NoError(t, err)
+	require.Equal(t, 1, rets[0].RowNumber())
+	code, err = rets[0].GetInt(0, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(1146), code)
+	conn.AssertExpectations(t)
+}
+
+func TestQueryCtxImpl_Execute_MultiStmts(t *testing.T) {
+	q, ns := prepareExecuteQueryCtx()
+	ns.On("IsDeniedSQL", mock.Anything).Return(false)
+	sql := "use test_db; show databases"
+
+	_, err := q.Execute(context.Background(), sql)
+	require.Equal(t, parser.ErrSyntax, err)
+	require.Empty(t, q.CurrentDB())
+
+	q.SetClientCapability(mysql.ClientProtocol41 | mysql.ClientMultiStatements)
+	rets, err := q.Execute(context.Background(), sql)
+	require.NoError(t, err)
+	require.Len(t, rets, 2)
+	require.Nil(t, rets[0].Resultset)
+	require.Equal(t, q.Status(), rets[0].Status)
+	require.Equal(t, []byte("test_db"), rets[1].Values[0][0].AsString())
+	require.Equal(t, "test_db", q.CurrentDB())
+}
+
+func TestQueryCtxImpl_Execute_MultiStmts_Warnings(t *testing.T) {
+	q, ns := prepareExecuteQueryCtx()
+	ns.On("IsDeniedSQL", mock.Anything).Return(false)
+	warnings, err := createSimpleTextResult([]string{"Level", "Code", "Message"}, [][]interface{}{
+		{"Warning", int64(1265), "Data truncated for column 'a' at row 1"},
+	})
+	require.NoError(t, err)
+	conn := new(MockPooledBackendConn)
+	conn.On("UseDB", "").Return(nil)
+	conn.On("GetAddr").Return("127.0.0.1:4000")
+	conn.On("PutBack").Return()
+	conn.On("Execute", "insert into t values ('abc');").Return(&gomysql.Result{AffectedRows: 1}, nil).Once()
+	conn.On("Execute", " update t set a = 1").Return(&gomysql.Result{AffectedRows: 2}, nil).Once()
+	conn.On("GetWarnings").Return(uint16(1)).Once()
+	conn.On("GetWarnings").Return(uint16(0))
+	conn.On("Execute", "SHOW WARNINGS").Return(warnings, nil).Once()
+	ns.On("GetPooledConn", mock.Anything).Return(conn, nil)
+	q.SetClientCapability(mysql.ClientProtocol41 | mysql.ClientMultiStatements)
+
+	// each result has the warning count of its own statement
+	rets, err := q.Execute(context.Background(), "insert into t values ('abc'); update t set a = 1")
+	require.NoError(t, err)
+	require.Len(t, rets, 2)
+	require.Equal(t, uint16(1), rets[0].Warnings)
+	require.Equal(t, uint16(0), rets[1].Warnings)
+	require.Equal(t, uint64(2), rets[1].AffectedRows)
+}
+
+func TestQueryCtxImpl_Execute_MultiStmts_Denied(t *testing.T) {
+	q, ns := prepareExecuteQueryCtx()
+	ns.On("IsDeniedSQL", mock.Anything).Return(false).Once()
+	ns.On("IsDeniedSQL", mock.Anything).Return(true)
+	q.SetClientCapability(mysql.ClientProtocol41 | mysql.ClientMultiStatements)
+
+	rets, err := q.Execute(context.Background(), "use test_db; show databases; use test_db")
+	require.EqualError(t, err, "ERROR 1105 (HY000): statement is denied")
+	require.Len(t, rets, 1)
+	require.Equal(t, "test_db", q.CurrentDB())
+	ns.AssertNumberOfCalls(t, "IsDeniedSQL", 2)
+	ns.AssertNotCalled(t, "ListDatabases")
+}
+
+func TestQueryCtxImpl_Execute_LoadDataLocal_NotAllowed(t *testing.T) {
+	q, ns := prepareExecuteQueryCtx()
+	ns.On("IsDeniedSQL", mock.Anything).Return(false)
+	ns.On("IsDatabaseAllowed", "other_db").Return(false)
+	ns.On("IsLoadDataLocalAllowed").Return(false).Once()
+	ns.On("IsLoadDataLocalAllowed").Return(true)
+	q.SetLocalFileHandler(testLocalFileHandler)
+
+	sql := "load data local infile '/tmp/t.csv' into table t"
+	_, err := q.Execute(context.Background(), sql)
+	require.Equal(t, uint16(mysql.ErrNotAllowedCommand), err.(*mysql.SQLError).Code)
+
+	// client doesn't set CLIENT_LOCAL_FILES
+	_, err = q.Execute(context.Background(), sql)
+	require.Equal(t, uint16(mysql.ErrNotAllowedCommand), err.(*mysql.SQLError).Code)
+
+	q.SetClientCapability(mysql.ClientProtocol41 | mysql.ClientLocalFiles)
+	_, err = q.Execute(context.Background(), "load data local infile '/tmp/t.csv' into table other_db.t")
+	require.Equal(t, uint16(mysql.ErrDBaccessDenied), err.(*mysql.SQLError).Code)
+}
+
+func TestQueryCtxImpl_Execute_SetSysVars(t *testing.T) {
+	q, ns := prepareExecuteQueryCtx()
+	ns.On("IsDeniedSQL", mock.Anything).Return(false)
+	conn := new(MockPooledBackendConn)
+	conn.On("PutBack").Return()
+	ns.On("GetPooledConn", mock.Anything).Return(conn, nil)
+
+	_, err := q.Execute(context.Background(), "set time_zone = '+08:00', @@SESSION.SQL_MODE = ''")
+	require.NoError(t, err)
+	sysVars := q.sessionVars.GetAllSystemVars()
+	require.Len(t, sysVars, 2)
+	require.Contains(t, sysVars, "time_zone")
+	require.Contains(t, sysVars, "sql_mode")
+	require.Equal(t, "+08:00", sysVars["time_zone"].Value.(ast.ValueExpr).GetValue())
+
+	_, err = q.Execute(context.Background(), "set sql_mode = default")
+	require.NoError(t, err)
+	require.NotContains(t, q.sessionVars.GetAllSystemVars(), "sql_mode")
+}
+
+func TestQueryCtxImpl_Execute_SetSysVars_BackendError(t *testing.T) {
+	q, ns := prepareExecuteQueryCtx()
+	ns.On("IsDeniedSQL", mock.Anything).Return(false)
+	conn := new(MockPooledBackendConn)
+	conn.On("PutBack").Return()
+	ns.On("GetPooledConn", mock.Anything).Return(conn, nil).Once()
+	// the conn pool syncs the variables to the conn taken from it
+	ns.On("GetPooledConn", mock.MatchedBy(func(ctx context.Context) bool {
+		sysVars := ctx.Value(constant.ContextKeySessionVariable).(map[string]*ast.VariableAssignment)
+		return sysVars["time_zone"].Value.(ast.ValueExpr).GetValue() == "garbage"
+	})).Return(nil, gomysql.NewError(gomysql.ER_WRONG_VALUE_FOR_VAR, "Variable 'time_zone' can't be set to the value of 'garbage'")).Once()
+
+	_, err := q.Execute(context.Background(), "set time_zone = '+08:00'")
+	require.NoError(t, err)
+
+	// the value rejected by backend fails SET and the stored one is kept
+	_, err = q.Execute(context.Background(), "set time_zone = 'garbage', sql_mode = ''")
+	require.Equal(t, uint16(gomysql.ER_WRONG_VALUE_FOR_VAR), err.(*gomysql.MyError).Code)
+	sysVars := q.sessionVars.GetAllSystemVars()
+	require.Len(t, sysVars, 1)
+	require.Equal(t, "+08:00", sysVars["time_zone"].Value.(ast.ValueExpr).GetValue())
+	ns.AssertNumberOfCalls(t, "GetPooledConn", 2)
+}
+
+func TestQueryCtxImpl_Execute_SetSysVars_Error(t *testing.T) {
+	q, ns := prepareExecuteQueryCtx()
+	ns.On("IsDeniedSQL", mock.Anything).Return(false)
+
+	_, err := q.Execute(context.Background(), "set global time_zone = '+08:00'")
+	require.Equal(t, uint16(mysql.ErrSpecificAccessDenied), err.(*mysql.SQLError).Code)
+	_, err = q.Execute(context.Background(), "set @@global.autocommit = 0")
+	require.Equal(t, uint16(mysql.ErrSpecificAccessDenied), err.(*mysql.SQLError).Code)
+
+	// no variable is set if any of them is invalid
+	_, err = q.Execute(context.Background(), "set time_zone = '+08:00', unknown_var = 1")
+	require.Equal(t, uint16(mysql.ErrUnknownSystemVariable), err.(*mysql.SQLError).Code)
+	_, err = q.Execute(context.Background(), "set time_zone = '+08:00', max_connections = 1")
+	require.Equal(t, uint16(mysql.ErrGlobalVariable), err.(*mysql.SQLError).Code)
+	require.Empty(t, q.sessionVars.GetAllSystemVars())
+}
+
+func TestQueryCtxImpl_Execute_UserVars(t *testing.T) {
+	q, ns := prepareExecuteQueryCtx()
+	ns.On("IsDeniedSQL", mock.Anything).Return(false)
+	conn := new(MockPooledBackendConn)
+	conn.On("GetWarnings").Return(uint16(0))
+	conn.On("UseDB", "").Return(nil)
+	conn.On("GetAddr").Return("127.0.0.1:4000")
+	conn.On("Execute", "insert into t values ()").Return(&gomysql.Result{InsertId: 5}, nil).Once()
+	conn.On("PutBack").Return().Times(2)
+	pinnedConn := new(MockPooledBackendConn)
+	pinnedConn.On("GetWarnings").Return(uint16(0))
+	pinnedConn.On("UseDB", "").Return(nil)
+	pinnedConn.On("GetAddr").Return("127.0.0.1:4001")
+	pinnedConn.On("SyncSessionVariables", mock.Anything).Return(nil)
+	pinnedConn.On("Execute", "SET last_insert_id = 5").Return(&gomysql.Result{}, nil).Once()
+	pinnedConn.On("Execute", "SET @`a`=1").Return(&gomysql.Result{}, nil).Once()
+	pinnedConn.On("Execute", "select @a, last_insert_id()").Return(&gomysql.Result{}, nil).Once()
+	ns.On("GetPooledConn", mock.Anything).Return(conn, nil).Times(2)
+	ns.On("GetPooledConn", mock.Anything).Return(pinnedConn, nil).Once()
+
+	_, err := q.Execute(context.Background(), "insert into t values ()")
+	require.NoError(t, err)
+	require.False(t, q.connMgr.IsConnPinned())
+
+	// user variables are not sysvars, they are set on the pinned conn
+	_, err = q.Execute(context.Background(), "set time_zone = '+08:00', @a = 1")
+	require.NoError(t, err)
+	require.True(t, q.connMgr.IsConnPinned())
+	require.Len(t, q.sessionVars.GetAllSystemVars(), 1)
+
+	_, err = q.Execute(context.Background(), "select @a, last_insert_id()")
+	require.NoError(t, err)
+	pinnedConn.AssertExpectations(t)
+	pinnedConn.AssertNotCalled(t, "PutBack")
+	ns.AssertNumberOfCalls(t, "GetPooledConn", 3)
+}
+
+func TestQueryCtxImpl_Execute_UserVars_SetSysVars(t *testing.T) {
+	q, ns := prepareExecuteQueryCtx()
+	ns.On("IsDeniedSQL", mock.Anything).Return(false)
+	pinnedConn := new(MockPooledBackendConn)
+	pinnedConn.On("GetWarnings").Return(uint16(0))
+	pinnedConn.On("UseDB", "").Return(nil)
+	pinnedConn.On("GetAddr").Return("127.0.0.1:4001")
+	pinnedConn.On("Execute", "SET @`a`=1").Return(&gomysql.Result{}, nil).Once()
+	pinnedConn.On("Execute", "select @a, now()").Return(&gomysql.Result{}, nil).Once()
+	pinnedConn.On("SyncSessionVariables", mock.Anything).Return(nil)
+	ns.On("GetPooledConn", mock.Anything).Return(pinnedConn, nil).Once()
+
+	_, err := q.Execute(context.Background(), "set @a = 1")
+	require.NoError(t, err)
+	_, err = q.Execute(context.Background(), "set time_zone = '+08:00'")
+	require.NoError(t, err)
+	_, err = q.Execute(context.Background(), "select @a, now()")
+	require.NoError(t, err)
+
+	// the sysvar set after pinning is synced to the pinned conn, both at SET time and before the query
+	hasTimeZone := mock.MatchedBy(func(ctx context.Context) bool {
+		sysVars := ctx.Value(constant.ContextKeySessionVariable).(map[string]*ast.VariableAssignment)
+		return sysVars["time_zone"] != nil
+	})
+	pinnedConn.AssertNumberOfCalls(t, "SyncSessionVariables", 3)
+	pinnedConn.AssertCalled(t, "SyncSessionVariables", hasTimeZone)
+	pinnedConn.AssertExpectations(t)
+	ns.AssertNumberOfCalls(t, "GetPooledConn", 1)
+}
+
+func TestQueryCtxImpl_Execute_UserVars_Lost(t *testing.T) {
+	q, ns := prepareExecuteQueryCtx()
+	ns.On("IsDeniedSQL", mock.Anything).Return(false)
+	pinnedConn := new(MockPooledBackendConn)
+	pinnedConn.On("GetWarnings").Return(uint16(0))
+	pinnedConn.On("UseDB", "").Return(nil)
+	pinnedConn.On("GetAddr").Return("127.0.0.1:4001")
+	pinnedConn.On("SyncSessionVariables", mock.Anything).Return(nil)
+	pinnedConn.On("Execute", "SET @`a`=1").Return(&gomysql.Result{}, nil).Once()
+	pinnedConn.On("Execute", "select @a").Return(nil, gomysql.ErrBadConn).Once()
+	pinnedConn.On("ErrorClose").Return(nil).Once()
+	otherConn := new(MockPooledBackendConn)
+	otherConn.On("GetWarnings").Return(uint16(0))
+	otherConn.On("UseDB", "").Return(nil)
+	otherConn.On("GetAddr").Return("127.0.0.1:4002")
+	otherConn.On("Execute", "select @a").Return(&gomysql.Result{}, nil).Once()
+	ns.On("GetPooledConn", mock.Anything).Return(pinnedConn, nil).Once()
+	ns.On("GetPooledConn", mock.Anything).Return(otherConn, nil).Once()
+
+	_, err := q.Execute(context.Background(), "set @a = 1")
+	require.NoError(t, err)
+	_, err = q.Execute(context.Background(), "select @a")
+	require.Equal(t, gomysql.ErrBadConn, err)
+
+	// the statement reading user variables after the pinned conn is lost fails once
+	_, err = q.Execute(context.Background(), "select @a")
+	require.Equal(t, errUserVarsLost, err)
+	_, err = q.Execute(context.Background(), "select @a")
+	require.NoError(t, err)
+	require.True(t, q.connMgr.IsConnPinned())
+	pinnedConn.AssertExpectations(t)
+	otherConn.AssertExpectations(t)
+}
+
+func TestQueryCtxImpl_Execute_Stream(t *testing.T) {
+	q, ns := prepareExecuteQueryCtx()
+	ns.On("IsDeniedSQL", mock.Anything).Return(false)
+	ns.On("IsPassthrough").Return(false)
+	streamer := &testResultsetStreamer{}
+	q.SetResultsetStreamer(streamer)
+	conn := new(MockPooledBackendConn)
+	conn.On("GetWarnings").Return(uint16(0))
+	conn.On("UseDB", mock.Anything).Return(nil)
+	conn.On("GetAddr").Return("127.0.0.1:4000")
+	conn.On("PutBack").Return()
+	conn.On("ExecuteStream", "select a from t", mock.Anything, mock.Anything).
+		Run(runTestStreamHandlers).Return(&gomysql.Result{}, nil).Once()
+	conn.On("ExecuteStream", "update t set a = 1", mock.Anything, mock.Anything).
+		Return(&gomysql.Result{AffectedRows: 1}, nil).Once()
+	conn.On("Execute", "select a from t").Return(&gomysql.Result{}, nil).Once()
+	ns.On("GetPooledConn", mock.Anything).Return(conn, nil)
+	ns.On("GetPooledReadConn", mock.Anything).Return(conn, nil)
+
+	// the result set is written by streamer
+	rets, err := q.Execute(context.Background(), "select a from t")
+	require.NoError(t, err)
+	require.Empty(t, rets)
+	require.True(t, streamer.ended)
+	require.Equal(t, [][]byte{[]byte("row")}, streamer.rows)
+
+	// OK result is returned
+	rets, err = q.Execute(context.Background(), "update t set a = 1")
+	require.NoError(t, err)
+	require.Len(t, rets, 1)
+	require.Equal(t, uint64(1), rets[0].AffectedRows)
+
+	// results of multiple statements are not streamed
+	q.SetClientCapability(mysql.ClientProtocol41 | mysql.ClientMultiStatements)
+	rets, err = q.Execute(context.Background(), "use test_db;select a from t")
+	require.NoError(t, err)
+	require.Len(t, rets, 2)
+	conn.AssertExpectations(t)
+}
+
+func TestQueryCtxImpl_Execute_Passthrough(t *testing.T) {
+	q, ns := prepareExecuteQueryCtx()
+	ns.On("IsDeniedSQL", mock.Anything).Return(false)
+	ns.On("IsPassthrough").Return(true)
+	q.SetClientCapability(mysql.ClientProtocol41)
+	streamer := &testResultsetStreamer{}
+	q.SetResultsetStreamer(streamer)
+	conn := new(MockPooledBackendConn)
+	conn.On("GetWarnings").Return(uint16(0))
+	conn.On("UseDB", "").Return(nil)
+	conn.On("GetAddr").Return("127.0.0.1:4000")
+	conn.On("SyncSessionVariables", mock.Anything).Return(nil)
+	conn.On("Execute", "SET @`a`=1").Return(&gomysql.Result{}, nil).Once()
+	conn.On("ExecutePassthrough", "select @a", mock.Anything).
+		Run(func(args mock.Arguments) {
+			_ = args.Get(1).(func([]byte) error)([]byte("packet"))
+		}).Return(&gomysql.Result{}, nil).Once()
+	ns.On("GetPooledConn", mock.Anything).Return(conn, nil).Once()
+
+	// the OK packet of the SET statement is written by proxy rather than relayed
+	rets, err := q.Execute(context.Background(), "set @a = 1")
+	require.NoError(t, err)
+	require.Len(t, rets, 1)
+	require.Empty(t, streamer.packets)
+
+	rets, err = q.Execute(context.Background(), "select @a")
+	require.NoError(t, err)
+	require.Empty(t, rets)
+	require.Equal(t, [][]byte{[]byte("packet")}, streamer.packets)
+	require.True(t, streamer.flushed)
+	require.False(t, streamer.ended)
+	conn.AssertExpectations(t)
+}
@@ -0,0 +1,138 @@
+package driver
+
+import (
+	"context"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/util/logutil"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/tidb-incubator/weir/pkg/proxy/constant"
+	utilerrors "github.com/tidb-incubator/weir/pkg/util/errors"
+	"go.uber.org/zap"
+)
+
+const ctxWarningsRecorderKey = constant.ContextKeyPrefix + "warnings_recorder"
+
+// warningsRecorder records the warnings of the statement. They must be read from the backend conn executing
+// the statement, since the conn may be put back to pool and used by other sessions before SHOW WARNINGS.
+type warningsRecorder struct {
+	count uint16
+	warns []stmtctx.SQLWarn
+}
+
+func ctxWithWarningsRecorder(ctx context.Context) (context.Context, *warningsRecorder) {
+	recorder := &warningsRecorder{}
+	return context.WithValue(ctx, ctxWarningsRecorderKey, recorder), recorder
+}
+
+// recordBackendWarnings reads the warnings of the last query on conn by SHOW WARNINGS if there are any.
+// Failing to read them doesn't fail the query, only the warning count is kept in this case.
+func recordBackendWarnings(ctx context.Context, conn BackendConn) {
+	recorder, ok := ctx.Value(ctxWarningsRecorderKey).(*warningsRecorder)
+	if !ok {
+		return
+	}
+	recorder.count = conn.GetWarnings()
+	recorder.warns = nil
+	if recorder.count == 0 {
+		return
+	}
+
+	warns, err := readBackendWarnings(conn)
+	if err != nil {
+		logutil.BgLogger().Warn("read backend warnings error", zap.Uint16("count", recorder.count), zap.Error(err))
+		return
+	}
+	recorder.warns = warns
+}
+
+func readBackendWarnings(conn BackendConn) ([]stmtctx.SQLWarn, error) {
+	ret, err := conn.Execute("SHOW WARNINGS")
+	if err != nil {
+		return nil, err
+	}
+	if ret.Resultset == nil {
+		return nil, nil
+	}
+
+	warns := make([]stmtctx.SQLWarn, 0, ret.RowNumber())
+	for i := 0; i < ret.RowNumber(); i++ {
+		level, err := ret.GetString(i, 0)
+		if err != nil {
+			return nil, err
+		}
+		code, err := ret.GetUint(i, 1)
+		if err != nil {
+			return nil, err
+		}
+		message, err := ret.GetString(i, 2)
+		if err != nil {
+			return nil, err
+		}
+		warns = append(warns, stmtctx.SQLWarn{Level: level, Err: mysql.NewErrf(uint16(code), "%s", message)})
+	}
+	return warns, nil
+}
+
+func isShowWarningsStmt(stmt ast.StmtNode) bool {
+	showStmt, ok := stmt.(*ast.ShowStmt)
+	return ok && (showStmt.Tp == ast.ShowWarnings || showStmt.Tp == ast.ShowErrors)
+}
+
+// executeOneStmtWithWarnings keeps the warnings of stmt in session, so that they are returned by SHOW WARNINGS and
+// SHOW ERRORS later, and the failure of stmt is kept as an error. SHOW WARNINGS and SHOW ERRORS keep the warnings.
+func (q *QueryCtxImpl) executeOneStmtWithWarnings(ctx context.Context, sql string, stmt ast.StmtNode) (*gomysql.Result, error) {
+	if isShowWarningsStmt(stmt) {
+		return q.executeOneStmt(ctx, sql, stmt)
+	}
+	return q.executeWithWarnings(ctx, func(ctx context.Context) (*gomysql.Result, error) {
+		return q.executeOneStmt(ctx, sql, stmt)
+	})
+}
+
+// executeWithWarnings runs execute with a warnings recorder in ctx, and keeps the recorded warnings in session,
+// or the error as an Error level warning if execute fails.
+func (q *QueryCtxImpl) executeWithWarnings(ctx context.Context, execute func(ctx context.Context) (*gomysql.Result, error)) (*gomysql.Result, error) {
+	// the warnings of the last statement must not be written to the packets of this one
+	q.sessionVars.SetWarnings(0, nil)
+	ctx, recorder := ctxWithWarningsRecorder(ctx)
+	ret, err := execute(ctx)
+	if err != nil {
+		q.sessionVars.SetWarnings(1, []stmtctx.SQLWarn{{Level: stmtctx.WarnLevelError, Err: err}})
+	} else {
+		q.sessionVars.SetWarnings(recorder.count, recorder.warns)
+	}
+	return ret, err
+}
+
+// createShowWarningsResult builds the result of SHOW WARNINGS, or SHOW ERRORS if errOnly is true.
+func createShowWarningsResult(warns []stmtctx.SQLWarn, errOnly bool) (*gomysql.Result, error) {
+	values := make([][]interface{}, 0, len(warns))
+	for _, w := range warns {
+		if errOnly && w.Level != stmtctx.WarnLevelError {
+			continue
+		}
+		sqlErr := toSQLError(w.Err)
+		values = append(values, []interface{}{w.Level, int64(sqlErr.Code), sqlErr.Message})
+	}
+	return createSimpleTextResult([]string{"Level", "Code", "Message"}, values)
+}
+
+// toSQLError gets the error code and message of err, which are the same as those sent to client.
+func toSQLError(err error) *mysql.SQLError {
+	if myErr, ok := utilerrors.CheckAndGetMyError(err); ok {
+		return &mysql.SQLError{Code: myErr.Code, Message: myErr.Message, State: myErr.State}
+	}
+	switch x := errors.Cause(err).(type) {
+	case *mysql.SQLError:
+		return x
+	case *terror.Error:
+		return x.ToSQLError()
+	default:
+		return mysql.NewErrf(mysql.ErrUnknown, "%s", err.Error())
+	}
+}
@@ -1,10 +1,11 @@
 package driver
 
 import (
-	"fmt"
 	"sync/atomic"
 
 	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
 	"github.com/pingcap/tidb/sessionctx/variable"
 )
 
@@ -12,6 +13,12 @@ type SessionVarsWrapper struct {
 	sessionVarMap map[string]*ast.VariableAssignment
 	sessionVars   *variable.SessionVars
 	affectedRows  uint64
+
+	// lastGeneratedID is the last non-zero insert id, i.e. the value of LAST_INSERT_ID()
+	lastGeneratedID uint64
+
+	// warningCount may be larger than the number of kept warnings, since backend keeps at most max_error_count ones
+	warningCount uint16
 }
 
 func NewSessionVarsWrapper(sessionVars *variable.SessionVars) *SessionVarsWrapper {
@@ -43,10 +50,10 @@ func (s *SessionVarsWrapper) CheckSessionSysVarValid(name string) error {
 	}
 	sysVar := variable.GetSysVar(name)
 	if sysVar == nil {
-		return fmt.Errorf("%s is not a valid sysvar", name)
+		return mysql.NewErr(mysql.ErrUnknownSystemVariable, name)
 	}
 	if (sysVar.Scope & variable.ScopeSession) == 0 {
-		return fmt.Errorf("%s is not a session scope sysvar", name)
+		return mysql.NewErr(mysql.ErrGlobalVariable, name)
 	}
 	return nil
 }
@@ -88,6 +95,28 @@ func (s *SessionVarsWrapper) SetLastInsertID(id uint64) {
 	s.sessionVars.StmtCtx.LastInsertID = id
 }
 
+func (s *SessionVarsWrapper) LastGeneratedID() uint64 {
+	return s.lastGeneratedID
+}
+
+func (s *SessionVarsWrapper) SetLastGeneratedID(id uint64) {
+	s.lastGeneratedID = id
+}
+
+func (s *SessionVarsWrapper) WarningCount() uint16 {
+	return s.warningCount
+}
+
+func (s *SessionVarsWrapper) GetWarnings() []stmtctx.SQLWarn {
+	return s.sessionVars.StmtCtx.GetWarnings()
+}
+
+// SetWarnings replaces the warnings of the last statement.
+func (s *SessionVarsWrapper) SetWarnings(count uint16, warns []stmtctx.SQLWarn) {
+	s.warningCount = count
+	s.sessionVars.StmtCtx.SetWarnings(warns)
+}
+
 func (s *SessionVarsWrapper) GetMessage() string {
 	return s.sessionVars.StmtCtx.GetMessage()
 }
@@ -7,6 +7,9 @@ const (
 	BackendEventInited  = "inited"
 	BackendEventClosing = "closing"
 	BackendEventClosed  = "closed"
+
+	BackendEventInstanceAdded   = "instance_added"
+	BackendEventInstanceRemoved = "instance_removed"
 )
 
 var (
@@ -33,4 +36,12 @@ var (
 			Name:      "b_conn_in_use",
 			Help:      "Number of backend conn in use.",
 		}, []string{LblCluster, LblNamespace, LblBackendAddr})
+
+	BackendInstanceHealthGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: ModuleWeirProxy,
+			Subsystem: LabelBackend,
+			Name:      "b_instance_healthy",
+			Help:      "Health state of backend instance, 1 for healthy and 0 for unhealthy.",
+		}, []string{LblCluster, LblNamespace, LblBackendAddr})
 )
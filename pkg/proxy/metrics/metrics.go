@@ -61,6 +61,10 @@ func RegisterProxyMetrics(cluster string) {
 	prometheus.MustRegister(QueryCtxQueryDeniedCounter)
 	QueryCtxQueryDurationHistogram = QueryCtxQueryDurationHistogram.MustCurryWith(curryingLabelsWithLblCluster).(*prometheus.HistogramVec)
 	prometheus.MustRegister(QueryCtxQueryDurationHistogram)
+	QueryCtxQueryRetryCounter = QueryCtxQueryRetryCounter.MustCurryWith(curryingLabelsWithLblCluster)
+	prometheus.MustRegister(QueryCtxQueryRetryCounter)
+	QueryCtxHostDeniedCounter = QueryCtxHostDeniedCounter.MustCurryWith(curryingLabelsWithLblCluster)
+	prometheus.MustRegister(QueryCtxHostDeniedCounter)
 	QueryCtxGauge = QueryCtxGauge.MustCurryWith(curryingLabelsWithLblCluster)
 	prometheus.MustRegister(QueryCtxGauge)
 	QueryCtxAttachedConnGauge = QueryCtxAttachedConnGauge.MustCurryWith(curryingLabelsWithLblCluster)
@@ -75,4 +79,6 @@ func RegisterProxyMetrics(cluster string) {
 	prometheus.MustRegister(BackendQueryCounter)
 	BackendConnInUseGauge = BackendConnInUseGauge.MustCurryWith(curryingLabelsWithLblCluster)
 	prometheus.MustRegister(BackendConnInUseGauge)
+	BackendInstanceHealthGauge = BackendInstanceHealthGauge.MustCurryWith(curryingLabelsWithLblCluster)
+	prometheus.MustRegister(BackendInstanceHealthGauge)
 }
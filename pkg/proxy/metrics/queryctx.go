@@ -65,6 +65,22 @@ var (
 			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 29), // 0.5ms ~ 1.5days
 		}, []string{LblCluster, LblNamespace, LblDb, LblTable, LblSQLType})
 
+	QueryCtxQueryRetryCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ModuleWeirProxy,
+			Subsystem: LabelQueryCtx,
+			Name:      "query_retry_total",
+			Help:      "Counter of read only queries retried on backend conn error.",
+		}, []string{LblCluster, LblNamespace, LblResult})
+
+	QueryCtxHostDeniedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: ModuleWeirProxy,
+			Subsystem: LabelQueryCtx,
+			Name:      "host_denied_total",
+			Help:      "Counter of connections rejected by allowed_ips and denied_ips.",
+		}, []string{LblCluster, LblNamespace})
+
 	QueryCtxGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: ModuleWeirProxy,
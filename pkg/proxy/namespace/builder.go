@@ -7,6 +7,7 @@ import (
 	"github.com/tidb-incubator/weir/pkg/config"
 	"github.com/tidb-incubator/weir/pkg/proxy/backend"
 	"github.com/tidb-incubator/weir/pkg/proxy/driver"
+	"github.com/tidb-incubator/weir/pkg/registry"
 	wast "github.com/tidb-incubator/weir/pkg/util/ast"
 	"github.com/tidb-incubator/weir/pkg/util/datastructure"
 	"github.com/pingcap/errors"
@@ -22,7 +23,25 @@ type NamespaceImpl struct {
 }
 
 func BuildNamespace(cfg *config.Namespace) (Namespace, error) {
-	be, err := BuildBackend(cfg.Namespace, &cfg.Backend)
+	return buildNamespace(cfg, nil)
+}
+
+// NewNamespaceBuilder returns a builder which uses reg to discover backend instances
+// for namespaces with use_registry enabled.
+func NewNamespaceBuilder(reg registry.Registry) NamespaceBuilder {
+	return func(cfg *config.Namespace) (Namespace, error) {
+		return buildNamespace(cfg, reg)
+	}
+}
+
+func buildNamespace(cfg *config.Namespace, reg registry.Registry) (Namespace, error) {
+	var be Backend
+	var err error
+	if cfg.Backend.UseRegistry {
+		be, err = BuildRegistryBackend(cfg.Namespace, &cfg.Backend, reg)
+	} else {
+		be, err = BuildBackend(cfg.Namespace, &cfg.Backend)
+	}
 	if err != nil {
 		return nil, errors.WithMessage(err, "build backend error")
 	}
@@ -79,7 +98,13 @@ func BuildBackend(ns string, cfg *config.BackendNamespace) (Backend, error) {
 
 func BuildFrontend(cfg *config.FrontendNamespace) (Frontend, error) {
 	fns := &FrontendNamespace{
-		allowedDBs: cfg.AllowedDBs,
+		allowedDBs:  cfg.AllowedDBs,
+		slowSQLTime: time.Duration(cfg.SlowSQLTime) * time.Millisecond,
+
+		requireSecureTransport: cfg.RequireSecureTransport,
+		emulatePrepare:         cfg.EmulatePrepare,
+		allowLoadDataLocal:     cfg.AllowLoadDataLocal,
+		passthrough:            cfg.Passthrough,
 	}
 	fns.allowedDBSet = datastructure.StringSliceToSet(cfg.AllowedDBs)
 
@@ -89,6 +114,17 @@ func BuildFrontend(cfg *config.FrontendNamespace) (Frontend, error) {
 	}
 	fns.userPasswd = userPasswds
 
+	allowedIPs, err := NewIPFilter(cfg.AllowedIPs)
+	if err != nil {
+		return nil, errors.WithMessage(err, "parse allowed ips error")
+	}
+	fns.allowedIPs = allowedIPs
+	deniedIPs, err := NewIPFilter(cfg.DeniedIPs)
+	if err != nil {
+		return nil, errors.WithMessage(err, "parse denied ips error")
+	}
+	fns.deniedIPs = deniedIPs
+
 	sqlBlacklist := make(map[uint32]SQLInfo)
 	fns.sqlBlacklist = sqlBlacklist
 
@@ -139,14 +175,38 @@ func parseBackendConfig(cfg *config.BackendNamespace) (*backend.BackendConfig, e
 		addrs[ins] = struct{}{}
 	}
 
+	replicaAddrs := make(map[string]struct{})
+	for _, ins := range cfg.ReplicaInstances {
+		replicaAddrs[ins] = struct{}{}
+	}
+
 	bcfg := &backend.BackendConfig{
 		Addrs:        addrs,
+		ReplicaAddrs: replicaAddrs,
+		Weights:      cfg.InstanceWeights,
 		UserName:     cfg.Username,
 		Password:     cfg.Password,
 		Capacity:     cfg.PoolSize,
 		IdleTimeout:  time.Duration(cfg.IdleTimeout) * time.Second,
 		SelectorType: selectorType,
 	}
+	if cfg.Security.EnableTLS {
+		tlsConfig, err := backend.NewTLSConfig(cfg.Security.SSLCA, cfg.Security.SSLCert, cfg.Security.SSLKey,
+			cfg.Security.ServerName, cfg.Security.InsecureSkipVerify)
+		if err != nil {
+			return nil, errors.WithMessage(err, "create backend tls config error")
+		}
+		bcfg.TLSConfig = tlsConfig
+	}
+	if cfg.HealthCheck.Enable {
+		bcfg.HealthCheck = &backend.HealthCheckConfig{
+			Interval:         time.Duration(cfg.HealthCheck.IntervalMs) * time.Millisecond,
+			Timeout:          time.Duration(cfg.HealthCheck.TimeoutMs) * time.Millisecond,
+			ProbeSQL:         cfg.HealthCheck.ProbeSQL,
+			FailureThreshold: cfg.HealthCheck.FailureThreshold,
+			SuccessThreshold: cfg.HealthCheck.SuccessThreshold,
+		}
+	}
 	return bcfg, nil
 }
 
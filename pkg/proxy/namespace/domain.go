@@ -2,18 +2,31 @@ package namespace
 
 import (
 	"context"
+	"time"
 
+	"github.com/tidb-incubator/weir/pkg/proxy/backend"
 	"github.com/tidb-incubator/weir/pkg/proxy/driver"
 )
 
 type Namespace interface {
 	Name() string
 	Auth(username string, passwdBytes []byte, salt []byte) bool
+	IsHostAllowed(host string) bool
+	IsSecureTransportRequired() bool
+	IsPrepareEmulated() bool
+	IsLoadDataLocalAllowed() bool
+	IsPassthrough() bool
 	IsDatabaseAllowed(db string) bool
 	ListDatabases() []string
 	IsDeniedSQL(sqlFeature uint32) bool
 	IsAllowedSQL(sqlFeature uint32) bool
+	HasSQLList() bool
+	GetSlowSQLTime() time.Duration
 	GetPooledConn(context.Context) (driver.PooledBackendConn, error)
+	GetPooledReadConn(context.Context) (driver.PooledBackendConn, error)
+	GetInstanceStatuses() []backend.InstanceStatus
+	AddInstance(addr string, role backend.InstanceRole, weight int) error
+	RemoveInstance(addr string) error
 	Close()
 	GetBreaker() (driver.Breaker, error)
 	GetRateLimiter() driver.RateLimiter
@@ -21,13 +34,24 @@ type Namespace interface {
 
 type Frontend interface {
 	Auth(username string, passwdBytes []byte, salt []byte) bool
+	IsHostAllowed(host string) bool
+	IsSecureTransportRequired() bool
+	IsPrepareEmulated() bool
+	IsLoadDataLocalAllowed() bool
+	IsPassthrough() bool
 	IsDatabaseAllowed(db string) bool
 	ListDatabases() []string
 	IsDeniedSQL(sqlFeature uint32) bool
 	IsAllowedSQL(sqlFeature uint32) bool
+	HasSQLList() bool
+	GetSlowSQLTime() time.Duration
 }
 
 type Backend interface {
 	Close()
 	GetPooledConn(context.Context) (driver.PooledBackendConn, error)
+	GetPooledReadConn(context.Context) (driver.PooledBackendConn, error)
+	GetInstanceStatuses() []backend.InstanceStatus
+	AddInstance(addr string, role backend.InstanceRole, weight int) error
+	RemoveInstance(addr string) error
 }
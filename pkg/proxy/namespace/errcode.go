@@ -7,6 +7,9 @@ import (
 var (
 	ErrDuplicatedUser      = errors.New("duplicated user")
 	ErrInvalidSelectorType = errors.New("invalid selector type")
+	ErrRegistryNotEnabled  = errors.New("registry is not enabled")
+	ErrNoRegistryInstance  = errors.New("no instance found in registry")
+	ErrPrimaryFromRegistry = errors.New("primary instances are managed by registry")
 
 	ErrNilBreakerName              = errors.New("breaker name nil")
 	ErrInvalidFailureRateThreshold = errors.New("invalid FailureRateThreshold")
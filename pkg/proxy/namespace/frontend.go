@@ -2,6 +2,7 @@ package namespace
 
 import (
 	"bytes"
+	"time"
 
 	"github.com/tidb-incubator/weir/pkg/util/passwd"
 )
@@ -16,6 +17,14 @@ type FrontendNamespace struct {
 	userPasswd   map[string]string
 	sqlBlacklist map[uint32]SQLInfo
 	sqlWhitelist map[uint32]SQLInfo
+	allowedIPs   *IPFilter
+	deniedIPs    *IPFilter
+	slowSQLTime  time.Duration
+
+	requireSecureTransport bool
+	emulatePrepare         bool
+	allowLoadDataLocal     bool
+	passthrough            bool
 }
 
 func (n *FrontendNamespace) Auth(username string, passwdBytes []byte, salt []byte) bool {
@@ -27,6 +36,45 @@ func (n *FrontendNamespace) Auth(username string, passwdBytes []byte, salt []byt
 	return bytes.Equal(userPasswdBytes, passwdBytes)
 }
 
+// IsHostAllowed checks client host against denied ips first, then allowed ips if it's not empty.
+func (n *FrontendNamespace) IsHostAllowed(host string) bool {
+	ip := parseHostIP(host)
+	if ip == nil {
+		return n.allowedIPs.IsEmpty()
+	}
+	if n.deniedIPs.Match(ip) {
+		return false
+	}
+	return n.allowedIPs.IsEmpty() || n.allowedIPs.Match(ip)
+}
+
+func (n *FrontendNamespace) IsSecureTransportRequired() bool {
+	return n.requireSecureTransport
+}
+
+func (n *FrontendNamespace) IsPrepareEmulated() bool {
+	return n.emulatePrepare
+}
+
+func (n *FrontendNamespace) IsLoadDataLocalAllowed() bool {
+	return n.allowLoadDataLocal
+}
+
+func (n *FrontendNamespace) IsPassthrough() bool {
+	return n.passthrough
+}
+
+// HasSQLList returns true if sql blacklist or whitelist is configured,
+// statements are checked by sql paradigm only in this case.
+func (n *FrontendNamespace) HasSQLList() bool {
+	return len(n.sqlBlacklist) != 0 || len(n.sqlWhitelist) != 0
+}
+
+// GetSlowSQLTime returns 0 if slow log is disabled for the namespace.
+func (n *FrontendNamespace) GetSlowSQLTime() time.Duration {
+	return n.slowSQLTime
+}
+
 func (n *FrontendNamespace) IsDatabaseAllowed(db string) bool {
 	_, ok := n.allowedDBSet[db]
 	return ok
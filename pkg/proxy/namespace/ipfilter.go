@@ -0,0 +1,53 @@
+package namespace
+
+import (
+	"net"
+	"strings"
+
+	"github.com/tidb-incubator/weir/pkg/util/netutil"
+)
+
+const hostLocalhost = "localhost"
+
+// IPFilter matches ip against a list of single ips and CIDR ranges.
+type IPFilter struct {
+	nets []*net.IPNet
+}
+
+func NewIPFilter(entries []string) (*IPFilter, error) {
+	f := &IPFilter{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		ipNet, err := netutil.ParseIPNet(entry)
+		if err != nil {
+			return nil, err
+		}
+		f.nets = append(f.nets, ipNet)
+	}
+	return f, nil
+}
+
+func (f *IPFilter) IsEmpty() bool {
+	return len(f.nets) == 0
+}
+
+func (f *IPFilter) Match(ip net.IP) bool {
+	for _, ipNet := range f.nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHostIP returns nil if host is not an ip.
+// Clients connected by unix socket have host localhost, which is treated as loopback address.
+func parseHostIP(host string) net.IP {
+	if host == hostLocalhost {
+		return net.IPv4(127, 0, 0, 1)
+	}
+	return net.ParseIP(host)
+}
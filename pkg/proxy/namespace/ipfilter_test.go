@@ -0,0 +1,45 @@
+package namespace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIPFilter_Error(t *testing.T) {
+	_, err := NewIPFilter([]string{"127.0.0.256"})
+	require.Error(t, err)
+	_, err = NewIPFilter([]string{"10.0.0.0/33"})
+	require.Error(t, err)
+}
+
+func TestFrontendNamespace_IsHostAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		denied  []string
+		host    string
+		want    bool
+	}{
+		{name: "no rules", host: "10.0.0.1", want: true},
+		{name: "denied ip", denied: []string{"10.0.0.1"}, host: "10.0.0.1", want: false},
+		{name: "not denied ip", denied: []string{"10.0.0.1"}, host: "10.0.0.2", want: true},
+		{name: "denied cidr", denied: []string{"10.0.0.0/8"}, host: "10.1.2.3", want: false},
+		{name: "allowed cidr", allowed: []string{"192.168.0.0/16"}, host: "192.168.1.1", want: true},
+		{name: "not allowed", allowed: []string{"192.168.0.0/16"}, host: "10.0.0.1", want: false},
+		{name: "deny first", allowed: []string{"10.0.0.0/8"}, denied: []string{"10.0.0.1"}, host: "10.0.0.1", want: false},
+		{name: "ipv6", allowed: []string{"fd00::/8"}, host: "fd00::1", want: true},
+		{name: "localhost", allowed: []string{"127.0.0.1"}, host: "localhost", want: true},
+		{name: "not ip", allowed: []string{"127.0.0.1"}, host: "unknown", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowedIPs, err := NewIPFilter(tt.allowed)
+			require.NoError(t, err)
+			deniedIPs, err := NewIPFilter(tt.denied)
+			require.NoError(t, err)
+			fns := &FrontendNamespace{allowedIPs: allowedIPs, deniedIPs: deniedIPs}
+			require.Equal(t, tt.want, fns.IsHostAllowed(tt.host))
+		})
+	}
+}
@@ -122,6 +122,10 @@ func (n *NamespaceManager) RemoveNamespace(name string) {
 	nss.Delete(name)
 }
 
+func (n *NamespaceManager) GetNamespace(name string) (Namespace, bool) {
+	return n.getCurrentNamespaces().Get(name)
+}
+
 func (n *NamespaceManager) getNamespaceByUsername(username string) (string, bool) {
 	return n.getCurrentUsers().GetUserNamespace(username)
 }
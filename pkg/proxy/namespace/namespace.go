@@ -3,6 +3,8 @@ package namespace
 import (
 	"context"
 	"fmt"
+	"time"
+
 	"github.com/pingcap/errors"
 	"github.com/tidb-incubator/weir/pkg/config"
 	"github.com/tidb-incubator/weir/pkg/proxy/driver"
@@ -62,6 +64,30 @@ func (n *NamespaceWrapper) Name() string {
 	return n.name
 }
 
+func (n *NamespaceWrapper) IsHostAllowed(host string) bool {
+	return n.mustGetCurrentNamespace().IsHostAllowed(host)
+}
+
+func (n *NamespaceWrapper) IsSecureTransportRequired() bool {
+	return n.mustGetCurrentNamespace().IsSecureTransportRequired()
+}
+
+func (n *NamespaceWrapper) IsPrepareEmulated() bool {
+	return n.mustGetCurrentNamespace().IsPrepareEmulated()
+}
+
+func (n *NamespaceWrapper) IsLoadDataLocalAllowed() bool {
+	return n.mustGetCurrentNamespace().IsLoadDataLocalAllowed()
+}
+
+func (n *NamespaceWrapper) IsPassthrough() bool {
+	return n.mustGetCurrentNamespace().IsPassthrough()
+}
+
+func (n *NamespaceWrapper) GetSlowSQLTime() time.Duration {
+	return n.mustGetCurrentNamespace().GetSlowSQLTime()
+}
+
 func (n *NamespaceWrapper) IsDatabaseAllowed(db string) bool {
 	return n.mustGetCurrentNamespace().IsDatabaseAllowed(db)
 }
@@ -78,10 +104,18 @@ func (n *NamespaceWrapper) IsAllowedSQL(sqlFeature uint32) bool {
 	return n.mustGetCurrentNamespace().IsAllowedSQL(sqlFeature)
 }
 
+func (n *NamespaceWrapper) HasSQLList() bool {
+	return n.mustGetCurrentNamespace().HasSQLList()
+}
+
 func (n *NamespaceWrapper) GetPooledConn(ctx context.Context) (driver.PooledBackendConn, error) {
 	return n.mustGetCurrentNamespace().GetPooledConn(ctx)
 }
 
+func (n *NamespaceWrapper) GetPooledReadConn(ctx context.Context) (driver.PooledBackendConn, error) {
+	return n.mustGetCurrentNamespace().GetPooledReadConn(ctx)
+}
+
 func (n *NamespaceWrapper) IncrConnCount() {
 	metrics.QueryCtxGauge.WithLabelValues(n.name).Inc()
 }
@@ -0,0 +1,132 @@
+package namespace
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/util/logutil"
+	"github.com/tidb-incubator/weir/pkg/config"
+	"github.com/tidb-incubator/weir/pkg/proxy/backend"
+	"github.com/tidb-incubator/weir/pkg/registry"
+	"github.com/tidb-incubator/weir/pkg/util/datastructure"
+	"go.uber.org/zap"
+)
+
+const DefaultRegistryListTimeout = 5 * time.Second
+
+// RegistryBackend keeps the primary instances of backend in sync with registry.
+type RegistryBackend struct {
+	Backend
+	ns      string
+	weights map[string]int
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func BuildRegistryBackend(ns string, cfg *config.BackendNamespace, reg registry.Registry) (Backend, error) {
+	if reg == nil {
+		return nil, ErrRegistryNotEnabled
+	}
+
+	listCtx, cancelList := context.WithTimeout(context.Background(), DefaultRegistryListTimeout)
+	addrs, err := reg.ListInstances(listCtx)
+	cancelList()
+	if err != nil {
+		return nil, errors.WithMessage(err, "list instances from registry error")
+	}
+	if len(addrs) == 0 {
+		return nil, ErrNoRegistryInstance
+	}
+
+	bcfg, err := parseBackendConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	bcfg.Addrs = datastructure.StringSliceToSet(addrs)
+
+	b := backend.NewBackendImpl(ns, bcfg)
+	if err := b.Init(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rb := &RegistryBackend{
+		Backend: b,
+		ns:      ns,
+		weights: cfg.InstanceWeights,
+		cancel:  cancel,
+	}
+	rb.wg.Add(1)
+	go rb.run(reg.Watch(ctx))
+	return rb, nil
+}
+
+func (r *RegistryBackend) run(ch <-chan []string) {
+	defer r.wg.Done()
+	for addrs := range ch {
+		r.sync(addrs)
+	}
+}
+
+// sync adds new instances before removing old ones, so that the backend always has a primary instance.
+func (r *RegistryBackend) sync(addrs []string) {
+	if len(addrs) == 0 {
+		logutil.BgLogger().Warn("no instance found in registry, keep current instances", zap.String("namespace", r.ns))
+		return
+	}
+
+	current := make(map[string]struct{})
+	for _, status := range r.GetInstanceStatuses() {
+		if status.Role == backend.InstanceRoleNamePrimary {
+			current[status.Addr] = struct{}{}
+		}
+	}
+	discovered := datastructure.StringSliceToSet(addrs)
+
+	for addr := range discovered {
+		if _, ok := current[addr]; ok {
+			continue
+		}
+		if err := r.Backend.AddInstance(addr, backend.InstanceRolePrimary, r.weights[addr]); err != nil {
+			logutil.BgLogger().Error("add instance discovered from registry error", zap.String("namespace", r.ns),
+				zap.String("addr", addr), zap.Error(err))
+		}
+	}
+
+	for addr := range current {
+		if _, ok := discovered[addr]; ok {
+			continue
+		}
+		if err := r.Backend.RemoveInstance(addr); err != nil {
+			logutil.BgLogger().Error("remove instance left from registry error", zap.String("namespace", r.ns),
+				zap.String("addr", addr), zap.Error(err))
+		}
+	}
+}
+
+// AddInstance only adds replica instances, since primary instances added by others are removed by the next sync.
+func (r *RegistryBackend) AddInstance(addr string, role backend.InstanceRole, weight int) error {
+	if role == backend.InstanceRolePrimary {
+		return ErrPrimaryFromRegistry
+	}
+	return r.Backend.AddInstance(addr, role, weight)
+}
+
+// RemoveInstance only removes replica instances, since primary instances are added back by the next sync.
+func (r *RegistryBackend) RemoveInstance(addr string) error {
+	for _, status := range r.GetInstanceStatuses() {
+		if status.Addr == addr && status.Role == backend.InstanceRoleNamePrimary {
+			return ErrPrimaryFromRegistry
+		}
+	}
+	return r.Backend.RemoveInstance(addr)
+}
+
+func (r *RegistryBackend) Close() {
+	r.cancel()
+	r.wg.Wait()
+	r.Backend.Close()
+}
@@ -0,0 +1,115 @@
+package namespace
+
+import (
+	"context"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidb-incubator/weir/pkg/config"
+	"github.com/tidb-incubator/weir/pkg/proxy/backend"
+	"github.com/tidb-incubator/weir/pkg/proxy/metrics"
+)
+
+func TestMain(m *testing.M) {
+	metrics.RegisterProxyMetrics("test_cluster")
+	os.Exit(m.Run())
+}
+
+type testRegistry struct {
+	addrs []string
+	ch    chan []string
+}
+
+func newTestRegistry(addrs ...string) *testRegistry {
+	return &testRegistry{
+		addrs: addrs,
+		ch:    make(chan []string),
+	}
+}
+
+func (r *testRegistry) ListInstances(ctx context.Context) ([]string, error) {
+	return r.addrs, nil
+}
+
+func (r *testRegistry) Watch(ctx context.Context) <-chan []string {
+	ch := make(chan []string)
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case addrs := <-r.ch:
+				ch <- addrs
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func (r *testRegistry) Close() {
+}
+
+func prepareRegistryBackendConfig() *config.BackendNamespace {
+	return &config.BackendNamespace{
+		SelectorType: "round_robin",
+		PoolSize:     1,
+		UseRegistry:  true,
+	}
+}
+
+func getInstanceAddrs(b Backend) []string {
+	var addrs []string
+	for _, status := range b.GetInstanceStatuses() {
+		addrs = append(addrs, status.Addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+func TestBuildRegistryBackend_Sync(t *testing.T) {
+	reg := newTestRegistry("127.0.0.1:4000", "127.0.0.1:4001")
+	be, err := BuildRegistryBackend("test_ns", prepareRegistryBackendConfig(), reg)
+	require.NoError(t, err)
+	defer be.Close()
+	require.Equal(t, []string{"127.0.0.1:4000", "127.0.0.1:4001"}, getInstanceAddrs(be))
+
+	reg.ch <- []string{"127.0.0.1:4001", "127.0.0.1:4002"}
+	require.Eventually(t, func() bool {
+		addrs := getInstanceAddrs(be)
+		return len(addrs) == 2 && addrs[0] == "127.0.0.1:4001" && addrs[1] == "127.0.0.1:4002"
+	}, time.Second, 10*time.Millisecond)
+
+	// empty list is ignored
+	reg.ch <- []string{}
+	reg.ch <- []string{"127.0.0.1:4001", "127.0.0.1:4002"}
+	require.Equal(t, []string{"127.0.0.1:4001", "127.0.0.1:4002"}, getInstanceAddrs(be))
+}
+
+func TestBuildRegistryBackend_Error(t *testing.T) {
+	_, err := BuildRegistryBackend("test_ns", prepareRegistryBackendConfig(), nil)
+	require.EqualError(t, err, ErrRegistryNotEnabled.Error())
+
+	_, err = BuildRegistryBackend("test_ns", prepareRegistryBackendConfig(), newTestRegistry())
+	require.EqualError(t, err, ErrNoRegistryInstance.Error())
+}
+
+func TestRegistryBackend_AddRemoveInstance(t *testing.T) {
+	reg := newTestRegistry("127.0.0.1:4000")
+	be, err := BuildRegistryBackend("test_ns", prepareRegistryBackendConfig(), reg)
+	require.NoError(t, err)
+	defer be.Close()
+
+	// primary instances are only changed by registry
+	require.EqualError(t, be.AddInstance("127.0.0.1:4001", backend.InstanceRolePrimary, 0), ErrPrimaryFromRegistry.Error())
+	require.EqualError(t, be.RemoveInstance("127.0.0.1:4000"), ErrPrimaryFromRegistry.Error())
+
+	require.NoError(t, be.AddInstance("127.0.0.1:4001", backend.InstanceRoleReplica, 0))
+	reg.ch <- []string{"127.0.0.1:4000"}
+	require.Equal(t, []string{"127.0.0.1:4000", "127.0.0.1:4001"}, getInstanceAddrs(be))
+	require.NoError(t, be.RemoveInstance("127.0.0.1:4001"))
+	require.Equal(t, []string{"127.0.0.1:4000"}, getInstanceAddrs(be))
+}
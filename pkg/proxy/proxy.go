@@ -3,12 +3,17 @@ package proxy
 import (
 	"time"
 
+	"github.com/pingcap/tidb/util/logutil"
+
 	"github.com/tidb-incubator/weir/pkg/config"
 	"github.com/tidb-incubator/weir/pkg/configcenter"
 	"github.com/tidb-incubator/weir/pkg/proxy/driver"
 	"github.com/tidb-incubator/weir/pkg/proxy/metrics"
 	"github.com/tidb-incubator/weir/pkg/proxy/namespace"
 	"github.com/tidb-incubator/weir/pkg/proxy/server"
+	"github.com/tidb-incubator/weir/pkg/proxy/slowlog"
+	"github.com/tidb-incubator/weir/pkg/registry"
+	"go.uber.org/zap"
 )
 
 type Proxy struct {
@@ -17,6 +22,7 @@ type Proxy struct {
 	apiServer    *HttpApiServer
 	nsmgr        *namespace.NamespaceManager
 	configCenter configcenter.ConfigCenter
+	registry     registry.Registry
 }
 
 func supplementProxyConfig(cfg *config.Proxy) *config.Proxy {
@@ -37,6 +43,9 @@ func NewProxy(cfg *config.Proxy) *Proxy {
 
 func (p *Proxy) Init() error {
 	metrics.RegisterProxyMetrics(p.cfg.Cluster)
+	if err := slowlog.Init(&p.cfg.Log.SlowLogFile); err != nil {
+		return err
+	}
 	cc, err := configcenter.CreateConfigCenter(p.cfg.ConfigCenter)
 	if err != nil {
 		return err
@@ -47,7 +56,16 @@ func (p *Proxy) Init() error {
 	if err != nil {
 		return err
 	}
-	nsmgr, err := namespace.CreateNamespaceManager(nss, namespace.BuildNamespace, namespace.DefaultAsyncCloseNamespace)
+	builder := namespace.BuildNamespace
+	if p.cfg.Registry.Enable {
+		reg, err := registry.CreateRegistry(p.cfg.Registry)
+		if err != nil {
+			return err
+		}
+		p.registry = reg
+		builder = namespace.NewNamespaceBuilder(reg)
+	}
+	nsmgr, err := namespace.CreateNamespaceManager(nss, builder, namespace.DefaultAsyncCloseNamespace)
 	if err != nil {
 		return err
 	}
@@ -83,4 +101,10 @@ func (p *Proxy) Close() {
 	if p.svr != nil {
 		p.svr.Close()
 	}
+	if p.registry != nil {
+		p.registry.Close()
+	}
+	if err := slowlog.Close(); err != nil {
+		logutil.BgLogger().Warn("close slow log error", zap.Error(err))
+	}
 }
@@ -91,9 +91,13 @@ type clientConn struct {
 	attrs        map[string]string // attributes parsed from client handshake response, not used for now.
 	peerHost     string            // peer host
 	peerPort     string            // peer port
+	isUnixSocket bool              // whether the client connects by unix socket
 	status       int32             // dispatching/reading/shutdown/waitshutdown
 	lastCode     uint16            // last error code
 	collation    uint8             // collation used by client, may be different from the collation used by database.
+
+	// errors of COM_STMT_SEND_LONG_DATA by stmt id, which has no response, so they are returned by the next COM_STMT_EXECUTE
+	longDataErrs map[uint32]error
 }
 
 // newClientConn creates a *clientConn object.
@@ -19,7 +19,6 @@ import (
 	"crypto/tls"
 	"encoding/binary"
 	"io"
-	"sync/atomic"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/parser/auth"
@@ -108,7 +107,8 @@ func (cc *clientConn) readOptionalSSLRequestAndHandshakeResponse(ctx context.Con
 	}
 
 	if resp.Capability&mysql.ClientSSL > 0 {
-		tlsConfig := (*tls.Config)(atomic.LoadPointer(&cc.server.tlsConfig))
+		cc.server.reloadTLSConfigIfModified()
+		tlsConfig := cc.server.getTLSConfig()
 		if tlsConfig != nil {
 			// The packet is a SSLRequest, let's switch to TLS.
 			if err = cc.upgradeToTLS(tlsConfig); err != nil {
@@ -365,6 +365,16 @@ func parseOldHandshakeResponseBody(ctx context.Context, packet *handshakeRespons
 }
 
 func (cc *clientConn) openSessionAndDoAuth(authData []byte) error {
+	if err := cc.openSession(); err != nil {
+		return err
+	}
+	if err := cc.server.checkConnectionCount(); err != nil {
+		return err
+	}
+	return cc.doAuth(authData)
+}
+
+func (cc *clientConn) openSession() error {
 	var tlsStatePtr *tls.ConnectionState
 	if cc.tlsConn != nil {
 		tlsState := cc.tlsConn.ConnectionState()
@@ -375,10 +385,12 @@ func (cc *clientConn) openSessionAndDoAuth(authData []byte) error {
 	if err != nil {
 		return err
 	}
+	cc.ctx.SetLocalFileHandler(cc.relayLocalFile)
+	cc.ctx.SetResultsetStreamer(&resultsetStreamer{cc: cc})
+	return nil
+}
 
-	if err = cc.server.checkConnectionCount(); err != nil {
-		return err
-	}
+func (cc *clientConn) doAuth(authData []byte) error {
 	hasPassword := "YES"
 	if len(authData) == 0 {
 		hasPassword = "NO"
@@ -390,6 +402,10 @@ func (cc *clientConn) openSessionAndDoAuth(authData []byte) error {
 	if !cc.ctx.Auth(&auth.UserIdentity{Username: cc.user, Hostname: host}, authData, cc.salt) {
 		return errAccessDenied.FastGenByArgs(cc.user, host, hasPassword)
 	}
+	// unix socket is treated as secure transport, which is the same as MySQL
+	if cc.tlsConn == nil && !cc.isUnixSocket && cc.ctx.IsSecureTransportRequired() {
+		return errSecureTransportRequired.FastGenByArgs()
+	}
 	if cc.dbname != "" {
 		err = cc.useDB(context.Background(), cc.dbname)
 		if err != nil {
@@ -400,6 +416,73 @@ func (cc *clientConn) openSessionAndDoAuth(authData []byte) error {
 	return nil
 }
 
+// handleChangeUser handles COM_CHANGE_USER. The old session is closed so that the attached backend conn,
+// session variables and prepared statements are released, then a new session is authenticated,
+// which may belong to another namespace.
+func (cc *clientConn) handleChangeUser(ctx context.Context, data []byte) error {
+	var req handshakeResponse41
+	if err := parseChangeUserRequest(ctx, &req, data, cc.capability); err != nil {
+		return err
+	}
+
+	if err := cc.ctx.Close(); err != nil {
+		logutil.Logger(ctx).Warn("close old session failed", zap.Error(err))
+	}
+	cc.longDataErrs = nil
+	cc.user = req.User
+	cc.dbname = req.DBName
+	if req.Collation != 0 {
+		cc.collation = req.Collation
+	}
+
+	// The connection is not counted again, since it's already in server clients.
+	if err := cc.openSession(); err != nil {
+		return err
+	}
+	if err := cc.doAuth(req.Auth); err != nil {
+		// The old session is closed and the new one is not authenticated, so close the connection as MySQL does.
+		logutil.Logger(ctx).Warn("change user failed, close this connection", zap.String("user", req.User), zap.Error(err))
+		terror.Log(cc.writeError(err))
+		return io.EOF
+	}
+	return cc.writeOK()
+}
+
+// parseChangeUserRequest parses COM_CHANGE_USER payload without the command byte.
+// See https://dev.mysql.com/doc/internals/en/com-change-user.html
+func parseChangeUserRequest(ctx context.Context, packet *handshakeResponse41, data []byte, capability uint32) (err error) {
+	defer func() {
+		// Check malformat packet cause out of range is disgusting, but don't panic!
+		if r := recover(); r != nil {
+			logutil.Logger(ctx).Error("change user panic", zap.ByteString("packetData", data))
+			err = mysql.ErrMalformPacket
+		}
+	}()
+
+	user, remain := parseNullTermString(data)
+	if user == nil {
+		return mysql.ErrMalformPacket
+	}
+	packet.User = string(user)
+
+	if capability&mysql.ClientSecureConnection > 0 {
+		authLen := int(remain[0])
+		packet.Auth = remain[1 : 1+authLen]
+		remain = remain[1+authLen:]
+	} else {
+		packet.Auth, remain = parseNullTermString(remain)
+	}
+
+	db, remain := parseNullTermString(remain)
+	packet.DBName = string(db)
+
+	// character set is optional, plugin name and connection attributes are ignored.
+	if len(remain) >= 2 {
+		packet.Collation = remain[0]
+	}
+	return nil
+}
+
 func parseAttrs(data []byte) (map[string]string, error) {
 	attrs := make(map[string]string)
 	pos := 0
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"testing"
+
+	"github.com/pingcap/parser/auth"
+	"github.com/pingcap/parser/mysql"
+	"github.com/stretchr/testify/require"
+	"github.com/tidb-incubator/weir/pkg/config"
+)
+
+const testDeniedUser = "denied"
+
+// testQueryCtx only implements methods used in change user.
+type testQueryCtx struct {
+	QueryCtx
+	user   string
+	db     string
+	closed bool
+}
+
+func (q *testQueryCtx) Auth(user *auth.UserIdentity, authData []byte, salt []byte) bool {
+	q.user = user.Username
+	return user.Username != testDeniedUser
+}
+
+func (q *testQueryCtx) IsSecureTransportRequired() bool { return false }
+func (q *testQueryCtx) Status() uint16                  { return mysql.ServerStatusAutocommit }
+func (q *testQueryCtx) LastMessage() string             { return "" }
+func (q *testQueryCtx) AffectedRows() uint64            { return 0 }
+func (q *testQueryCtx) LastInsertID() uint64            { return 0 }
+func (q *testQueryCtx) WarningCount() uint16            { return 0 }
+
+func (q *testQueryCtx) SetLocalFileHandler(handler LocalFileHandler) {
+}
+
+func (q *testQueryCtx) SetResultsetStreamer(streamer ResultsetStreamer) {
+}
+
+func (q *testQueryCtx) Execute(ctx context.Context, sql string) ([]*StmtResult, error) {
+	q.db = sql
+	return nil, nil
+}
+
+func (q *testQueryCtx) Close() error {
+	q.closed = true
+	return nil
+}
+
+type testDriver struct{}
+
+func (d *testDriver) OpenCtx(connID uint64, capability uint32, collation uint8, dbname string, tlsState *tls.ConnectionState) (QueryCtx, error) {
+	return &testQueryCtx{}, nil
+}
+
+type captureConn struct {
+	bytesConn
+	out bytes.Buffer
+}
+
+func (c *captureConn) Write(b []byte) (int, error) {
+	return c.out.Write(b)
+}
+
+func prepareChangeUserConn() (*clientConn, *captureConn) {
+	cfg := &config.Proxy{}
+	// the only connection is counted already
+	cfg.ProxyServer.MaxConnections = 1
+	s := &Server{cfg: cfg, driver: &testDriver{}, clients: make(map[uint32]*clientConn)}
+	cc := newClientConn(s)
+	s.clients[cc.connectionID] = cc
+	conn := &captureConn{}
+	cc.setConn(conn)
+	cc.capability = mysql.ClientProtocol41 | mysql.ClientSecureConnection
+	cc.peerHost = "127.0.0.1"
+	cc.user = "old_user"
+	cc.ctx = &testQueryCtx{user: "old_user"}
+	return cc, conn
+}
+
+func buildChangeUserRequest(user, db string, authData []byte, collation uint8) []byte {
+	data := append([]byte(user), 0)
+	data = append(data, byte(len(authData)))
+	data = append(data, authData...)
+	data = append(data, []byte(db)...)
+	data = append(data, 0)
+	return append(data, collation, 0)
+}
+
+func TestParseChangeUserRequest(t *testing.T) {
+	var req handshakeResponse41
+	data := buildChangeUserRequest("hello", "test_db", []byte("12345"), mysql.DefaultCollationID)
+	require.NoError(t, parseChangeUserRequest(context.Background(), &req, data, mysql.ClientSecureConnection))
+	require.Equal(t, "hello", req.User)
+	require.Equal(t, "test_db", req.DBName)
+	require.Equal(t, []byte("12345"), req.Auth)
+	require.Equal(t, uint8(mysql.DefaultCollationID), req.Collation)
+
+	req = handshakeResponse41{}
+	data = []byte("hello\x00pwd\x00\x00")
+	require.NoError(t, parseChangeUserRequest(context.Background(), &req, data, 0))
+	require.Equal(t, "hello", req.User)
+	require.Equal(t, "", req.DBName)
+	require.Equal(t, []byte("pwd"), req.Auth)
+	require.Equal(t, uint8(0), req.Collation)
+
+	err := parseChangeUserRequest(context.Background(), &req, []byte("hello"), mysql.ClientSecureConnection)
+	require.Equal(t, mysql.ErrMalformPacket, err)
+	err = parseChangeUserRequest(context.Background(), &req, []byte("hello\x00\x10"), mysql.ClientSecureConnection)
+	require.Equal(t, mysql.ErrMalformPacket, err)
+}
+
+func TestClientConn_HandleChangeUser(t *testing.T) {
+	cc, conn := prepareChangeUserConn()
+	oldCtx := cc.ctx.(*testQueryCtx)
+
+	data := buildChangeUserRequest("new_user", "test_db", []byte("12345"), mysql.DefaultCollationID)
+	require.NoError(t, cc.handleChangeUser(context.Background(), data))
+	require.True(t, oldCtx.closed)
+	newCtx := cc.ctx.(*testQueryCtx)
+	require.NotEqual(t, oldCtx, newCtx)
+	require.Equal(t, "new_user", newCtx.user)
+	require.Equal(t, "use `test_db`", newCtx.db)
+	require.Equal(t, "new_user", cc.user)
+	require.Equal(t, "test_db", cc.dbname)
+	require.Equal(t, byte(mysql.OKHeader), conn.out.Bytes()[4])
+}
+
+func TestClientConn_HandleChangeUser_AuthFailed(t *testing.T) {
+	cc, conn := prepareChangeUserConn()
+
+	data := buildChangeUserRequest(testDeniedUser, "", nil, 0)
+	require.Equal(t, io.EOF, cc.handleChangeUser(context.Background(), data))
+	require.Equal(t, byte(mysql.ErrHeader), conn.out.Bytes()[4])
+}
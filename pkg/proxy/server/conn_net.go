@@ -109,6 +109,8 @@ func (cc *clientConn) writeError(e error) error {
 		switch y := e.(type) {
 		case *terror.Error:
 			m = y.ToSQLError()
+		case *mysql.SQLError:
+			m = y
 		default:
 			m = mysql.NewErrf(mysql.ErrUnknown, "%s", e.Error())
 		}
@@ -239,7 +241,8 @@ func (cc *clientConn) writeGoMySQLResultset(ctx context.Context, rs *gomysql.Res
 	var err error
 
 	if mysql.HasCursorExistsFlag(serverStatus) {
-		// TODO(eastfisher): writeChunksWithFetchSize
+		// rows are sent by COM_STMT_FETCH
+		err = cc.writeColumnInfo(convertFieldsToColumnInfos(rs.Fields), serverStatus)
 	} else {
 		err = cc.doWriteGoMySQLResultset(ctx, rs, binary, serverStatus)
 	}
@@ -270,6 +273,52 @@ func (cc *clientConn) doWriteGoMySQLResultset(ctx context.Context, rs *gomysql.R
 	return cc.writeEOF(serverStatus)
 }
 
+// resultsetStreamer writes the packets of a result set to client one by one, the write blocks if client
+// reads slowly, so that the backend is not read faster than the client.
+type resultsetStreamer struct {
+	cc *clientConn
+	// data is reused by packets, it's not allocated by cc.alloc which is reset for each command
+	data []byte
+}
+
+// maxStreamerBufferSize is the max size of buffer kept by resultsetStreamer after a result set is written.
+const maxStreamerBufferSize = 32 * 1024
+
+func (s *resultsetStreamer) WriteColumns(columns [][]byte, serverStatus uint16) error {
+	if err := s.WritePacket(dumpLengthEncodedInt(nil, uint64(len(columns)))); err != nil {
+		return err
+	}
+	for _, column := range columns {
+		if err := s.WritePacket(column); err != nil {
+			return err
+		}
+	}
+	return s.cc.writeEOF(serverStatus)
+}
+
+func (s *resultsetStreamer) WriteRow(row []byte) error {
+	return s.WritePacket(row)
+}
+
+func (s *resultsetStreamer) WriteEnd(serverStatus uint16) error {
+	if err := s.cc.writeEOF(serverStatus); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+func (s *resultsetStreamer) WritePacket(data []byte) error {
+	s.data = append(append(s.data[:0], 0, 0, 0, 0), data...)
+	return s.cc.writePacket(s.data)
+}
+
+func (s *resultsetStreamer) Flush() error {
+	if cap(s.data) > maxStreamerBufferSize {
+		s.data = nil
+	}
+	return s.cc.flush()
+}
+
 func convertFieldsToColumnInfos(fields []*gomysql.Field) []*ColumnInfo {
 	var rets []*ColumnInfo
 	for _, f := range fields {
@@ -417,29 +466,23 @@ func (cc *clientConn) writeChunksWithFetchSize(ctx context.Context, rs ResultSet
 	return cc.writeEOF(serverStatus)
 }
 
-func (cc *clientConn) writeMultiResultset(ctx context.Context, rss []ResultSet, binary bool) error {
+// writeMultiResultset writes the results of multiple statements, each of them is a result set or an OK packet.
+// All the results except the last one are flagged with ServerMoreResultsExists,
+// and so is the last one if moreResults is set, e.g. it's followed by an error packet.
+func (cc *clientConn) writeMultiResultset(ctx context.Context, rss []*StmtResult, binary bool, moreResults bool) error {
 	for i, rs := range rss {
-		lastRs := i == len(rss)-1
-
-		// TODO(eastfisher): currently, we do not need multi query
-		/*
-			if r, ok := rs.(*tidbResultSet).recordSet.(sqlexec.MultiQueryNoDelayResult); ok {
-				status := r.Status()
-				if !lastRs {
-					status |= mysql.ServerMoreResultsExists
-				}
-				if err := cc.writeOkWith(r.LastMessage(), r.AffectedRows(), r.LastInsertID(), status, r.WarnCount()); err != nil {
-					return err
-				}
-				continue
-			}
-		*/
-
-		status := uint16(0)
-		if !lastRs {
+		status := rs.Status
+		if i < len(rss)-1 || moreResults {
 			status |= mysql.ServerMoreResultsExists
 		}
-		if err := cc.writeResultset(ctx, rs, binary, status, 0); err != nil {
+
+		var err error
+		if rs.Resultset == nil {
+			err = cc.writeOkWith(rs.Message, rs.AffectedRows, rs.InsertId, status, rs.Warnings)
+		} else {
+			err = cc.writeGoMySQLResultset(ctx, rs.Resultset, binary, status, 0)
+		}
+		if err != nil {
 			return err
 		}
 	}
@@ -451,7 +494,7 @@ func (cc *clientConn) PeerHost(hasPassword string) (host string, err error) {
 		return cc.peerHost, nil
 	}
 	host = variable.DefHostname
-	if cc.server.isUnixSocket() {
+	if cc.isUnixSocket {
 		cc.peerHost = host
 		return
 	}
@@ -70,7 +70,7 @@ func (cc *clientConn) dispatch(ctx context.Context, data []byte) error {
 	dataStr := string(hack.String(data))
 	switch cmd {
 	case mysql.ComPing, mysql.ComStmtClose, mysql.ComStmtSendLongData, mysql.ComStmtReset,
-		mysql.ComSetOption, mysql.ComChangeUser:
+		mysql.ComSetOption, mysql.ComChangeUser, mysql.ComResetConnection:
 		cc.ctx.SetProcessInfo("", t, cmd, 0)
 	case mysql.ComInitDB:
 		cc.ctx.SetProcessInfo("use "+dataStr, t, cmd, 0)
@@ -108,17 +108,24 @@ func (cc *clientConn) dispatch(ctx context.Context, data []byte) error {
 	case mysql.ComStmtExecute:
 		return cc.handleStmtExecute(ctx, data)
 	case mysql.ComStmtFetch:
-		return mysql.NewErrf(mysql.ErrUnknown, "command %d not supported now", cmd)
+		return cc.handleStmtFetch(ctx, data)
 	case mysql.ComStmtClose:
 		return cc.handleStmtClose(ctx, data)
 	case mysql.ComStmtSendLongData:
-		return cc.handleStmtSendLongData(data)
+		return cc.handleStmtSendLongData(ctx, data)
 	case mysql.ComStmtReset:
-		return cc.handleStmtReset(data)
+		return cc.handleStmtReset(ctx, data)
 	case mysql.ComSetOption:
 		return mysql.NewErrf(mysql.ErrUnknown, "command %d not supported now", cmd)
 	case mysql.ComChangeUser:
-		return mysql.NewErrf(mysql.ErrUnknown, "command %d not supported now", cmd)
+		return cc.handleChangeUser(ctx, data)
+	case mysql.ComResetConnection:
+		if err := cc.ctx.ResetSession(ctx); err != nil {
+			return err
+		}
+		// prepared statements are released by reset
+		cc.longDataErrs = nil
+		return cc.writeOK()
 	default:
 		return mysql.NewErrf(mysql.ErrUnknown, "command %d not supported now", cmd)
 	}
@@ -139,27 +146,49 @@ func (cc *clientConn) useDB(ctx context.Context, db string) (err error) {
 
 // handleQuery executes the sql query string and writes result set or result ok to the client.
 // As the execution time of this function represents the performance of TiDB, we do time log and metrics here.
-// There is a special query `load data` that does not return result, which is handled differently.
-// Query `load stats` does not return result either.
+// The file content of `load data local infile` is read from client by relayLocalFile during the execution.
 func (cc *clientConn) handleQuery(ctx context.Context, sql string) (err error) {
-	rss, err := cc.ctx.Execute(ctx, sql)
-	if err != nil {
-		metrics.ExecuteErrorCounter.WithLabelValues(metrics.ExecuteErrorToLabel(err)).Inc()
-		return err
+	rss, execErr := cc.ctx.Execute(ctx, sql)
+	if execErr != nil {
+		metrics.ExecuteErrorCounter.WithLabelValues(metrics.ExecuteErrorToLabel(execErr)).Inc()
+	}
+	if len(rss) == 0 {
+		return execErr
 	}
 	status := atomic.LoadInt32(&cc.status)
-	if rss != nil && (status == connStatusShutdown || status == connStatusWaitShutdown) {
+	if status == connStatusShutdown || status == connStatusWaitShutdown {
 		// TODO(eastfisher): close ResultSet
 		return executor.ErrQueryInterrupted
 	}
 
-	if rss != nil {
-		err = cc.writeGoMySQLResultset(ctx, rss.Resultset, false, 0, 0)
-	} else {
-		// (eastfisher)currently we does not support load data
-		err = cc.writeOK()
+	// results of the statements executed before the failed one are sent ahead of the error
+	if err = cc.writeMultiResultset(ctx, rss, false, execErr != nil); err != nil {
+		return err
+	}
+	return execErr
+}
+
+// relayLocalFile sends the file request of LOAD DATA LOCAL INFILE to client and passes the file packets
+// to writeData until client sends an empty packet. If writeData fails, the rest packets are still read
+// and discarded, so that they are not handled as commands.
+func (cc *clientConn) relayLocalFile(filename string, writeData func(data []byte) error) error {
+	if err := cc.writeReq(filename); err != nil {
+		return err
+	}
+
+	var writeErr error
+	for {
+		data, err := cc.readPacket()
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return writeErr
+		}
+		if writeErr == nil {
+			writeErr = writeData(data)
+		}
 	}
-	return err
 }
 
 // handleFieldList returns the field list for a table.
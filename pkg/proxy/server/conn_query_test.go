@@ -0,0 +1,241 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pingcap/parser/mysql"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+type testMultiQueryCtx struct {
+	testQueryCtx
+	rets []*StmtResult
+	err  error
+}
+
+func (q *testMultiQueryCtx) Execute(ctx context.Context, sql string) ([]*StmtResult, error) {
+	return q.rets, q.err
+}
+
+func newTestMultiResults(t *testing.T) []*StmtResult {
+	rs, err := gomysql.BuildSimpleTextResultset([]string{"a"}, [][]interface{}{{1}})
+	require.NoError(t, err)
+	return []*StmtResult{
+		{
+			Result:   &gomysql.Result{Status: mysql.ServerStatusAutocommit, AffectedRows: 2, InsertId: 3},
+			Message:  "Rows matched: 2  Changed: 2  Warnings: 1",
+			Warnings: 1,
+		},
+		{Result: &gomysql.Result{Status: mysql.ServerStatusAutocommit, Resultset: rs}},
+	}
+}
+
+// getTestPacketStatus returns status of EOF packet, or OK packet with 1-byte affected rows and last insert id.
+func getTestPacketStatus(packet []byte) uint16 {
+	return binary.LittleEndian.Uint16(packet[3:5])
+}
+
+func TestClientConn_HandleQuery_MultiResults(t *testing.T) {
+	cc, conn := prepareChangeUserConn()
+	cc.capability |= mysql.ClientProtocol41
+	cc.ctx = &testMultiQueryCtx{rets: newTestMultiResults(t)}
+
+	require.NoError(t, cc.handleQuery(context.Background(), "update t set a = 1; select a from t"))
+
+	// OK, column count, column, EOF, row, EOF
+	packets := readTestPackets(conn.out.Bytes())
+	require.Len(t, packets, 6)
+	require.Equal(t, []byte{mysql.OKHeader, 2, 3}, packets[0][:3])
+	require.Equal(t, mysql.ServerStatusAutocommit|mysql.ServerMoreResultsExists, getTestPacketStatus(packets[0]))
+	// the warning count and info message are those of the statement rather than the session
+	require.Equal(t, uint16(1), binary.LittleEndian.Uint16(packets[0][5:7]))
+	require.Contains(t, string(packets[0][7:]), "Rows matched: 2  Changed: 2  Warnings: 1")
+	require.Equal(t, []byte{1}, packets[1])
+	require.Equal(t, byte(mysql.EOFHeader), packets[3][0])
+	require.Equal(t, byte(mysql.EOFHeader), packets[5][0])
+	require.Equal(t, mysql.ServerStatusAutocommit, getTestPacketStatus(packets[5]))
+}
+
+func TestClientConn_HandleQuery_MultiResultsWithError(t *testing.T) {
+	cc, conn := prepareChangeUserConn()
+	cc.capability |= mysql.ClientProtocol41
+	execErr := errors.New("mock error")
+	cc.ctx = &testMultiQueryCtx{rets: newTestMultiResults(t)[:1], err: execErr}
+
+	require.Equal(t, execErr, cc.handleQuery(context.Background(), "update t set a = 1; select a from t"))
+
+	// the error packet is written by the caller
+	packets := readTestPackets(conn.out.Bytes())
+	require.Len(t, packets, 1)
+	require.Equal(t, mysql.ServerStatusAutocommit|mysql.ServerMoreResultsExists, getTestPacketStatus(packets[0]))
+
+	conn.out.Reset()
+	cc.ctx = &testMultiQueryCtx{err: execErr}
+	require.Equal(t, execErr, cc.handleQuery(context.Background(), "select a from t"))
+	require.Empty(t, conn.out.Bytes())
+}
+
+func TestClientConn_WriteError_SQLError(t *testing.T) {
+	cc, conn := prepareChangeUserConn()
+
+	// the code of error returned by proxy is kept, so that it's the same as the one in SHOW ERRORS
+	err := mysql.NewErrf(mysql.ErrDBaccessDenied, "db %s access denied", "test_db")
+	require.NoError(t, cc.writeError(err))
+
+	packets := readTestPackets(conn.out.Bytes())
+	require.Len(t, packets, 1)
+	require.Equal(t, mysql.ErrDBaccessDenied, int(binary.LittleEndian.Uint16(packets[0][1:3])))
+	require.Equal(t, "db test_db access denied", string(packets[0][9:]))
+}
+
+// writeTestClientPackets writes packets sent by client to conn, starting from sequence.
+func writeTestClientPackets(conn *captureConn, sequence byte, packets ...[]byte) {
+	for _, p := range packets {
+		conn.b.Write([]byte{byte(len(p)), byte(len(p) >> 8), byte(len(p) >> 16), sequence})
+		conn.b.Write(p)
+		sequence++
+	}
+}
+
+func TestClientConn_RelayLocalFile(t *testing.T) {
+	cc, conn := prepareChangeUserConn()
+	writeTestClientPackets(conn, 1, []byte("1,a\n"), []byte("2,b\n"), nil)
+
+	var file []byte
+	err := cc.relayLocalFile("/tmp/t.csv", func(data []byte) error {
+		file = append(file, data...)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []byte("1,a\n2,b\n"), file)
+
+	packets := readTestPackets(conn.out.Bytes())
+	require.Len(t, packets, 1)
+	require.Equal(t, append([]byte{mysql.LocalInFileHeader}, "/tmp/t.csv"...), packets[0])
+}
+
+func TestClientConn_RelayLocalFile_WriteError(t *testing.T) {
+	cc, conn := prepareChangeUserConn()
+	writeTestClientPackets(conn, 1, []byte("1,a\n"), []byte("2,b\n"), nil, []byte{mysql.ComPing})
+
+	writeErr := errors.New("mock error")
+	writeCount := 0
+	err := cc.relayLocalFile("/tmp/t.csv", func(data []byte) error {
+		writeCount++
+		return writeErr
+	})
+	require.Equal(t, writeErr, err)
+	require.Equal(t, 1, writeCount)
+
+	// the rest file packets are discarded
+	data, err := cc.readPacket()
+	require.NoError(t, err)
+	require.Equal(t, []byte{mysql.ComPing}, data)
+}
+
+func TestResultsetStreamer(t *testing.T) {
+	cc, conn := prepareChangeUserConn()
+	cc.capability |= mysql.ClientProtocol41
+	streamer := &resultsetStreamer{cc: cc}
+
+	columns := [][]byte{[]byte("column a"), []byte("column b")}
+	require.NoError(t, streamer.WriteColumns(columns, 0))
+	require.NoError(t, streamer.WriteRow([]byte("row 1")))
+	require.NoError(t, streamer.WriteRow([]byte("row 2")))
+	require.NoError(t, streamer.WriteEnd(mysql.ServerMoreResultsExists))
+
+	// column count, columns, EOF, rows, EOF
+	packets := readTestPackets(conn.out.Bytes())
+	require.Len(t, packets, 7)
+	require.Equal(t, []byte{2}, packets[0])
+	require.Equal(t, columns[0], packets[1])
+	require.Equal(t, columns[1], packets[2])
+	require.Equal(t, mysql.ServerStatusAutocommit, getTestPacketStatus(packets[3]))
+	require.Equal(t, []byte("row 1"), packets[4])
+	require.Equal(t, []byte("row 2"), packets[5])
+	require.Equal(t, mysql.ServerStatusAutocommit|mysql.ServerMoreResultsExists, getTestPacketStatus(packets[6]))
+}
+
+func TestResultsetStreamer_Passthrough(t *testing.T) {
+	cc, conn := prepareChangeUserConn()
+	streamer := &resultsetStreamer{cc: cc}
+
+	// packets are written as they are, including the EOF packets from backend
+	packets := newTestPassthroughPackets(t, 2)
+	for _, p := range packets {
+		require.NoError(t, streamer.WritePacket(p))
+	}
+	require.NoError(t, streamer.Flush())
+	require.Equal(t, packets, readTestPackets(conn.out.Bytes()))
+
+	// the buffer is kept for the next result set unless it's too large
+	require.NotNil(t, streamer.data)
+	require.NoError(t, streamer.WritePacket(make([]byte, maxStreamerBufferSize+1)))
+	require.NoError(t, streamer.Flush())
+	require.Nil(t, streamer.data)
+}
+
+// newTestPassthroughPackets returns the packets of a text result set from backend, with 3 columns and rowCount rows.
+func newTestPassthroughPackets(t testing.TB, rowCount int) [][]byte {
+	rs := newTestBenchResultset(t, rowCount)
+	eof := []byte{mysql.EOFHeader, 0, 0, byte(mysql.ServerStatusAutocommit), 0}
+	packets := [][]byte{{byte(len(rs.Fields))}}
+	for _, field := range rs.Fields {
+		packets = append(packets, field.Dump())
+	}
+	packets = append(packets, eof)
+	for _, row := range rs.RowDatas {
+		packets = append(packets, row)
+	}
+	return append(packets, eof)
+}
+
+func newTestBenchResultset(t testing.TB, rowCount int) *gomysql.Resultset {
+	values := make([][]interface{}, 0, rowCount)
+	for i := 0; i < rowCount; i++ {
+		values = append(values, []interface{}{i, fmt.Sprintf("name_%d", i), 3.14})
+	}
+	rs, err := gomysql.BuildSimpleTextResultset([]string{"id", "name", "score"}, values)
+	require.NoError(t, err)
+	return rs
+}
+
+// BenchmarkClientConn_WriteGoMySQLResultset encodes the result set read from backend again.
+func BenchmarkClientConn_WriteGoMySQLResultset(b *testing.B) {
+	cc, conn := prepareChangeUserConn()
+	rs := newTestBenchResultset(b, 100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn.out.Reset()
+		cc.alloc.Reset()
+		if err := cc.writeGoMySQLResultset(context.Background(), rs, false, mysql.ServerStatusAutocommit, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkResultsetStreamer_Passthrough relays the packets from backend as they are.
+func BenchmarkResultsetStreamer_Passthrough(b *testing.B) {
+	cc, conn := prepareChangeUserConn()
+	streamer := &resultsetStreamer{cc: cc}
+	packets := newTestPassthroughPackets(b, 100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn.out.Reset()
+		for _, p := range packets {
+			if err := streamer.WritePacket(p); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := streamer.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
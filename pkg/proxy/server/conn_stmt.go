@@ -4,8 +4,9 @@ import (
 	"context"
 	"encoding/binary"
 
-	"github.com/pingcap/errors"
 	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/util/logutil"
+	"go.uber.org/zap"
 )
 
 // TODO(eastfisher): fix me when prepare is implemented
@@ -81,6 +82,10 @@ func (cc *clientConn) handleStmtExecute(ctx context.Context, data []byte) error
 	}
 
 	stmtID := binary.LittleEndian.Uint32(data[0:4])
+	if err, ok := cc.longDataErrs[stmtID]; ok {
+		delete(cc.longDataErrs, stmtID)
+		return err
+	}
 	ret, err := cc.ctx.StmtExecuteForward(ctx, int(stmtID), data)
 	if err != nil {
 		return err
@@ -94,14 +99,70 @@ func (cc *clientConn) handleStmtExecute(ctx context.Context, data []byte) error
 	return err
 }
 
-// TODO(eastfisher): implement this function
-func (cc *clientConn) handleStmtSendLongData(data []byte) error {
-	return errors.New("stmt not implemented")
+// handleStmtFetch streams rows from the backend cursor to client, rows are written as soon as they are read.
+func (cc *clientConn) handleStmtFetch(ctx context.Context, data []byte) error {
+	if len(data) < 8 {
+		return mysql.ErrMalformPacket
+	}
+
+	stmtID := binary.LittleEndian.Uint32(data[0:4])
+	buf := cc.alloc.AllocWithLen(4, 1024)
+	status, err := cc.ctx.StmtFetch(ctx, int(stmtID), data, func(row []byte) error {
+		buf = append(buf[0:4], row...)
+		return cc.writePacket(buf)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = cc.writeEOF(status); err != nil {
+		return err
+	}
+	return cc.flush()
 }
 
-// TODO(eastfisher): implement this function
-func (cc *clientConn) handleStmtReset(data []byte) error {
-	return errors.New("stmt not implemented")
+// handleStmtSendLongData forwards data with the stmt id and param id header to backend.
+// There is no response to COM_STMT_SEND_LONG_DATA, so the error is kept and returned by
+// the next COM_STMT_EXECUTE of the stmt, the same as MySQL.
+func (cc *clientConn) handleStmtSendLongData(ctx context.Context, data []byte) error {
+	if len(data) < 4 {
+		logutil.Logger(ctx).Warn("malformed send long data packet", zap.Int("length", len(data)))
+		return nil
+	}
+
+	stmtID := binary.LittleEndian.Uint32(data[0:4])
+	if _, ok := cc.longDataErrs[stmtID]; ok {
+		return nil
+	}
+	var err error
+	if len(data) < 6 {
+		err = mysql.ErrMalformPacket
+	} else {
+		err = cc.ctx.StmtSendLongData(ctx, int(stmtID), data)
+	}
+	if err != nil {
+		logutil.Logger(ctx).Warn("send long data error", zap.Uint32("stmtID", stmtID), zap.Error(err))
+		if cc.longDataErrs == nil {
+			cc.longDataErrs = make(map[uint32]error)
+		}
+		cc.longDataErrs[stmtID] = err
+	}
+	return nil
+}
+
+func (cc *clientConn) handleStmtReset(ctx context.Context, data []byte) error {
+	if len(data) < 4 {
+		return mysql.ErrMalformPacket
+	}
+
+	stmtID := binary.LittleEndian.Uint32(data[0:4])
+	// the long data is cleared by reset, so is its error
+	delete(cc.longDataErrs, stmtID)
+	if err := cc.ctx.StmtReset(ctx, int(stmtID)); err != nil {
+		return err
+	}
+
+	return cc.writeOK()
 }
 
 func (cc *clientConn) handleStmtClose(ctx context.Context, data []byte) error {
@@ -110,9 +171,10 @@ func (cc *clientConn) handleStmtClose(ctx context.Context, data []byte) error {
 	}
 
 	stmtID := int(binary.LittleEndian.Uint32(data[0:4]))
+	delete(cc.longDataErrs, uint32(stmtID))
+	// client doesn't read response of COM_STMT_CLOSE, so the error is only logged
 	if err := cc.ctx.StmtClose(ctx, stmtID); err != nil {
-		return err
+		logutil.Logger(ctx).Warn("close stmt error", zap.Int("stmtID", stmtID), zap.Error(err))
 	}
-
-	return cc.writeOK()
+	return nil
 }
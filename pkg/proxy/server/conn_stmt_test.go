@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/pingcap/parser/mysql"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+type testStmtQueryCtx struct {
+	testQueryCtx
+	rows        [][]byte
+	longData    []byte
+	longDataErr error
+	executeIDs  []int
+	resetID     int
+	closeID     int
+}
+
+func (q *testStmtQueryCtx) StmtExecuteForward(ctx context.Context, stmtId int, data []byte) (*gomysql.Result, error) {
+	q.executeIDs = append(q.executeIDs, stmtId)
+	return nil, nil
+}
+
+func (q *testStmtQueryCtx) StmtFetch(ctx context.Context, stmtId int, data []byte, rowHandler func(row []byte) error) (uint16, error) {
+	for _, row := range q.rows {
+		if err := rowHandler(row); err != nil {
+			return 0, err
+		}
+	}
+	return mysql.ServerStatusLastRowSend, nil
+}
+
+func (q *testStmtQueryCtx) StmtSendLongData(ctx context.Context, stmtId int, data []byte) error {
+	if q.longDataErr != nil {
+		return q.longDataErr
+	}
+	q.longData = append(q.longData, data...)
+	return nil
+}
+
+func (q *testStmtQueryCtx) StmtReset(ctx context.Context, stmtId int) error {
+	q.resetID = stmtId
+	return nil
+}
+
+func (q *testStmtQueryCtx) StmtClose(ctx context.Context, stmtId int) error {
+	q.closeID = stmtId
+	return nil
+}
+
+// readTestPackets splits written data into packet payloads.
+func readTestPackets(data []byte) [][]byte {
+	var packets [][]byte
+	for len(data) >= 4 {
+		length := int(uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16)
+		packets = append(packets, data[4:4+length])
+		data = data[4+length:]
+	}
+	return packets
+}
+
+func TestClientConn_HandleStmtFetch(t *testing.T) {
+	cc, conn := prepareChangeUserConn()
+	cc.ctx = &testStmtQueryCtx{rows: [][]byte{[]byte("row1"), []byte("row2")}}
+
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint32(data[0:4], 1)
+	binary.LittleEndian.PutUint32(data[4:8], 2)
+	require.NoError(t, cc.handleStmtFetch(context.Background(), data))
+
+	packets := readTestPackets(conn.out.Bytes())
+	require.Len(t, packets, 3)
+	require.Equal(t, []byte("row1"), packets[0])
+	require.Equal(t, []byte("row2"), packets[1])
+	require.Equal(t, byte(mysql.EOFHeader), packets[2][0])
+	status := binary.LittleEndian.Uint16(packets[2][3:5])
+	require.True(t, status&mysql.ServerStatusLastRowSend > 0)
+
+	require.Equal(t, mysql.ErrMalformPacket, cc.handleStmtFetch(context.Background(), data[0:4]))
+}
+
+func TestClientConn_HandleStmtSendLongData(t *testing.T) {
+	cc, conn := prepareChangeUserConn()
+	queryCtx := &testStmtQueryCtx{}
+	cc.ctx = queryCtx
+
+	data := append([]byte{1, 0, 0, 0, 0, 0}, "blob"...)
+	require.NoError(t, cc.handleStmtSendLongData(context.Background(), data))
+	require.Equal(t, data, queryCtx.longData)
+	// no response for COM_STMT_SEND_LONG_DATA
+	require.Empty(t, conn.out.Bytes())
+
+}
+
+func TestClientConn_HandleStmtSendLongData_Error(t *testing.T) {
+	cc, conn := prepareChangeUserConn()
+	queryCtx := &testStmtQueryCtx{longDataErr: mysql.NewErr(mysql.ErrUnknownStmtHandler, 1, "mysqld_stmt_send_long_data")}
+	cc.ctx = queryCtx
+	executeData := []byte{1, 0, 0, 0, 0, 1, 0, 0, 0}
+
+	// the errors are not written to client
+	require.NoError(t, cc.handleStmtSendLongData(context.Background(), append([]byte{1, 0, 0, 0, 0, 0}, "blob"...)))
+	require.NoError(t, cc.handleStmtSendLongData(context.Background(), []byte{2, 0, 0, 0, 0}))
+	require.NoError(t, cc.handleStmtSendLongData(context.Background(), []byte{3}))
+	require.Empty(t, conn.out.Bytes())
+
+	// the next execute of the stmt returns the error without executing it, and the one after succeeds
+	require.Equal(t, queryCtx.longDataErr, cc.handleStmtExecute(context.Background(), executeData))
+	require.Empty(t, queryCtx.executeIDs)
+	require.NoError(t, cc.handleStmtExecute(context.Background(), executeData))
+	require.Equal(t, []int{1}, queryCtx.executeIDs)
+	packets := readTestPackets(conn.out.Bytes())
+	require.Len(t, packets, 1)
+	require.Equal(t, byte(mysql.OKHeader), packets[0][0])
+
+	// the error of malformed packet is cleared by reset
+	require.NoError(t, cc.handleStmtReset(context.Background(), []byte{2, 0, 0, 0}))
+	executeData[0] = 2
+	require.NoError(t, cc.handleStmtExecute(context.Background(), executeData))
+	require.Equal(t, []int{1, 2}, queryCtx.executeIDs)
+}
+
+func TestClientConn_HandleStmtReset(t *testing.T) {
+	cc, conn := prepareChangeUserConn()
+	queryCtx := &testStmtQueryCtx{}
+	cc.ctx = queryCtx
+
+	require.NoError(t, cc.handleStmtReset(context.Background(), []byte{2, 0, 0, 0}))
+	require.Equal(t, 2, queryCtx.resetID)
+	require.Equal(t, byte(mysql.OKHeader), conn.out.Bytes()[4])
+
+	require.Equal(t, mysql.ErrMalformPacket, cc.handleStmtReset(context.Background(), []byte{2}))
+}
+
+func TestClientConn_HandleStmtClose(t *testing.T) {
+	cc, conn := prepareChangeUserConn()
+	queryCtx := &testStmtQueryCtx{}
+	cc.ctx = queryCtx
+
+	require.NoError(t, cc.handleStmtClose(context.Background(), []byte{3, 0, 0, 0}))
+	require.Equal(t, 3, queryCtx.closeID)
+	// no response for COM_STMT_CLOSE
+	require.Empty(t, conn.out.Bytes())
+}
@@ -33,6 +33,24 @@ type IDriver interface {
 	OpenCtx(connID uint64, capability uint32, collation uint8, dbname string, tlsState *tls.ConnectionState) (QueryCtx, error)
 }
 
+// LocalFileHandler sends the file request of LOAD DATA LOCAL INFILE to client,
+// and passes the file content sent by client to writeData packet by packet.
+type LocalFileHandler func(filename string, writeData func(data []byte) error) error
+
+// ResultsetStreamer writes a text result set to client while it's being read from backend, so that the rows are
+// never buffered in proxy. Column definition and row packets from backend are written as they are except for
+// the sequence ids, and the EOF packets are written with serverStatus.
+type ResultsetStreamer interface {
+	WriteColumns(columns [][]byte, serverStatus uint16) error
+	WriteRow(row []byte) error
+	WriteEnd(serverStatus uint16) error
+	// WritePacket writes a response packet from backend as it is, it's used in passthrough mode,
+	// where all the packets including OK and EOF are from backend.
+	WritePacket(data []byte) error
+	// Flush sends the written packets to client.
+	Flush() error
+}
+
 // QueryCtx is the interface to execute command.
 type QueryCtx interface {
 	// Status returns server status code.
@@ -67,8 +85,10 @@ type QueryCtx interface {
 	// CurrentDB returns current DB.
 	CurrentDB() string
 
-	// Execute executes a SQL statement.
-	Execute(ctx context.Context, sql string) (*mysql.Result, error)
+	// Execute executes the SQL statements in sql and returns a result for each of them,
+	// a result without Resultset is sent as an OK packet.
+	// If a statement fails, results of the statements executed before it are returned with the error.
+	Execute(ctx context.Context, sql string) ([]*StmtResult, error)
 
 	// ExecuteInternal executes a internal SQL statement.
 	ExecuteInternal(ctx context.Context, sql string) ([]ResultSet, error)
@@ -76,11 +96,28 @@ type QueryCtx interface {
 	// SetClientCapability sets client capability flags
 	SetClientCapability(uint32)
 
+	// SetLocalFileHandler sets the handler to read file content from client for LOAD DATA LOCAL INFILE.
+	SetLocalFileHandler(handler LocalFileHandler)
+
+	// SetResultsetStreamer sets the streamer to write result sets of Execute to client directly,
+	// the result sets written by it are not returned by Execute.
+	SetResultsetStreamer(streamer ResultsetStreamer)
+
 	// Prepare prepares a statement.
 	Prepare(ctx context.Context, sql string) (stmtId int, columns, params []*ColumnInfo, err error)
 
 	StmtExecuteForward(ctx context.Context, stmtId int, data []byte) (*mysql.Result, error)
 
+	// StmtFetch fetches rows from the cursor opened by StmtExecuteForward,
+	// each binary row is passed to rowHandler and the status of the last EOF packet is returned.
+	StmtFetch(ctx context.Context, stmtId int, data []byte, rowHandler func(row []byte) error) (uint16, error)
+
+	// StmtSendLongData sends a chunk of parameter data to the prepared statement.
+	StmtSendLongData(ctx context.Context, stmtId int, data []byte) error
+
+	// StmtReset resets the long data and the cursor of the prepared statement.
+	StmtReset(ctx context.Context, stmtId int) error
+
 	StmtClose(ctx context.Context, stmtId int) error
 
 	// FieldList returns columns of a table.
@@ -92,6 +129,12 @@ type QueryCtx interface {
 	// Auth verifies user's authentication.
 	Auth(user *auth.UserIdentity, auth []byte, salt []byte) bool
 
+	// ResetSession resets session state for COM_RESET_CONNECTION without re-authentication.
+	ResetSession(ctx context.Context) error
+
+	// IsSecureTransportRequired returns true if the namespace of authenticated user only accepts TLS connections.
+	IsSecureTransportRequired() bool
+
 	// ShowProcess shows the information about the session.
 	ShowProcess() *util.ProcessInfo
 
@@ -155,3 +198,11 @@ type fetchNotifier interface {
 	// it will be used in server-side cursor.
 	OnFetchReturned()
 }
+
+// StmtResult is the result of a statement executed by QueryCtx.Execute. The info message and warning count
+// are kept with each result, since the session only keeps those of the last statement.
+type StmtResult struct {
+	*mysql.Result
+	Message  string
+	Warnings uint16
+}
@@ -17,12 +17,15 @@ import (
 	"context"
 	"math/rand"
 	"net"
+	"os"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
 
 	"github.com/tidb-incubator/weir/pkg/config"
+	"github.com/tidb-incubator/weir/pkg/util/proxyprotocol"
 	"github.com/tidb-incubator/weir/pkg/util/timer"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/parser/mysql"
@@ -58,8 +61,12 @@ const defaultCapability = mysql.ClientLongPassword | mysql.ClientLongFlag |
 type Server struct {
 	cfg            *config.Proxy
 	tlsConfig      unsafe.Pointer // *tls.Config
+	tlsLock        sync.Mutex     // protects tlsModTime and tlsLastCheck
+	tlsModTime     time.Time      // the latest modification time of tls files when they are loaded
+	tlsLastCheck   time.Time
 	driver         IDriver
 	listener       net.Listener
+	socket         net.Listener // nil if unix socket is not configured
 	rwlock         sync.RWMutex
 	clients        map[uint32]*clientConn
 	baseConnID     uint32
@@ -85,7 +92,9 @@ func NewServer(cfg *config.Proxy, driver IDriver) (*Server, error) {
 		tw:             tw,
 	}
 
-	// TODO(eastfisher): set tlsConfig
+	if err := s.initTLSConfig(); err != nil {
+		return nil, err
+	}
 
 	setSystemTimeZoneVariable()
 
@@ -94,6 +103,10 @@ func NewServer(cfg *config.Proxy, driver IDriver) (*Server, error) {
 	if err := s.initListener(); err != nil {
 		return nil, err
 	}
+	if err := s.initSocketListener(); err != nil {
+		terror.Log(s.listener.Close())
+		return nil, err
+	}
 
 	// TODO(eastfisher): init status http server
 
@@ -110,23 +123,94 @@ func (s *Server) initCapability() {
 	}
 }
 
-// TODO(eastfisher): support unix socket and proxy protocol
 func (s *Server) initListener() error {
 	listener, err := net.Listen("tcp", s.cfg.ProxyServer.Addr)
 	if err != nil {
 		return err
 	}
+
+	ppCfg := s.cfg.ProxyServer.ProxyProtocol
+	if len(ppCfg.Networks) > 0 {
+		ppListener, err := proxyprotocol.NewListener(listener, ppCfg.Networks, time.Duration(ppCfg.HeaderTimeout)*time.Second)
+		if err != nil {
+			terror.Log(listener.Close())
+			return errors.WithMessage(err, "create proxy protocol listener error")
+		}
+		logutil.BgLogger().Info("proxy protocol is enabled", zap.Strings("networks", ppCfg.Networks))
+		listener = ppListener
+	}
+
 	s.listener = listener
 	return nil
 }
 
+func (s *Server) initSocketListener() error {
+	socket := s.cfg.ProxyServer.Socket
+	if socket == "" {
+		return nil
+	}
+
+	var perm uint64
+	if s.cfg.ProxyServer.SocketPermission != "" {
+		var err error
+		if perm, err = strconv.ParseUint(s.cfg.ProxyServer.SocketPermission, 8, 32); err != nil {
+			return errors.Errorf("invalid socket permission: %s", s.cfg.ProxyServer.SocketPermission)
+		}
+	}
+
+	if err := removeStaleSocket(socket); err != nil {
+		return err
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return errors.WithMessage(err, "listen unix socket error")
+	}
+	if s.cfg.ProxyServer.SocketPermission != "" {
+		if err := os.Chmod(socket, os.FileMode(perm)); err != nil {
+			terror.Log(listener.Close())
+			return errors.WithMessage(err, "set unix socket permission error")
+		}
+	}
+
+	logutil.BgLogger().Info("unix socket is enabled", zap.String("socket", socket))
+	s.socket = listener
+	return nil
+}
+
+// removeStaleSocket removes socket file left by a crashed process,
+// it returns error if the socket is still in use.
+func removeStaleSocket(socket string) error {
+	if _, err := os.Stat(socket); os.IsNotExist(err) {
+		return nil
+	}
+	if conn, err := net.DialTimeout("unix", socket, time.Second); err == nil {
+		terror.Log(conn.Close())
+		return errors.Errorf("unix socket is already in use: %s", socket)
+	}
+	if err := os.Remove(socket); err != nil {
+		return errors.WithMessage(err, "remove stale unix socket error")
+	}
+	return nil
+}
+
 func (s *Server) Run() error {
 	metrics.ServerEventCounter.WithLabelValues(metrics.EventStart).Inc()
 
 	// TODO(eastfisher): startStatusHTTP()
 
+	if s.socket != nil {
+		go func() {
+			if err := s.startNetworkListener(s.socket, true); err != nil {
+				logutil.BgLogger().Error("unix socket listener stopped", zap.Error(err))
+			}
+		}()
+	}
+	return s.startNetworkListener(s.listener, false)
+}
+
+func (s *Server) startNetworkListener(listener net.Listener, isUnixSocket bool) error {
 	for {
-		conn, err := s.listener.Accept()
+		conn, err := listener.Accept()
 		if err != nil {
 			if opErr, ok := err.(*net.OpError); ok {
 				if opErr.Err.Error() == "use of closed network connection" {
@@ -134,13 +218,11 @@ func (s *Server) Run() error {
 				}
 			}
 
-			// TODO(eastfisher): support PROXY protocol
-
 			logutil.BgLogger().Error("accept failed", zap.Error(err))
 			return errors.Trace(err)
 		}
 
-		clientConn := s.newConn(conn)
+		clientConn := s.newConn(conn, isUnixSocket)
 		go s.onConn(clientConn)
 	}
 }
@@ -180,10 +262,11 @@ func (s *Server) onConn(conn *clientConn) {
 	conn.Run(ctx)
 }
 
-func (s *Server) newConn(conn net.Conn) *clientConn {
+func (s *Server) newConn(conn net.Conn, isUnixSocket bool) *clientConn {
 	cc := newClientConn(s)
+	cc.isUnixSocket = isUnixSocket
 	if s.cfg.Performance.TCPKeepAlive {
-		if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if tcpConn, ok := unwrapConn(conn).(*net.TCPConn); ok {
 			if err := tcpConn.SetKeepAlive(true); err != nil {
 				logutil.BgLogger().Error("failed to set tcp keep alive option", zap.Error(err))
 			}
@@ -194,6 +277,14 @@ func (s *Server) newConn(conn net.Conn) *clientConn {
 	return cc
 }
 
+// unwrapConn returns the underlying conn of wrapped conn such as proxy protocol conn.
+func unwrapConn(conn net.Conn) net.Conn {
+	if wrapped, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		return wrapped.NetConn()
+	}
+	return conn
+}
+
 func (s *Server) checkConnectionCount() error {
 	// When the value of MaxConnections is 0, the number of connections is unlimited.
 	if int(s.cfg.ProxyServer.MaxConnections) == 0 {
@@ -212,13 +303,8 @@ func (s *Server) checkConnectionCount() error {
 	return nil
 }
 
-// TODO(eastfisher): implement this function
-func (s *Server) isUnixSocket() bool {
-	return false
-}
-
 // Close closes the server.
-// TODO(eastfisher): implement this function, close unix socket, status server, and gRPC server.
+// TODO(eastfisher): implement this function, close status server, and gRPC server.
 func (s *Server) Close() {
 	s.rwlock.Lock()
 	defer s.rwlock.Unlock()
@@ -228,6 +314,12 @@ func (s *Server) Close() {
 		terror.Log(errors.Trace(err))
 		s.listener = nil
 	}
+	if s.socket != nil {
+		// unix socket file is removed on closing
+		err := s.socket.Close()
+		terror.Log(errors.Trace(err))
+		s.socket = nil
+	}
 	metrics.ServerEventCounter.WithLabelValues(metrics.EventClose).Inc()
 }
 
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pingcap/tidb/sessionctx/variable"
+	"github.com/stretchr/testify/require"
+	"github.com/tidb-incubator/weir/pkg/config"
+)
+
+func prepareSocketServer(t *testing.T, socket, perm string) *Server {
+	cfg := &config.Proxy{}
+	cfg.ProxyServer.Socket = socket
+	cfg.ProxyServer.SocketPermission = perm
+	return &Server{cfg: cfg}
+}
+
+func TestServer_InitSocketListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "weir_socket")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "weir.sock")
+
+	s := prepareSocketServer(t, socket, "0600")
+	require.NoError(t, s.initSocketListener())
+	st, err := os.Stat(socket)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), st.Mode().Perm())
+
+	conn, err := net.Dial("unix", socket)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	// socket in use
+	require.Error(t, prepareSocketServer(t, socket, "").initSocketListener())
+
+	s.Close()
+	_, err = os.Stat(socket)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestServer_InitSocketListener_StaleSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "weir_socket")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "weir.sock")
+	require.NoError(t, ioutil.WriteFile(socket, nil, 0600))
+
+	s := prepareSocketServer(t, socket, "")
+	require.NoError(t, s.initSocketListener())
+	defer s.Close()
+	conn, err := net.Dial("unix", socket)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+}
+
+func TestServer_InitSocketListener_Error(t *testing.T) {
+	require.NoError(t, prepareSocketServer(t, "", "").initSocketListener())
+	require.Error(t, prepareSocketServer(t, "weir.sock", "0999").initSocketListener())
+}
+
+func TestClientConn_PeerHost_UnixSocket(t *testing.T) {
+	cc := &clientConn{isUnixSocket: true}
+	host, err := cc.PeerHost("NO")
+	require.NoError(t, err)
+	require.Equal(t, variable.DefHostname, host)
+}
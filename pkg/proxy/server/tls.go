@@ -0,0 +1,167 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/util/logutil"
+	"github.com/tidb-incubator/weir/pkg/config"
+	"go.uber.org/zap"
+)
+
+var errTLSNotConfigured = errors.New("tls is not configured")
+
+// tlsFilesCheckInterval is the min interval to check whether the cert, key and ca files are modified,
+// the files are checked on tls handshakes so that rotated certificates take effect without reloading manually.
+var tlsFilesCheckInterval = 10 * time.Second
+
+// loadTLSConfig returns nil if cert or key is not configured.
+func loadTLSConfig(cfg *config.ProxyServerSecurity) (*tls.Config, error) {
+	if cfg.SSLCert == "" || cfg.SSLKey == "" {
+		if cfg.VerifyClientCert {
+			return nil, errors.New("ssl_cert and ssl_key are required to verify client cert")
+		}
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.SSLCert, cfg.SSLKey)
+	if err != nil {
+		return nil, errors.WithMessage(err, "load server cert and key error")
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+	}
+
+	if cfg.SSLCA != "" {
+		caData, err := ioutil.ReadFile(cfg.SSLCA)
+		if err != nil {
+			return nil, errors.WithMessage(err, "read ca file error")
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caData) {
+			return nil, errors.Errorf("invalid ca file: %s", cfg.SSLCA)
+		}
+		tlsConfig.ClientCAs = certPool
+	} else if cfg.VerifyClientCert {
+		return nil, errors.New("ssl_ca is required to verify client cert")
+	}
+
+	if cfg.VerifyClientCert {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+func (s *Server) initTLSConfig() error {
+	s.tlsLock.Lock()
+	defer s.tlsLock.Unlock()
+
+	modTime, err := tlsFilesModTime(&s.cfg.ProxyServer.Security)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := loadTLSConfig(&s.cfg.ProxyServer.Security)
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		logutil.BgLogger().Info("tls is enabled for client connections",
+			zap.Bool("verify_client_cert", s.cfg.ProxyServer.Security.VerifyClientCert))
+	}
+	s.setTLSConfig(tlsConfig)
+	s.tlsModTime = modTime
+	s.tlsLastCheck = time.Now()
+	return nil
+}
+
+// ReloadTLSConfig reloads cert, key and ca files, it's used for certificate rotation.
+// Connections established before reloading are not affected.
+func (s *Server) ReloadTLSConfig() error {
+	if s.getTLSConfig() == nil {
+		return errTLSNotConfigured
+	}
+
+	s.tlsLock.Lock()
+	defer s.tlsLock.Unlock()
+	return s.reloadTLSConfigLocked()
+}
+
+// reloadTLSConfigIfModified reloads the tls config if any of the cert, key and ca files is modified
+// since the last loading. The files are checked at most once per tlsFilesCheckInterval.
+func (s *Server) reloadTLSConfigIfModified() {
+	if s.getTLSConfig() == nil {
+		return
+	}
+
+	s.tlsLock.Lock()
+	defer s.tlsLock.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.tlsLastCheck) < tlsFilesCheckInterval {
+		return
+	}
+	s.tlsLastCheck = now
+
+	modTime, err := tlsFilesModTime(&s.cfg.ProxyServer.Security)
+	if err != nil {
+		logutil.BgLogger().Warn("check tls files error", zap.Error(err))
+		return
+	}
+	if modTime.Equal(s.tlsModTime) {
+		return
+	}
+	// the files may be partially written, keep the old tls config and retry in the next check
+	if err := s.reloadTLSConfigLocked(); err != nil {
+		logutil.BgLogger().Warn("reload modified tls files error", zap.Error(err))
+	}
+}
+
+func (s *Server) reloadTLSConfigLocked() error {
+	modTime, err := tlsFilesModTime(&s.cfg.ProxyServer.Security)
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := loadTLSConfig(&s.cfg.ProxyServer.Security)
+	if err != nil {
+		return err
+	}
+	s.setTLSConfig(tlsConfig)
+	s.tlsModTime = modTime
+	logutil.BgLogger().Info("tls config is reloaded")
+	return nil
+}
+
+// tlsFilesModTime returns the latest modification time of the cert, key and ca files.
+// It's got before loading the files, so a modification during loading is caught by the next check.
+func tlsFilesModTime(cfg *config.ProxyServerSecurity) (time.Time, error) {
+	var modTime time.Time
+	for _, file := range []string{cfg.SSLCert, cfg.SSLKey, cfg.SSLCA} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, errors.WithMessage(err, "stat tls file error")
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return modTime, nil
+}
+
+func (s *Server) getTLSConfig() *tls.Config {
+	return (*tls.Config)(atomic.LoadPointer(&s.tlsConfig))
+}
+
+func (s *Server) setTLSConfig(tlsConfig *tls.Config) {
+	atomic.StorePointer(&s.tlsConfig, unsafe.Pointer(tlsConfig))
+}
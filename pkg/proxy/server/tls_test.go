@@ -0,0 +1,179 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidb-incubator/weir/pkg/config"
+)
+
+type testCertFiles struct {
+	dir  string
+	ca   string
+	cert string
+	key  string
+}
+
+// generateTestCertFiles generates a self-signed cert used as both ca and server cert.
+func generateTestCertFiles(t *testing.T, commonName string) *testCertFiles {
+	dir, err := ioutil.TempDir("", "weir_tls")
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	files := &testCertFiles{
+		dir:  dir,
+		ca:   filepath.Join(dir, "ca.pem"),
+		cert: filepath.Join(dir, "cert.pem"),
+		key:  filepath.Join(dir, "key.pem"),
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	require.NoError(t, ioutil.WriteFile(files.ca, certPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(files.cert, certPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(files.key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return files
+}
+
+func getLeafCommonName(t *testing.T, tlsConfig *tls.Config) string {
+	cert, err := x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0])
+	require.NoError(t, err)
+	return cert.Subject.CommonName
+}
+
+func TestLoadTLSConfig_Disabled(t *testing.T) {
+	tlsConfig, err := loadTLSConfig(&config.ProxyServerSecurity{})
+	require.NoError(t, err)
+	require.Nil(t, tlsConfig)
+
+	_, err = loadTLSConfig(&config.ProxyServerSecurity{VerifyClientCert: true})
+	require.Error(t, err)
+}
+
+func TestLoadTLSConfig_Success(t *testing.T) {
+	files := generateTestCertFiles(t, "weir")
+	defer os.RemoveAll(files.dir)
+
+	tlsConfig, err := loadTLSConfig(&config.ProxyServerSecurity{SSLCert: files.cert, SSLKey: files.key})
+	require.NoError(t, err)
+	require.Equal(t, tls.VerifyClientCertIfGiven, tlsConfig.ClientAuth)
+	require.Nil(t, tlsConfig.ClientCAs)
+
+	tlsConfig, err = loadTLSConfig(&config.ProxyServerSecurity{
+		SSLCA:            files.ca,
+		SSLCert:          files.cert,
+		SSLKey:           files.key,
+		VerifyClientCert: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+	require.NotNil(t, tlsConfig.ClientCAs)
+}
+
+func TestLoadTLSConfig_Error(t *testing.T) {
+	files := generateTestCertFiles(t, "weir")
+	defer os.RemoveAll(files.dir)
+
+	_, err := loadTLSConfig(&config.ProxyServerSecurity{SSLCert: files.cert, SSLKey: files.cert})
+	require.Error(t, err)
+
+	_, err = loadTLSConfig(&config.ProxyServerSecurity{SSLCert: files.cert, SSLKey: files.key, SSLCA: files.key})
+	require.Error(t, err)
+
+	_, err = loadTLSConfig(&config.ProxyServerSecurity{SSLCert: files.cert, SSLKey: files.key, VerifyClientCert: true})
+	require.Error(t, err)
+}
+
+func TestServer_ReloadTLSConfig(t *testing.T) {
+	files := generateTestCertFiles(t, "weir_old")
+	defer os.RemoveAll(files.dir)
+
+	s := &Server{cfg: &config.Proxy{}}
+	require.NoError(t, s.initTLSConfig())
+	require.EqualError(t, s.ReloadTLSConfig(), errTLSNotConfigured.Error())
+
+	s.cfg.ProxyServer.Security = config.ProxyServerSecurity{SSLCert: files.cert, SSLKey: files.key}
+	require.NoError(t, s.initTLSConfig())
+	require.Equal(t, "weir_old", getLeafCommonName(t, s.getTLSConfig()))
+
+	newFiles := generateTestCertFiles(t, "weir_new")
+	defer os.RemoveAll(newFiles.dir)
+	require.NoError(t, os.Rename(newFiles.cert, files.cert))
+	require.NoError(t, os.Rename(newFiles.key, files.key))
+	require.NoError(t, s.ReloadTLSConfig())
+	require.Equal(t, "weir_new", getLeafCommonName(t, s.getTLSConfig()))
+
+	// keep the old tls config if reloading failed
+	require.NoError(t, ioutil.WriteFile(files.key, []byte("invalid"), 0600))
+	require.Error(t, s.ReloadTLSConfig())
+	require.Equal(t, "weir_new", getLeafCommonName(t, s.getTLSConfig()))
+}
+
+func TestServer_ReloadTLSConfigIfModified(t *testing.T) {
+	files := generateTestCertFiles(t, "weir_old")
+	defer os.RemoveAll(files.dir)
+
+	s := &Server{cfg: &config.Proxy{}}
+	s.cfg.ProxyServer.Security = config.ProxyServerSecurity{SSLCert: files.cert, SSLKey: files.key}
+	require.NoError(t, s.initTLSConfig())
+
+	newFiles := generateTestCertFiles(t, "weir_new")
+	defer os.RemoveAll(newFiles.dir)
+	require.NoError(t, os.Rename(newFiles.cert, files.cert))
+	require.NoError(t, os.Rename(newFiles.key, files.key))
+	modTime := s.tlsModTime.Add(time.Minute)
+	require.NoError(t, os.Chtimes(files.cert, modTime, modTime))
+
+	// the files are not checked within the interval
+	s.reloadTLSConfigIfModified()
+	require.Equal(t, "weir_old", getLeafCommonName(t, s.getTLSConfig()))
+
+	s.tlsLastCheck = time.Now().Add(-tlsFilesCheckInterval)
+	s.reloadTLSConfigIfModified()
+	require.Equal(t, "weir_new", getLeafCommonName(t, s.getTLSConfig()))
+	require.Equal(t, modTime.UnixNano(), s.tlsModTime.UnixNano())
+
+	// keep the old tls config if reloading failed, and retry in the next check
+	require.NoError(t, ioutil.WriteFile(files.key, []byte("invalid"), 0600))
+	modTime = modTime.Add(time.Minute)
+	require.NoError(t, os.Chtimes(files.key, modTime, modTime))
+	s.tlsLastCheck = time.Now().Add(-tlsFilesCheckInterval)
+	s.reloadTLSConfigIfModified()
+	require.Equal(t, "weir_new", getLeafCommonName(t, s.getTLSConfig()))
+	require.NotEqual(t, modTime.UnixNano(), s.tlsModTime.UnixNano())
+}
+
+func TestServer_ReloadTLSConfigIfModified_TLSNotConfigured(t *testing.T) {
+	s := &Server{cfg: &config.Proxy{}}
+	require.NoError(t, s.initTLSConfig())
+	s.tlsLastCheck = time.Time{}
+	s.reloadTLSConfigIfModified()
+	require.Nil(t, s.getTLSConfig())
+	require.True(t, s.tlsLastCheck.IsZero())
+}
@@ -0,0 +1,144 @@
+package slowlog
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/tidb-incubator/weir/pkg/config"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	DefaultSlowLogMaxSize = 300 // MB
+
+	// field names are the same as TiDB slow log if possible,
+	// so that slow log tools of TiDB can be used to parse weir slow log.
+	timeFormat          = time.RFC3339Nano
+	fieldPrefix         = "# "
+	fieldTime           = "Time: "
+	fieldUserHost       = "User@Host: "
+	fieldConnID         = "Conn_ID: "
+	fieldQueryTime      = "Query_time: "
+	fieldNamespace      = "Namespace: "
+	fieldBackendAddr    = "Backend_addr: "
+	fieldDB             = "DB: "
+	fieldDigest         = "Digest: "
+	fieldNormalizedSQL  = "Normalized_sql: "
+	fieldSucc           = "Succ: "
+	sqlTerminator       = ";"
+	lineBreakEscapeChar = " "
+)
+
+var (
+	mu     sync.Mutex
+	output io.WriteCloser // nil if slow log is disabled
+)
+
+type Entry struct {
+	Time          time.Time
+	Namespace     string
+	User          string
+	Host          string
+	ConnID        uint64
+	DB            string
+	Digest        string
+	NormalizedSQL string
+	BackendAddr   string
+	QueryTime     time.Duration
+	Succ          bool
+	SQL           string
+}
+
+// Init opens slow log file with rotation, slow log is disabled if filename is empty.
+func Init(cfg *config.LogFile) error {
+	if cfg.Filename == "" {
+		return nil
+	}
+	if st, err := os.Stat(cfg.Filename); err == nil && st.IsDir() {
+		return errors.Errorf("slow log file is a directory: %s", cfg.Filename)
+	}
+
+	maxSize := cfg.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultSlowLogMaxSize
+	}
+	setOutput(&lumberjack.Logger{
+		Filename:   cfg.Filename,
+		MaxSize:    maxSize,
+		MaxAge:     cfg.MaxDays,
+		MaxBackups: cfg.MaxBackups,
+		LocalTime:  true,
+	})
+	return nil
+}
+
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return output != nil
+}
+
+func Log(e *Entry) error {
+	data := e.Format()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if output == nil {
+		return nil
+	}
+	_, err := output.Write(data)
+	return err
+}
+
+func Close() error {
+	return setOutput(nil)
+}
+
+func setOutput(w io.WriteCloser) error {
+	mu.Lock()
+	defer mu.Unlock()
+	var err error
+	if output != nil {
+		err = output.Close()
+	}
+	output = w
+	return err
+}
+
+func (e *Entry) Format() []byte {
+	var buf bytes.Buffer
+	writeField(&buf, fieldTime, e.Time.Format(timeFormat))
+	writeField(&buf, fieldUserHost, e.User+"["+e.User+"] @ "+e.Host+" ["+e.Host+"]")
+	writeField(&buf, fieldConnID, strconv.FormatUint(e.ConnID, 10))
+	writeField(&buf, fieldQueryTime, strconv.FormatFloat(e.QueryTime.Seconds(), 'f', -1, 64))
+	writeField(&buf, fieldNamespace, e.Namespace)
+	writeField(&buf, fieldBackendAddr, e.BackendAddr)
+	writeField(&buf, fieldDB, e.DB)
+	writeField(&buf, fieldDigest, e.Digest)
+	writeField(&buf, fieldNormalizedSQL, escapeLineBreak(e.NormalizedSQL))
+	writeField(&buf, fieldSucc, strconv.FormatBool(e.Succ))
+
+	buf.WriteString(e.SQL)
+	if !strings.HasSuffix(e.SQL, sqlTerminator) {
+		buf.WriteString(sqlTerminator)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func writeField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(fieldPrefix)
+	buf.WriteString(name)
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+func escapeLineBreak(s string) string {
+	return strings.ReplaceAll(s, "\n", lineBreakEscapeChar)
+}
@@ -0,0 +1,84 @@
+package slowlog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tidb-incubator/weir/pkg/config"
+)
+
+func prepareEntry() *Entry {
+	return &Entry{
+		Time:          time.Date(2021, 1, 2, 3, 4, 5, 6, time.UTC),
+		Namespace:     "test_ns",
+		User:          "hello",
+		Host:          "127.0.0.1",
+		ConnID:        3,
+		DB:            "test_db",
+		Digest:        "abc",
+		NormalizedSQL: "select * from tbl where id = ?",
+		BackendAddr:   "127.0.0.1:4000",
+		QueryTime:     1500 * time.Millisecond,
+		Succ:          true,
+		SQL:           "select * from tbl where id = 1",
+	}
+}
+
+func TestEntry_Format(t *testing.T) {
+	expected := `# Time: 2021-01-02T03:04:05.000000006Z
+# User@Host: hello[hello] @ 127.0.0.1 [127.0.0.1]
+# Conn_ID: 3
+# Query_time: 1.5
+# Namespace: test_ns
+# Backend_addr: 127.0.0.1:4000
+# DB: test_db
+# Digest: abc
+# Normalized_sql: select * from tbl where id = ?
+# Succ: true
+select * from tbl where id = 1;
+`
+	require.Equal(t, expected, string(prepareEntry().Format()))
+}
+
+func TestEntry_Format_EscapeLineBreak(t *testing.T) {
+	e := prepareEntry()
+	e.NormalizedSQL = "select *\nfrom tbl"
+	e.SQL = "select *\nfrom tbl;"
+	e.Succ = false
+	data := string(e.Format())
+	require.Contains(t, data, "# Normalized_sql: select * from tbl\n")
+	require.Contains(t, data, "# Succ: false\nselect *\nfrom tbl;\n")
+}
+
+func TestLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "weir_slowlog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.False(t, Enabled())
+	require.NoError(t, Log(prepareEntry()))
+
+	filename := filepath.Join(dir, "slow.log")
+	require.NoError(t, Init(&config.LogFile{Filename: filename}))
+	require.True(t, Enabled())
+	require.NoError(t, Log(prepareEntry()))
+	require.NoError(t, Close())
+	require.False(t, Enabled())
+
+	data, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, string(prepareEntry().Format()), string(data))
+}
+
+func TestInit_ErrDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "weir_slowlog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.Error(t, Init(&config.LogFile{Filename: dir}))
+	require.False(t, Enabled())
+}
@@ -0,0 +1,165 @@
+package registry
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/util/logutil"
+	"github.com/tidb-incubator/weir/pkg/config"
+	"go.etcd.io/etcd/clientv3"
+	"go.uber.org/zap"
+)
+
+const (
+	// TiDB servers put "/topology/tidb/<ip>:<port>/ttl" with a lease in PD's etcd,
+	// the key is removed when the server exits or its lease expires.
+	TopologyInformationPath = "/topology/tidb/"
+	topologyTTLKeySuffix    = "/ttl"
+
+	DefaultEtcdDialTimeout = 3 * time.Second
+	watchRetryInterval     = time.Second
+)
+
+type EtcdRegistry struct {
+	etcdClient *clientv3.Client
+}
+
+func CreateEtcdRegistry(cfg config.Registry) (*EtcdRegistry, error) {
+	etcdConfig := clientv3.Config{
+		Endpoints:   cfg.Addrs,
+		DialTimeout: DefaultEtcdDialTimeout,
+	}
+	etcdClient, err := clientv3.New(etcdConfig)
+	if err != nil {
+		return nil, errors.WithMessage(err, "create etcd registry error")
+	}
+	return NewEtcdRegistry(etcdClient), nil
+}
+
+func NewEtcdRegistry(etcdClient *clientv3.Client) *EtcdRegistry {
+	return &EtcdRegistry{
+		etcdClient: etcdClient,
+	}
+}
+
+func (e *EtcdRegistry) ListInstances(ctx context.Context) ([]string, error) {
+	addrs, _, err := e.listInstances(ctx)
+	return addrs, err
+}
+
+func (e *EtcdRegistry) listInstances(ctx context.Context) ([]string, int64, error) {
+	resp, err := e.etcdClient.Get(ctx, TopologyInformationPath, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	addrs := make([]string, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		if addr, ok := parseTopologyTTLKey(string(kv.Key)); ok {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Strings(addrs)
+	return addrs, resp.Header.Revision, nil
+}
+
+func (e *EtcdRegistry) Watch(ctx context.Context) <-chan []string {
+	ch := make(chan []string, 1)
+	go e.watch(ctx, ch)
+	return ch
+}
+
+func (e *EtcdRegistry) watch(ctx context.Context, ch chan<- []string) {
+	defer close(ch)
+
+	var last []string
+	for {
+		// list again after the watch is broken, since events may be lost
+		addrs, rev, err := e.listInstances(ctx)
+		if err == nil {
+			if !sendIfChanged(ctx, ch, last, addrs) {
+				return
+			}
+			last = addrs
+			last, err = e.watchFromRevision(ctx, ch, last, rev+1)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		logutil.BgLogger().Warn("watch tidb topology error, retrying", zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRetryInterval):
+		}
+	}
+}
+
+// watchFromRevision lists the instances on each change of the topology keys, and returns when the watch is broken.
+func (e *EtcdRegistry) watchFromRevision(ctx context.Context, ch chan<- []string, last []string, rev int64) ([]string, error) {
+	watchCtx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+
+	watchCh := e.etcdClient.Watch(watchCtx, TopologyInformationPath, clientv3.WithPrefix(), clientv3.WithRev(rev))
+	for resp := range watchCh {
+		if err := resp.Err(); err != nil {
+			return last, err
+		}
+		addrs, _, err := e.listInstances(ctx)
+		if err != nil {
+			return last, err
+		}
+		if !sendIfChanged(ctx, ch, last, addrs) {
+			return last, ctx.Err()
+		}
+		last = addrs
+	}
+	return last, errors.New("watch channel closed")
+}
+
+func (e *EtcdRegistry) Close() {
+	if err := e.etcdClient.Close(); err != nil {
+		logutil.BgLogger().Error("close etcd client error", zap.Error(err))
+	}
+}
+
+// sendIfChanged returns false if ctx is done.
+func sendIfChanged(ctx context.Context, ch chan<- []string, last, addrs []string) bool {
+	if last != nil && isStringSliceEqual(last, addrs) {
+		return true
+	}
+	select {
+	case ch <- addrs:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func parseTopologyTTLKey(key string) (string, bool) {
+	if !strings.HasPrefix(key, TopologyInformationPath) || !strings.HasSuffix(key, topologyTTLKeySuffix) {
+		return "", false
+	}
+	addr := strings.TrimSuffix(strings.TrimPrefix(key, TopologyInformationPath), topologyTTLKeySuffix)
+	if addr == "" || strings.Contains(addr, "/") {
+		return "", false
+	}
+	return addr, true
+}
+
+func isStringSliceEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
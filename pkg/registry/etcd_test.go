@@ -0,0 +1,159 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/embed"
+)
+
+const testWaitTimeout = 5 * time.Second
+
+type EtcdRegistryTestSuite struct {
+	suite.Suite
+
+	dir    string
+	etcd   *embed.Etcd
+	client *clientv3.Client
+	reg    *EtcdRegistry
+}
+
+func TestEtcdRegistryTestSuite(t *testing.T) {
+	suite.Run(t, new(EtcdRegistryTestSuite))
+}
+
+func (s *EtcdRegistryTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "weir_registry")
+	s.Require().NoError(err)
+	s.dir = dir
+
+	cfg := embed.NewConfig()
+	cfg.Dir = dir
+	cfg.LogLevel = "error"
+	cfg.LCUrls = []url.URL{s.mustGetLocalURL()}
+	cfg.ACUrls = cfg.LCUrls
+	cfg.LPUrls = []url.URL{s.mustGetLocalURL()}
+	cfg.APUrls = cfg.LPUrls
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+
+	etcd, err := embed.StartEtcd(cfg)
+	s.Require().NoError(err)
+	s.etcd = etcd
+	select {
+	case <-etcd.Server.ReadyNotify():
+	case <-time.After(testWaitTimeout):
+		s.FailNow("start embed etcd timeout")
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{cfg.LCUrls[0].String()},
+		DialTimeout: DefaultEtcdDialTimeout,
+	})
+	s.Require().NoError(err)
+	s.client = client
+	s.reg = NewEtcdRegistry(client)
+}
+
+func (s *EtcdRegistryTestSuite) TearDownTest() {
+	s.reg.Close()
+	s.etcd.Close()
+	_ = os.RemoveAll(s.dir)
+}
+
+func (s *EtcdRegistryTestSuite) mustGetLocalURL() url.URL {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer l.Close()
+	u, err := url.Parse(fmt.Sprintf("http://%s", l.Addr().String()))
+	s.Require().NoError(err)
+	return *u
+}
+
+func (s *EtcdRegistryTestSuite) putTopology(addr string) {
+	ctx := context.Background()
+	_, err := s.client.Put(ctx, TopologyInformationPath+addr+"/info", "{}")
+	s.Require().NoError(err)
+	_, err = s.client.Put(ctx, TopologyInformationPath+addr+"/ttl", "1")
+	s.Require().NoError(err)
+}
+
+func (s *EtcdRegistryTestSuite) deleteTopologyTTL(addr string) {
+	_, err := s.client.Delete(context.Background(), TopologyInformationPath+addr+"/ttl")
+	s.Require().NoError(err)
+}
+
+func (s *EtcdRegistryTestSuite) mustReceive(ch <-chan []string) []string {
+	select {
+	case addrs := <-ch:
+		return addrs
+	case <-time.After(testWaitTimeout):
+		s.FailNow("wait for instances timeout")
+		return nil
+	}
+}
+
+func (s *EtcdRegistryTestSuite) TestListInstances() {
+	s.putTopology("127.0.0.1:4001")
+	s.putTopology("127.0.0.1:4000")
+	_, err := s.client.Put(context.Background(), "/topology/prometheus", "127.0.0.1:9090")
+	s.Require().NoError(err)
+
+	addrs, err := s.reg.ListInstances(context.Background())
+	s.Require().NoError(err)
+	s.Equal([]string{"127.0.0.1:4000", "127.0.0.1:4001"}, addrs)
+
+	// only info key left means the tidb server is not alive
+	s.deleteTopologyTTL("127.0.0.1:4000")
+	addrs, err = s.reg.ListInstances(context.Background())
+	s.Require().NoError(err)
+	s.Equal([]string{"127.0.0.1:4001"}, addrs)
+}
+
+func (s *EtcdRegistryTestSuite) TestWatch() {
+	s.putTopology("127.0.0.1:4000")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := s.reg.Watch(ctx)
+	s.Equal([]string{"127.0.0.1:4000"}, s.mustReceive(ch))
+
+	s.putTopology("127.0.0.1:4001")
+	s.Equal([]string{"127.0.0.1:4000", "127.0.0.1:4001"}, s.mustReceive(ch))
+
+	s.deleteTopologyTTL("127.0.0.1:4000")
+	s.Equal([]string{"127.0.0.1:4001"}, s.mustReceive(ch))
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		s.False(ok)
+	case <-time.After(testWaitTimeout):
+		s.Fail("watch channel is not closed")
+	}
+}
+
+func TestParseTopologyTTLKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		addr string
+		ok   bool
+	}{
+		{key: "/topology/tidb/127.0.0.1:4000/ttl", addr: "127.0.0.1:4000", ok: true},
+		{key: "/topology/tidb/127.0.0.1:4000/info", ok: false},
+		{key: "/topology/tidb//ttl", ok: false},
+		{key: "/topology/tiflash/127.0.0.1:3930/ttl", ok: false},
+	}
+	for _, tt := range tests {
+		addr, ok := parseTopologyTTLKey(tt.key)
+		if addr != tt.addr || ok != tt.ok {
+			t.Errorf("parseTopologyTTLKey(%s) = (%s, %v), want (%s, %v)", tt.key, addr, ok, tt.addr, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package registry
+
+import (
+	"context"
+
+	"github.com/pingcap/errors"
+	"github.com/tidb-incubator/weir/pkg/config"
+)
+
+const (
+	RegistryTypeEtcd = "etcd"
+)
+
+// Registry discovers backend TiDB instances.
+type Registry interface {
+	// ListInstances returns the sorted addrs of alive TiDB instances.
+	ListInstances(ctx context.Context) ([]string, error)
+	// Watch sends the full list of alive instances each time it changes,
+	// the returned channel is closed after ctx is done.
+	Watch(ctx context.Context) <-chan []string
+	Close()
+}
+
+func CreateRegistry(cfg config.Registry) (Registry, error) {
+	switch cfg.Type {
+	case RegistryTypeEtcd:
+		return CreateEtcdRegistry(cfg)
+	default:
+		return nil, errors.New("invalid registry type")
+	}
+}
@@ -59,12 +59,15 @@ func ExtractFirstTableNameFromStmt(stmt ast.StmtNode) string {
 
 type AstVisitor struct {
 	sqlFeature string
+	// undoList restores the nodes modified by visitor, so that stmt is still usable after extracting
+	undoList []func()
 }
 
 func ExtractAstVisit(stmt ast.StmtNode) (*AstVisitor, error) {
 	visitor := &AstVisitor{}
 
 	stmt.Accept(visitor)
+	defer visitor.undo()
 
 	sb := strings.Builder{}
 	if err := stmt.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags, &sb)); err != nil {
@@ -82,10 +85,14 @@ func (f *AstVisitor) Enter(n ast.Node) (node ast.Node, skipChildren bool) {
 			return nn, false
 		}
 		if _, ok := nn.List[0].(*driver.ValueExpr); ok {
+			list := nn.List
 			nn.List = nn.List[:1]
+			f.undoList = append(f.undoList, func() { nn.List = list })
 		}
 	case *driver.ValueExpr:
+		datum := nn.Datum
 		nn.SetValue("?")
+		f.undoList = append(f.undoList, func() { nn.Datum = datum })
 	}
 	return n, false
 }
@@ -94,6 +101,13 @@ func (f *AstVisitor) Leave(n ast.Node) (node ast.Node, ok bool) {
 	return n, true
 }
 
+func (f *AstVisitor) undo() {
+	for i := len(f.undoList) - 1; i >= 0; i-- {
+		f.undoList[i]()
+	}
+	f.undoList = nil
+}
+
 func (f *AstVisitor) SqlFeature() string {
 	return f.sqlFeature
 }
@@ -0,0 +1,39 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/format"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractAstVisit(t *testing.T) {
+	tests := []struct {
+		sql     string
+		feature string
+	}{
+		{sql: "SELECT * FROM tbl1 WHERE id = 1", feature: "SELECT * FROM `tbl1` WHERE `id`='?'"},
+		{sql: "SELECT * FROM tbl1 WHERE id IN (1, 2, 3)", feature: "SELECT * FROM `tbl1` WHERE `id` IN ('?')"},
+		{sql: "SET time_zone = '+08:00'", feature: "SET @@SESSION.`time_zone`='?'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			stmt, err := parser.New().ParseOneStmt(tt.sql, "", "")
+			assert.NoError(t, err)
+			sb := &strings.Builder{}
+			assert.NoError(t, stmt.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags, sb)))
+			restored := sb.String()
+
+			visitor, err := ExtractAstVisit(stmt)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.feature, visitor.SqlFeature())
+
+			// stmt is not modified
+			sb.Reset()
+			assert.NoError(t, stmt.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags, sb)))
+			assert.Equal(t, restored, sb.String())
+		})
+	}
+}
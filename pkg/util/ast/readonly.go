@@ -0,0 +1,134 @@
+package ast
+
+import (
+	"context"
+
+	"github.com/pingcap/parser/ast"
+	"github.com/tidb-incubator/weir/pkg/proxy/constant"
+)
+
+const ctxAstReadOnlyStmtKey = constant.ContextKeyPrefix + "ast_read_only_stmt"
+
+// functions whose result depends on the state of backend session or which modify it,
+// statements calling them must be routed to the same backend as writes.
+var sessionStateFuncs = map[string]struct{}{
+	ast.LastInsertId:    {},
+	ast.FoundRows:       {},
+	ast.RowCount:        {},
+	ast.GetLock:         {},
+	ast.ReleaseLock:     {},
+	ast.ReleaseAllLocks: {},
+	ast.IsFreeLock:      {},
+	ast.IsUsedLock:      {},
+	ast.NextVal:         {},
+	ast.LastVal:         {},
+	ast.SetVal:          {},
+}
+
+func CtxWithReadOnlyStmt(ctx context.Context, readOnly bool) context.Context {
+	return context.WithValue(ctx, ctxAstReadOnlyStmtKey, readOnly)
+}
+
+func IsReadOnlyStmtFromCtx(ctx context.Context) bool {
+	readOnly, ok := ctx.Value(ctxAstReadOnlyStmtKey).(bool)
+	return ok && readOnly
+}
+
+// IsReadOnlyStmt returns true if stmt is a plain SELECT (or UNION of SELECTs)
+// which neither takes row locks nor reads or modifies backend session state,
+// so it can be served by any backend instance including read replicas.
+func IsReadOnlyStmt(stmt ast.StmtNode) bool {
+	switch stmt.(type) {
+	case *ast.SelectStmt, *ast.UnionStmt:
+	default:
+		return false
+	}
+
+	visitor := &readOnlyVisitor{readOnly: true}
+	stmt.Accept(visitor)
+	return visitor.readOnly
+}
+
+type readOnlyVisitor struct {
+	readOnly bool
+}
+
+func (r *readOnlyVisitor) Enter(n ast.Node) (node ast.Node, skipChildren bool) {
+	switch nn := n.(type) {
+	case *ast.SelectStmt:
+		if nn.LockTp != ast.SelectLockNone || nn.SelectIntoOpt != nil {
+			r.readOnly = false
+		}
+	case *ast.VariableExpr:
+		// user variables are not synced between backend conns
+		if nn.Value != nil || !nn.IsSystem {
+			r.readOnly = false
+		}
+	case *ast.FuncCallExpr:
+		if _, ok := sessionStateFuncs[nn.FnName.L]; ok {
+			r.readOnly = false
+		}
+	}
+	return n, !r.readOnly
+}
+
+func (r *readOnlyVisitor) Leave(n ast.Node) (node ast.Node, ok bool) {
+	return n, r.readOnly
+}
+
+// IsUserVarOrLastInsertIDUsed returns true if stmt reads or assigns user variables, or calls LAST_INSERT_ID().
+// Their values are kept by the backend session, so the statement must be served by the same backend conn as before.
+func IsUserVarOrLastInsertIDUsed(stmt ast.StmtNode) bool {
+	visitor := &userVarVisitor{}
+	stmt.Accept(visitor)
+	return visitor.used
+}
+
+type userVarVisitor struct {
+	used bool
+}
+
+func (u *userVarVisitor) Enter(n ast.Node) (node ast.Node, skipChildren bool) {
+	switch nn := n.(type) {
+	case *ast.VariableExpr:
+		if !nn.IsSystem {
+			u.used = true
+		}
+	case *ast.VariableAssignment:
+		if !nn.IsSystem && nn.Name != ast.SetNames && nn.Name != ast.SetCharset {
+			u.used = true
+		}
+	case *ast.FuncCallExpr:
+		if nn.FnName.L == ast.LastInsertId {
+			u.used = true
+		}
+	}
+	return n, u.used
+}
+
+func (u *userVarVisitor) Leave(n ast.Node) (node ast.Node, ok bool) {
+	return n, true
+}
+
+// IsUserVarRead returns true if stmt reads the value of any user variable.
+func IsUserVarRead(stmt ast.StmtNode) bool {
+	visitor := &userVarReadVisitor{}
+	stmt.Accept(visitor)
+	return visitor.read
+}
+
+type userVarReadVisitor struct {
+	read bool
+}
+
+func (u *userVarReadVisitor) Enter(n ast.Node) (node ast.Node, skipChildren bool) {
+	// assignments like @a := 1 are VariableExprs with Value set
+	if v, ok := n.(*ast.VariableExpr); ok && !v.IsSystem && v.Value == nil {
+		u.read = true
+	}
+	return n, u.read
+}
+
+func (u *userVarReadVisitor) Leave(n ast.Node) (node ast.Node, ok bool) {
+	return n, true
+}
@@ -0,0 +1,98 @@
+package ast
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pingcap/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsReadOnlyStmt(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{sql: "SELECT 1", want: true},
+		{sql: "SELECT * FROM tbl1 WHERE id = 1", want: true},
+		{sql: "SELECT * FROM tbl1 UNION SELECT * FROM tbl2", want: true},
+		{sql: "SELECT * FROM tbl1 WHERE id IN (SELECT id FROM tbl2)", want: true},
+		{sql: "SELECT @@tx_isolation", want: true},
+		{sql: "SELECT * FROM tbl1 FOR UPDATE", want: false},
+		{sql: "SELECT * FROM tbl1 UNION SELECT * FROM tbl2 FOR UPDATE", want: false},
+		{sql: "SELECT * FROM tbl1 INTO OUTFILE '/tmp/tbl1'", want: false},
+		{sql: "SELECT @a", want: false},
+		{sql: "SELECT @a := 1", want: false},
+		{sql: "SELECT LAST_INSERT_ID()", want: false},
+		{sql: "SELECT FOUND_ROWS()", want: false},
+		{sql: "SELECT GET_LOCK('lock1', 10)", want: false},
+		{sql: "INSERT INTO tbl1 VALUES (1)", want: false},
+		{sql: "INSERT INTO tbl1 SELECT * FROM tbl2", want: false},
+		{sql: "UPDATE tbl1 SET a = 1 WHERE id = 1", want: false},
+		{sql: "DELETE FROM tbl1 WHERE id = 1", want: false},
+		{sql: "SHOW TABLES", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			stmt, err := parser.New().ParseOneStmt(tt.sql, "", "")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, IsReadOnlyStmt(stmt))
+		})
+	}
+}
+
+func TestReadOnlyStmtCtx(t *testing.T) {
+	ctx := context.Background()
+	assert.False(t, IsReadOnlyStmtFromCtx(ctx))
+	assert.True(t, IsReadOnlyStmtFromCtx(CtxWithReadOnlyStmt(ctx, true)))
+	assert.False(t, IsReadOnlyStmtFromCtx(CtxWithReadOnlyStmt(ctx, false)))
+}
+
+func TestIsUserVarOrLastInsertIDUsed(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{sql: "SELECT 1", want: false},
+		{sql: "SELECT @@tx_isolation", want: false},
+		{sql: "SET NAMES utf8mb4", want: false},
+		{sql: "SET @@session.sql_mode = ''", want: false},
+		{sql: "SELECT @a", want: true},
+		{sql: "SELECT * FROM tbl1 WHERE id IN (SELECT id FROM tbl2 WHERE a = @a)", want: true},
+		{sql: "SELECT @a := 1", want: true},
+		{sql: "SET @a = 1", want: true},
+		{sql: "SELECT LAST_INSERT_ID()", want: true},
+		{sql: "INSERT INTO tbl1 VALUES (LAST_INSERT_ID())", want: true},
+		{sql: "UPDATE tbl1 SET a = @a WHERE id = 1", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			stmt, err := parser.New().ParseOneStmt(tt.sql, "", "")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, IsUserVarOrLastInsertIDUsed(stmt))
+		})
+	}
+}
+
+func TestIsUserVarRead(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{sql: "SELECT @@tx_isolation", want: false},
+		{sql: "SELECT LAST_INSERT_ID()", want: false},
+		{sql: "SET @a = 1", want: false},
+		{sql: "SELECT @a := 1", want: false},
+		{sql: "SELECT @a", want: true},
+		{sql: "SET @b = @a + 1", want: true},
+		{sql: "SELECT @a := @a + 1", want: true},
+		{sql: "UPDATE tbl1 SET a = @a WHERE id = 1", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			stmt, err := parser.New().ParseOneStmt(tt.sql, "", "")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, IsUserVarRead(stmt))
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package netutil
+
+import (
+	"net"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// ParseIPNet parses a CIDR range or a single ip, which is treated as a range containing only itself.
+func ParseIPNet(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, errors.Errorf("invalid cidr: %s", s)
+		}
+		return ipNet, nil
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, errors.Errorf("invalid ip: %s", s)
+	}
+	bits := 8 * net.IPv4len
+	if ip.To4() == nil {
+		bits = 8 * net.IPv6len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
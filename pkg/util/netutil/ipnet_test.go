@@ -0,0 +1,41 @@
+package netutil
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIPNet(t *testing.T) {
+	tests := []struct {
+		s       string
+		contain []string
+		exclude []string
+		err     bool
+	}{
+		{s: "127.0.0.1", contain: []string{"127.0.0.1"}, exclude: []string{"127.0.0.2"}},
+		{s: " 10.0.0.0/8 ", contain: []string{"10.1.2.3"}, exclude: []string{"11.0.0.1"}},
+		{s: "::1", contain: []string{"::1"}, exclude: []string{"127.0.0.1"}},
+		{s: "fd00::/8", contain: []string{"fd00::1"}, exclude: []string{"fe80::1"}},
+		{s: "", err: true},
+		{s: "10.0.0.0/33", err: true},
+		{s: "localhost", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			ipNet, err := ParseIPNet(tt.s)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			for _, ip := range tt.contain {
+				require.True(t, ipNet.Contains(net.ParseIP(ip)), ip)
+			}
+			for _, ip := range tt.exclude {
+				require.False(t, ipNet.Contains(net.ParseIP(ip)), ip)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+// Package proxyprotocol implements HAProxy PROXY protocol v1 and v2 on the server side,
+// see https://www.haproxy.org/download/2.3/doc/proxy-protocol.txt
+package proxyprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/tidb-incubator/weir/pkg/util/netutil"
+)
+
+const (
+	DefaultHeaderTimeout = 5 * time.Second
+
+	allNetworks = "*"
+
+	v1Prefix       = "PROXY "
+	v1MaxLength    = 107 // including CRLF
+	v1ProtoTCP4    = "TCP4"
+	v1ProtoTCP6    = "TCP6"
+	v1ProtoUnknown = "UNKNOWN"
+
+	v2HeaderLength  = 16
+	v2Version       = 0x20
+	v2CmdLocal      = 0x00
+	v2CmdProxy      = 0x01
+	v2FamilyInet    = 0x10
+	v2FamilyInet6   = 0x20
+	v2AddrLenInet   = 12
+	v2AddrLenInet6  = 36
+	v2VersionMask   = 0xF0
+	v2CmdMask       = 0x0F
+	v2FamilyMask    = 0xF0
+	v2SignatureSize = 12
+)
+
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+var (
+	ErrNoProxyHeader      = errors.New("proxy protocol header is missing")
+	ErrInvalidProxyHeader = errors.New("invalid proxy protocol header")
+)
+
+// Listener requires connections from trusted networks to send a PROXY protocol header,
+// connections from other networks are returned as is.
+type Listener struct {
+	net.Listener
+	trustAll      bool
+	trusted       []*net.IPNet
+	headerTimeout time.Duration
+}
+
+// NewListener wraps l, networks are ips or CIDRs, "*" means all networks are trusted.
+func NewListener(l net.Listener, networks []string, headerTimeout time.Duration) (*Listener, error) {
+	pl := &Listener{
+		Listener:      l,
+		headerTimeout: headerTimeout,
+	}
+	if pl.headerTimeout <= 0 {
+		pl.headerTimeout = DefaultHeaderTimeout
+	}
+	for _, network := range networks {
+		network = strings.TrimSpace(network)
+		if network == allNetworks {
+			pl.trustAll = true
+			continue
+		}
+		ipNet, err := netutil.ParseIPNet(network)
+		if err != nil {
+			return nil, err
+		}
+		pl.trusted = append(pl.trusted, ipNet)
+	}
+	return pl, nil
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.isTrusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return newConn(conn, l.headerTimeout), nil
+}
+
+func (l *Listener) isTrusted(addr net.Addr) bool {
+	if l.trustAll {
+		return true
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, ipNet := range l.trusted {
+		if ipNet.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn reads the PROXY protocol header lazily on the first Read or RemoteAddr call,
+// so that a slow client does not block the accept loop.
+type Conn struct {
+	net.Conn
+	reader        *bufio.Reader
+	headerTimeout time.Duration
+
+	once       sync.Once
+	remoteAddr net.Addr
+	err        error
+}
+
+func newConn(conn net.Conn, headerTimeout time.Duration) *Conn {
+	return &Conn{
+		Conn:          conn,
+		reader:        bufio.NewReader(conn),
+		headerTimeout: headerTimeout,
+	}
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	if err := c.readHeaderOnce(); err != nil {
+		return 0, err
+	}
+	if c.reader.Buffered() > 0 {
+		return c.reader.Read(b)
+	}
+	return c.Conn.Read(b)
+}
+
+// RemoteAddr returns the client address in PROXY protocol header,
+// it returns address of the proxy if the header is invalid or the proxy sends a LOCAL command.
+func (c *Conn) RemoteAddr() net.Addr {
+	if err := c.readHeaderOnce(); err != nil {
+		return c.Conn.RemoteAddr()
+	}
+	return c.remoteAddr
+}
+
+// NetConn returns the underlying connection.
+func (c *Conn) NetConn() net.Conn {
+	return c.Conn
+}
+
+func (c *Conn) readHeaderOnce() error {
+	c.once.Do(func() {
+		if err := c.Conn.SetReadDeadline(time.Now().Add(c.headerTimeout)); err != nil {
+			c.err = err
+			return
+		}
+		c.remoteAddr, c.err = c.readHeader()
+		if c.err == nil && c.remoteAddr == nil {
+			c.remoteAddr = c.Conn.RemoteAddr()
+		}
+		if err := c.Conn.SetReadDeadline(time.Time{}); err != nil && c.err == nil {
+			c.err = err
+		}
+	})
+	return c.err
+}
+
+// readHeader returns nil addr if the header doesn't carry client address.
+func (c *Conn) readHeader() (net.Addr, error) {
+	prefix, err := c.reader.Peek(len(v1Prefix))
+	if err != nil {
+		return nil, errors.WithMessage(err, "read proxy protocol header error")
+	}
+	if string(prefix) == v1Prefix {
+		return c.readHeaderV1()
+	}
+	if bytes.HasPrefix(v2Signature, prefix) {
+		return c.readHeaderV2()
+	}
+	return nil, ErrNoProxyHeader
+}
+
+// readHeaderV1 reads header like "PROXY TCP4 192.168.0.1 192.168.0.11 56324 4000\r\n".
+func (c *Conn) readHeaderV1() (net.Addr, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= v1MaxLength {
+			return nil, ErrInvalidProxyHeader
+		}
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			return nil, errors.WithMessage(err, "read proxy protocol header error")
+		}
+		line = append(line, b)
+	}
+
+	fields := strings.Split(strings.TrimSuffix(string(line), "\r\n"), " ")
+	if len(fields) < 2 {
+		return nil, ErrInvalidProxyHeader
+	}
+	switch fields[1] {
+	case v1ProtoUnknown:
+		return nil, nil
+	case v1ProtoTCP4, v1ProtoTCP6:
+	default:
+		return nil, ErrInvalidProxyHeader
+	}
+	if len(fields) != 6 {
+		return nil, ErrInvalidProxyHeader
+	}
+	ip := net.ParseIP(fields[2])
+	if ip == nil || (ip.To4() != nil) != (fields[1] == v1ProtoTCP4) {
+		return nil, ErrInvalidProxyHeader
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, ErrInvalidProxyHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+func (c *Conn) readHeaderV2() (net.Addr, error) {
+	header := make([]byte, v2HeaderLength)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return nil, errors.WithMessage(err, "read proxy protocol header error")
+	}
+	if !bytes.Equal(header[:v2SignatureSize], v2Signature) || header[12]&v2VersionMask != v2Version {
+		return nil, ErrInvalidProxyHeader
+	}
+	cmd := header[12] & v2CmdMask
+	family := header[13] & v2FamilyMask
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return nil, errors.WithMessage(err, "read proxy protocol header error")
+	}
+
+	switch cmd {
+	case v2CmdLocal:
+		return nil, nil
+	case v2CmdProxy:
+	default:
+		return nil, ErrInvalidProxyHeader
+	}
+	// TLVs after addresses are ignored.
+	switch family {
+	case v2FamilyInet:
+		if len(payload) < v2AddrLenInet {
+			return nil, ErrInvalidProxyHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case v2FamilyInet6:
+		if len(payload) < v2AddrLenInet6 {
+			return nil, ErrInvalidProxyHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	default:
+		// unspecified and unix families don't carry ip address
+		return nil, nil
+	}
+}
@@ -0,0 +1,144 @@
+package proxyprotocol
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestListener(t *testing.T, networks []string, headerTimeout time.Duration) *Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	pl, err := NewListener(l, networks, headerTimeout)
+	require.NoError(t, err)
+	return pl
+}
+
+// dialAndSend dials l, sends data and returns the accepted conn.
+func dialAndSend(t *testing.T, l net.Listener, data []byte) net.Conn {
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	_, err = client.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, client.Close())
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	return conn
+}
+
+func buildHeaderV2(cmd, family byte, addrs []byte) []byte {
+	header := append([]byte{}, v2Signature...)
+	header = append(header, v2Version|cmd, family|0x01, 0, 0)
+	binary.BigEndian.PutUint16(header[14:16], uint16(len(addrs)))
+	return append(header, addrs...)
+}
+
+func TestNewListener_Error(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	_, err = NewListener(l, []string{"10.0.0.0/33"}, 0)
+	require.Error(t, err)
+	_, err = NewListener(l, []string{"not_ip"}, 0)
+	require.Error(t, err)
+}
+
+func TestConn_Header(t *testing.T) {
+	l := newTestListener(t, []string{"127.0.0.0/8"}, time.Second)
+	defer l.Close()
+
+	v2Inet := buildHeaderV2(v2CmdProxy, v2FamilyInet, []byte{
+		192, 168, 0, 1, // src ip
+		192, 168, 0, 11, // dst ip
+		0xdc, 0x04, // src port 56324
+		0x0f, 0xa0, // dst port 4000
+		0x01, 0x00, 0x00, // tlv is ignored
+	})
+	v2Inet6 := buildHeaderV2(v2CmdProxy, v2FamilyInet6, append(append(
+		net.ParseIP("2001:db8::1").To16(), net.ParseIP("2001:db8::2").To16()...),
+		0xdc, 0x04, 0x0f, 0xa0))
+	v2Local := buildHeaderV2(v2CmdLocal, 0, nil)
+
+	cases := []struct {
+		header     []byte
+		remoteAddr string // empty means addr of the proxy
+	}{
+		{[]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 4000\r\n"), "192.168.0.1:56324"},
+		{[]byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 4000\r\n"), "[2001:db8::1]:56324"},
+		{[]byte("PROXY UNKNOWN\r\n"), ""},
+		{v2Inet, "192.168.0.1:56324"},
+		{v2Inet6, "[2001:db8::1]:56324"},
+		{v2Local, ""},
+	}
+	for _, c := range cases {
+		conn := dialAndSend(t, l, append(c.header, "hello"...))
+		expectedAddr := c.remoteAddr
+		if expectedAddr == "" {
+			expectedAddr = conn.(*Conn).NetConn().RemoteAddr().String()
+		}
+		require.Equal(t, expectedAddr, conn.RemoteAddr().String())
+		data, err := ioutil.ReadAll(conn)
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(data))
+		require.NoError(t, conn.Close())
+	}
+}
+
+func TestConn_InvalidHeader(t *testing.T) {
+	l := newTestListener(t, []string{"*"}, time.Second)
+	defer l.Close()
+
+	cases := []struct {
+		header []byte
+		err    error
+	}{
+		{[]byte("hello world"), ErrNoProxyHeader},
+		{[]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324\r\n"), ErrInvalidProxyHeader},
+		{[]byte("PROXY TCP4 2001:db8::1 2001:db8::2 56324 4000\r\n"), ErrInvalidProxyHeader},
+		{[]byte("PROXY UDP4 192.168.0.1 192.168.0.11 56324 4000\r\n"), ErrInvalidProxyHeader},
+		{append([]byte("PROXY TCP4 "), make([]byte, v1MaxLength)...), ErrInvalidProxyHeader},
+		{buildHeaderV2(v2CmdProxy, v2FamilyInet, []byte{192, 168, 0, 1}), ErrInvalidProxyHeader},
+	}
+	for _, c := range cases {
+		conn := dialAndSend(t, l, c.header)
+		_, err := conn.Read(make([]byte, 1))
+		require.Equal(t, c.err, err)
+		require.NoError(t, conn.Close())
+	}
+}
+
+func TestConn_HeaderTimeout(t *testing.T) {
+	l := newTestListener(t, []string{"*"}, 50*time.Millisecond)
+	defer l.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+	require.Equal(t, client.LocalAddr().String(), conn.RemoteAddr().String())
+}
+
+func TestListener_Untrusted(t *testing.T) {
+	l := newTestListener(t, []string{"10.0.0.0/8"}, time.Second)
+	defer l.Close()
+
+	header := "PROXY TCP4 192.168.0.1 192.168.0.11 56324 4000\r\n"
+	conn := dialAndSend(t, l, []byte(header))
+	defer conn.Close()
+	_, ok := conn.(*Conn)
+	require.False(t, ok)
+	data, err := ioutil.ReadAll(conn)
+	require.NoError(t, err)
+	require.Equal(t, header, string(data))
+}